@@ -7,14 +7,17 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	tomb "gopkg.in/tomb.v2"
 
 	"github.com/restic/restic/internal/archiver"
@@ -54,6 +57,11 @@ given as the arguments.
 					return errors.Fatal("cannot use both `--stdin` and `--files-from -`")
 				}
 			}
+			for _, filename := range backupOptions.FilesFromRaw {
+				if filename == "-" {
+					return errors.Fatal("cannot use both `--stdin` and `--files-from0 -`")
+				}
+			}
 		}
 
 		var t tomb.Tomb
@@ -79,42 +87,62 @@ type BackupOptions struct {
 	ExcludeOtherFS      bool
 	ExcludeIfPresent    []string
 	ExcludeCaches       bool
+	ExcludeLargerThan   string
+	IgnoreFileName      string
 	Stdin               bool
 	StdinFilename       string
 	Tags                []string
 	Host                string
 	FilesFrom           []string
+	FilesFromRaw        []string
 	TimeStamp           string
 	WithAtime           bool
 	IgnoreInode         bool
+	IgnoreCtime         bool
+	ReadConcurrency     uint
+	UseFsSnapshot       bool
+	DryRun              bool
 }
 
 var backupOptions BackupOptions
 
 func init() {
 	cmdRoot.AddCommand(cmdBackup)
+	initBackupFlags(cmdBackup.Flags(), &backupOptions)
+}
 
-	f := cmdBackup.Flags()
-	f.StringVar(&backupOptions.Parent, "parent", "", "use this parent snapshot (default: last snapshot in the repo that has the same target files/directories)")
-	f.BoolVarP(&backupOptions.Force, "force", "f", false, `force re-reading the target files/directories (overrides the "parent" flag)`)
-	f.StringArrayVarP(&backupOptions.Excludes, "exclude", "e", nil, "exclude a `pattern` (can be specified multiple times)")
-	f.StringArrayVar(&backupOptions.InsensitiveExcludes, "iexclude", nil, "same as `--exclude` but ignores the casing of filenames")
-	f.StringArrayVar(&backupOptions.ExcludeFiles, "exclude-file", nil, "read exclude patterns from a `file` (can be specified multiple times)")
-	f.BoolVarP(&backupOptions.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems")
-	f.StringArrayVar(&backupOptions.ExcludeIfPresent, "exclude-if-present", nil, "takes filename[:header], exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
-	f.BoolVar(&backupOptions.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See http://bford.info/cachedir/spec.html for the Cache Directory Tagging Standard`)
-	f.BoolVar(&backupOptions.Stdin, "stdin", false, "read backup from stdin")
-	f.StringVar(&backupOptions.StdinFilename, "stdin-filename", "stdin", "file name to use when reading from stdin")
-	f.StringArrayVar(&backupOptions.Tags, "tag", nil, "add a `tag` for the new snapshot (can be specified multiple times)")
-
-	f.StringVarP(&backupOptions.Host, "host", "H", "", "set the `hostname` for the snapshot manually. To prevent an expensive rescan use the \"parent\" flag")
-	f.StringVar(&backupOptions.Host, "hostname", "", "set the `hostname` for the snapshot manually")
+// initBackupFlags adds the flags of the backup command to f, they are also
+// used by the watch command.
+func initBackupFlags(f *pflag.FlagSet, opts *BackupOptions) {
+	f.StringVar(&opts.Parent, "parent", "", "use this parent snapshot (default: last snapshot in the repo that has the same target files/directories)")
+	f.BoolVarP(&opts.Force, "force", "f", false, `force re-reading the target files/directories (overrides the "parent" flag)`)
+	f.StringArrayVarP(&opts.Excludes, "exclude", "e", nil, "exclude a `pattern` (can be specified multiple times)")
+	f.StringArrayVar(&opts.InsensitiveExcludes, "iexclude", nil, "same as `--exclude` but ignores the casing of filenames")
+	f.StringArrayVar(&opts.ExcludeFiles, "exclude-file", nil, "read exclude patterns from a `file` (can be specified multiple times)")
+	f.BoolVarP(&opts.ExcludeOtherFS, "one-file-system", "x", false, "exclude other file systems")
+	f.StringArrayVar(&opts.ExcludeIfPresent, "exclude-if-present", nil, "takes filename[:header], exclude contents of directories containing filename (except filename itself) if header of that file is as provided (can be specified multiple times)")
+	f.BoolVar(&opts.ExcludeCaches, "exclude-caches", false, `excludes cache directories that are marked with a CACHEDIR.TAG file. See http://bford.info/cachedir/spec.html for the Cache Directory Tagging Standard`)
+	f.StringVar(&opts.ExcludeLargerThan, "exclude-larger-than", "", "exclude files larger than `size` (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.StringVar(&opts.IgnoreFileName, "ignore-file-name", "", "exclude items matching the patterns in files with this `name` in the directories above them within the backup targets")
+	f.BoolVar(&opts.Stdin, "stdin", false, "read backup from stdin")
+	f.StringVar(&opts.StdinFilename, "stdin-filename", "stdin", "file name to use when reading from stdin")
+	f.StringArrayVar(&opts.Tags, "tag", nil, "add a `tag` for the new snapshot (can be specified multiple times)")
+
+	f.StringVarP(&opts.Host, "host", "H", "", "set the `hostname` for the snapshot manually. To prevent an expensive rescan use the \"parent\" flag")
+	f.StringVar(&opts.Host, "hostname", "", "set the `hostname` for the snapshot manually")
 	f.MarkDeprecated("hostname", "use --host")
 
-	f.StringArrayVar(&backupOptions.FilesFrom, "files-from", nil, "read the files to backup from file (can be combined with file args/can be specified multiple times)")
-	f.StringVar(&backupOptions.TimeStamp, "time", "", "time of the backup (ex. '2012-11-01 22:08:41') (default: now)")
-	f.BoolVar(&backupOptions.WithAtime, "with-atime", false, "store the atime for all files and directories")
-	f.BoolVar(&backupOptions.IgnoreInode, "ignore-inode", false, "ignore inode number changes when checking for modified files")
+	f.StringArrayVar(&opts.FilesFrom, "files-from", nil, "read the files to backup from file (can be combined with file args/can be specified multiple times)")
+	f.StringArrayVar(&opts.FilesFromRaw, "files-from0", nil, "read the NUL separated names of the files to backup from `file`, e.g. from `find -print0` (can be combined with file args/can be specified multiple times)")
+	f.StringVar(&opts.TimeStamp, "time", "", "time of the backup (ex. '2012-11-01 22:08:41') (default: now)")
+	f.BoolVar(&opts.WithAtime, "with-atime", false, "store the atime for all files and directories")
+	f.BoolVar(&opts.IgnoreInode, "ignore-inode", false, "ignore inode number and ctime changes when checking for modified files")
+	f.BoolVar(&opts.IgnoreCtime, "ignore-ctime", false, "ignore ctime changes when checking for modified files")
+	f.UintVar(&opts.ReadConcurrency, "read-concurrency", 0, "read `n` files concurrently (default: 2)")
+	f.BoolVarP(&opts.DryRun, "dry-run", "n", false, "do not write anything to the repository, only report what would be added")
+	if runtime.GOOS == "windows" {
+		f.BoolVar(&opts.UseFsSnapshot, "use-fs-snapshot", false, "read the files from a snapshot of the file system (Windows VSS)")
+	}
 }
 
 // filterExisting returns a slice of all existing items, or an error if no
@@ -184,24 +212,105 @@ func readLinesFromFile(filename string) ([]string, error) {
 	return lines, nil
 }
 
+// readFilenamesFromFileRaw reads the NUL separated file names from the given
+// file, or from the standard input if filename is a dash (-). The names are
+// used verbatim, they are neither trimmed nor expanded.
+func readFilenamesFromFileRaw(filename string) ([]string, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	if filename == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(filename)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range strings.Split(string(data), "\x00") {
+		// ignore empty names, e.g. after the last separator
+		if name == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// uniqueTargets returns the targets without duplicates, the order of the
+// targets is kept. Two targets are the same if their absolute paths only
+// differ in redundant separators or "." elements. Unlike filepath.Clean, ".."
+// elements are not removed, since they may follow a symlink.
+func uniqueTargets(targets []string) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, errors.Wrap(err, "Getwd")
+	}
+
+	seen := make(map[string]struct{}, len(targets))
+	result := make([]string, 0, len(targets))
+	for _, target := range targets {
+		key := targetKey(wd, target)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		result = append(result, target)
+	}
+
+	return result, nil
+}
+
+// targetKey returns the absolute path of target relative to wd, with
+// redundant separators and "." elements removed.
+func targetKey(wd, target string) string {
+	if !filepath.IsAbs(target) {
+		target = wd + string(filepath.Separator) + target
+	}
+
+	volume := filepath.VolumeName(target)
+	var elements []string
+	for _, element := range strings.Split(filepath.ToSlash(target[len(volume):]), "/") {
+		if element == "" || element == "." {
+			continue
+		}
+		elements = append(elements, element)
+	}
+
+	return volume + string(filepath.Separator) + strings.Join(elements, string(filepath.Separator))
+}
+
 // Check returns an error when an invalid combination of options was set.
 func (opts BackupOptions) Check(gopts GlobalOptions, args []string) error {
 	if gopts.password == "" {
-		for _, filename := range opts.FilesFrom {
-			if filename == "-" {
-				return errors.Fatal("unable to read password from stdin when data is to be read from stdin, use --password-file or $RESTIC_PASSWORD")
+		for _, filenames := range [][]string{opts.FilesFrom, opts.FilesFromRaw} {
+			for _, filename := range filenames {
+				if filename == "-" {
+					return errors.Fatal("unable to read password from stdin when data is to be read from stdin, use --password-file or $RESTIC_PASSWORD")
+				}
 			}
 		}
 	}
 
 	if opts.Stdin {
-		if len(opts.FilesFrom) > 0 {
+		if len(opts.FilesFrom) > 0 || len(opts.FilesFromRaw) > 0 {
 			return errors.Fatal("--stdin and --files-from cannot be used together")
 		}
 
 		if len(args) > 0 {
 			return errors.Fatal("--stdin was specified and files/dirs were listed as arguments")
 		}
+
+		if opts.UseFsSnapshot {
+			return errors.Fatal("--stdin and --use-fs-snapshot cannot be used together")
+		}
 	}
 
 	return nil
@@ -209,7 +318,7 @@ func (opts BackupOptions) Check(gopts GlobalOptions, args []string) error {
 
 // collectRejectByNameFuncs returns a list of all functions which may reject data
 // from being saved in a snapshot based on path only
-func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository, targets []string) (fs []RejectByNameFunc, err error) {
+func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository, targetFS fs.FS, targets []string) (fs []RejectByNameFunc, err error) {
 	// exclude restic cache
 	if repo.Cache != nil {
 		f, err := rejectResticCache(repo)
@@ -237,6 +346,15 @@ func collectRejectByNameFuncs(opts BackupOptions, repo *repository.Repository, t
 		fs = append(fs, rejectByPattern(opts.Excludes))
 	}
 
+	if opts.IgnoreFileName != "" {
+		f, err := rejectByIgnoreFile(targetFS, opts.IgnoreFileName, targets)
+		if err != nil {
+			return nil, err
+		}
+
+		fs = append(fs, f)
+	}
+
 	if opts.ExcludeCaches {
 		opts.ExcludeIfPresent = append(opts.ExcludeIfPresent, "CACHEDIR.TAG:Signature: 8a477f597d28d172789f06886806bc55")
 	}
@@ -265,6 +383,18 @@ func collectRejectFuncs(opts BackupOptions, repo *repository.Repository, targets
 		fs = append(fs, f)
 	}
 
+	if opts.ExcludeLargerThan != "" && !opts.Stdin {
+		maxSize, err := parseSizeStr(opts.ExcludeLargerThan)
+		if err != nil {
+			return nil, errors.Fatalf("invalid value for --exclude-larger-than: %v", err)
+		}
+		if maxSize > math.MaxInt64 {
+			return nil, errors.Fatalf("invalid value for --exclude-larger-than: size %q is too large", opts.ExcludeLargerThan)
+		}
+
+		fs = append(fs, rejectBySize(int64(maxSize)))
+	}
+
 	return fs, nil
 }
 
@@ -274,6 +404,25 @@ func collectRejectFuncs(opts BackupOptions, repo *repository.Repository, targets
 // variables are resolved. For adding a literal dollar sign ($), write $$ to
 // the file.
 func readExcludePatternsFromFiles(excludeFiles []string) ([]string, error) {
+	var excludes []string
+	for _, filename := range excludeFiles {
+		data, err := textfile.Read(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		patterns, err := parseExcludePatterns(data)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(excludes, patterns...)
+	}
+	return excludes, nil
+}
+
+// parseExcludePatterns returns the patterns in data, one per line. Empty lines
+// and comments are skipped, and environment variables are expanded.
+func parseExcludePatterns(data []byte) ([]string, error) {
 	getenvOrDollar := func(s string) string {
 		if s == "$" {
 			return "$"
@@ -282,37 +431,24 @@ func readExcludePatternsFromFiles(excludeFiles []string) ([]string, error) {
 	}
 
 	var excludes []string
-	for _, filename := range excludeFiles {
-		err := func() (err error) {
-			data, err := textfile.Read(filename)
-			if err != nil {
-				return err
-			}
-
-			scanner := bufio.NewScanner(bytes.NewReader(data))
-			for scanner.Scan() {
-				line := strings.TrimSpace(scanner.Text())
-
-				// ignore empty lines
-				if line == "" {
-					continue
-				}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
 
-				// strip comments
-				if strings.HasPrefix(line, "#") {
-					continue
-				}
+		// ignore empty lines
+		if line == "" {
+			continue
+		}
 
-				line = os.Expand(line, getenvOrDollar)
-				excludes = append(excludes, line)
-			}
-			return scanner.Err()
-		}()
-		if err != nil {
-			return nil, err
+		// strip comments
+		if strings.HasPrefix(line, "#") {
+			continue
 		}
+
+		line = os.Expand(line, getenvOrDollar)
+		excludes = append(excludes, line)
 	}
-	return excludes, nil
+	return excludes, scanner.Err()
 }
 
 // collectTargets returns a list of target files/dirs from several sources.
@@ -342,6 +478,14 @@ func collectTargets(opts BackupOptions, args []string) (targets []string, err er
 		}
 	}
 
+	for _, file := range opts.FilesFromRaw {
+		names, err := readFilenamesFromFileRaw(file)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, names...)
+	}
+
 	// merge files from files-from into normal args so we can reuse the normal
 	// args checks and have the ability to use both files-from and args at the
 	// same time
@@ -350,7 +494,11 @@ func collectTargets(opts BackupOptions, args []string) (targets []string, err er
 		return nil, errors.Fatal("nothing to backup, please specify target files/dirs")
 	}
 
-	targets = args
+	targets, err = uniqueTargets(args)
+	if err != nil {
+		return nil, err
+	}
+
 	targets, err = filterExisting(targets)
 	if err != nil {
 		return nil, err
@@ -422,6 +570,7 @@ func runBackup(opts BackupOptions, gopts GlobalOptions, term *termstatus.Termina
 		ScannerError(item string, fi os.FileInfo, err error) error
 		ReportTotal(item string, s archiver.ScanStats)
 		SetMinUpdatePause(d time.Duration)
+		SetDryRun()
 		Run(ctx context.Context) error
 		Error(item string, fi os.FileInfo, err error) error
 		Finish(snapshotID restic.ID)
@@ -472,8 +621,37 @@ func runBackup(opts BackupOptions, gopts GlobalOptions, term *termstatus.Termina
 		return err
 	}
 
+	if opts.DryRun {
+		repo.SetDryRun()
+		p.SetDryRun()
+	}
+
+	var targetFS fs.FS = fs.Local{}
+	if opts.UseFsSnapshot {
+		if err = fs.HasSufficientPrivilegesForVSS(); err != nil {
+			return errors.Fatalf("unable to use file system snapshots: %v", err)
+		}
+
+		errorHandler := func(item string, err error) error {
+			return p.Error(item, nil, err)
+		}
+		messageHandler := func(msg string, args ...interface{}) {
+			if !gopts.JSON {
+				p.P(msg, args...)
+			}
+		}
+
+		localVss := fs.NewLocalVss(errorHandler, messageHandler)
+		defer func() {
+			if err := localVss.DeleteSnapshots(); err != nil {
+				Warnf("unable to delete snapshots: %v\n", err)
+			}
+		}()
+		targetFS = localVss
+	}
+
 	// rejectByNameFuncs collect functions that can reject items from the backup based on path only
-	rejectByNameFuncs, err := collectRejectByNameFuncs(opts, repo, targets)
+	rejectByNameFuncs, err := collectRejectByNameFuncs(opts, repo, targetFS, targets)
 	if err != nil {
 		return err
 	}
@@ -519,7 +697,6 @@ func runBackup(opts BackupOptions, gopts GlobalOptions, term *termstatus.Termina
 		return true
 	}
 
-	var targetFS fs.FS = fs.Local{}
 	if opts.Stdin {
 		if !gopts.JSON {
 			p.V("read data from stdin")
@@ -545,7 +722,7 @@ func runBackup(opts BackupOptions, gopts GlobalOptions, term *termstatus.Termina
 	}
 	t.Go(func() error { return sc.Scan(t.Context(gopts.ctx), targets) })
 
-	arch := archiver.New(repo, targetFS, archiver.Options{})
+	arch := archiver.New(repo, targetFS, archiver.Options{FileReadConcurrency: opts.ReadConcurrency})
 	arch.SelectByName = selectByNameFilter
 	arch.Select = selectFilter
 	arch.WithAtime = opts.WithAtime
@@ -553,7 +730,14 @@ func runBackup(opts BackupOptions, gopts GlobalOptions, term *termstatus.Termina
 	arch.CompleteItem = p.CompleteItem
 	arch.StartFile = p.StartFile
 	arch.CompleteBlob = p.CompleteBlob
-	arch.IgnoreInode = opts.IgnoreInode
+	if opts.IgnoreInode {
+		// a changed inode number also changes the ctime, e.g. on FUSE file
+		// systems, so it is ignored as well
+		arch.ChangeIgnoreFlags |= archiver.ChangeIgnoreInode | archiver.ChangeIgnoreCtime
+	}
+	if opts.IgnoreCtime {
+		arch.ChangeIgnoreFlags |= archiver.ChangeIgnoreCtime
+	}
 
 	if parentSnapshotID == nil {
 		parentSnapshotID = &restic.ID{}
@@ -595,7 +779,14 @@ func runBackup(opts BackupOptions, gopts GlobalOptions, term *termstatus.Termina
 
 	p.Finish(id)
 	if !gopts.JSON {
-		p.P("snapshot %s saved\n", id.Str())
+		if opts.DryRun {
+			p.P("dry run, no snapshot saved\n")
+		} else {
+			p.P("snapshot %s saved\n", id.Str())
+		}
+		if stats := formatTransferStats(repo); stats != "" {
+			p.P("%s\n", stats)
+		}
 	}
 
 	// cleanly shutdown all running goroutines
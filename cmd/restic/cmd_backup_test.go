@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestReadFilenamesFromFileRaw(t *testing.T) {
+	tempDir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	filename := filepath.Join(tempDir, "files")
+	data := "foo\x00 bar with spaces \x00\x00#not a comment\x00new\nline\x00*.go\x00"
+	rtest.OK(t, ioutil.WriteFile(filename, []byte(data), 0600))
+
+	names, err := readFilenamesFromFileRaw(filename)
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"foo", " bar with spaces ", "#not a comment", "new\nline", "*.go"}, names)
+}
+
+func TestUniqueTargets(t *testing.T) {
+	targets := []string{"foo", "bar/", "./foo", "baz/../bar", "foo/x", "bar", ".//foo/./x/"}
+	// baz/../bar is not the same as bar if baz is a symlink
+	want := []string{"foo", "bar/", "baz/../bar", "foo/x"}
+	got, err := uniqueTargets(targets)
+	rtest.OK(t, err)
+	rtest.Equals(t, want, got)
+
+	wd, err := os.Getwd()
+	rtest.OK(t, err)
+
+	// relative and absolute paths for the same target
+	sep := string(filepath.Separator)
+	dotdot := wd + sep + "foo" + sep + ".." + sep + "foo"
+	got, err = uniqueTargets([]string{"foo", filepath.Join(wd, "foo"), dotdot})
+	rtest.OK(t, err)
+	rtest.Equals(t, []string{"foo", dotdot}, got)
+}
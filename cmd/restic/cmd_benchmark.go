@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/restic/chunker"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/crypto"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdBenchmark = &cobra.Command{
+	Use:   "benchmark [flags]",
+	Short: "Measure the performance of this machine and the repository",
+	Long: `
+The "benchmark" command measures how fast this machine can split data into
+chunks, hash and encrypt it. When a repository is given, it also measures how
+fast files with the size of pack files can be uploaded to and downloaded from
+the repository's backend with different numbers of concurrent connections.
+Afterwards, it prints recommendations for the options to use.
+
+The test files are stored next to the lock files of the repository with names
+starting with "benchmark-", which are ignored by all other operations. They are
+removed afterwards. Test files left over from an interrupted run are removed
+when the command runs the next time. The repository is locked exclusively
+while the command runs, so that two benchmarks cannot remove each other's test
+files.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBenchmark(benchmarkOptions, globalOptions, args)
+	},
+}
+
+// BenchmarkOptions collects all options for the benchmark command.
+type BenchmarkOptions struct {
+	DataSize       string
+	MaxConnections int
+}
+
+var benchmarkOptions BenchmarkOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdBenchmark)
+
+	f := cmdBenchmark.Flags()
+	f.StringVar(&benchmarkOptions.DataSize, "data-size", "32M", "upload and download `size` bytes for each number of connections")
+	f.IntVar(&benchmarkOptions.MaxConnections, "max-connections", 16, "test up to `n` concurrent connections to the backend")
+}
+
+// benchmarkPackSize is the minimal size of pack files written by the
+// repository, the backend is tested with files of this size.
+const benchmarkPackSize = 4 * 1024 * 1024
+
+// benchmarkPrefix is the prefix for the names of the test files. They are
+// saved as lock files, which check and prune ignore, and since the names are
+// not valid IDs, they are also skipped when looking for other locks.
+const benchmarkPrefix = "benchmark-"
+
+// benchmarkCPUDataSize is the amount of data used for the CPU benchmarks.
+const benchmarkCPUDataSize = 64 * 1024 * 1024
+
+// bytesPerSecond returns the rate for processing size bytes in d.
+func bytesPerSecond(size int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(size) / d.Seconds()
+}
+
+// benchmarkChunker measures how long it takes to split buf into chunks.
+func benchmarkChunker(buf []byte) (time.Duration, error) {
+	pol, err := chunker.RandomPolynomial()
+	if err != nil {
+		return 0, errors.Wrap(err, "RandomPolynomial")
+	}
+
+	start := time.Now()
+	chk := chunker.New(bytes.NewReader(buf), pol)
+	chunkBuf := make([]byte, chunker.MaxSize)
+	for {
+		_, err := chk.Next(chunkBuf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "Next")
+		}
+	}
+
+	return time.Since(start), nil
+}
+
+// benchmarkHash measures how long it takes to hash buf in blocks of the
+// average chunk size.
+func benchmarkHash(buf []byte) time.Duration {
+	start := time.Now()
+	for offset := 0; offset < len(buf); offset += chunker.MinSize {
+		restic.Hash(buf[offset : offset+chunker.MinSize])
+	}
+	return time.Since(start)
+}
+
+// benchmarkEncryption measures how long it takes to encrypt buf in blocks of
+// the average chunk size.
+func benchmarkEncryption(buf []byte) time.Duration {
+	key := crypto.NewRandomKey()
+	dst := make([]byte, 0, chunker.MinSize+crypto.Extension)
+
+	start := time.Now()
+	for offset := 0; offset < len(buf); offset += chunker.MinSize {
+		nonce := crypto.NewRandomNonce()
+		dst = key.Seal(dst[:0], nonce, buf[offset:offset+chunker.MinSize], nil)
+	}
+	return time.Since(start)
+}
+
+// benchmarkBackendResult is the result of uploading and downloading files
+// with a number of concurrent connections.
+type benchmarkBackendResult struct {
+	connections      int
+	upload, download time.Duration
+}
+
+// runConcurrently calls fn for all handles with the given number of
+// concurrent calls.
+func runConcurrently(ctx context.Context, handles []restic.Handle, connections int, fn func(context.Context, restic.Handle) error) (time.Duration, error) {
+	start := time.Now()
+
+	wg, ctx := errgroup.WithContext(ctx)
+	ch := make(chan restic.Handle)
+
+	wg.Go(func() error {
+		defer close(ch)
+		for _, h := range handles {
+			select {
+			case ch <- h:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	for i := 0; i < connections; i++ {
+		wg.Go(func() error {
+			for h := range ch {
+				err := fn(ctx, h)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	err := wg.Wait()
+	return time.Since(start), err
+}
+
+// benchmarkBackend uploads and downloads all handles with the given number of
+// concurrent connections.
+func benchmarkBackend(ctx context.Context, be restic.Backend, handles []restic.Handle, connections int, data []byte) (res benchmarkBackendResult, err error) {
+	res.connections = connections
+
+	res.upload, err = runConcurrently(ctx, handles, connections, func(ctx context.Context, h restic.Handle) error {
+		return be.Save(ctx, h, restic.NewByteReader(data))
+	})
+	if err != nil {
+		return res, err
+	}
+
+	res.download, err = runConcurrently(ctx, handles, connections, func(ctx context.Context, h restic.Handle) error {
+		return be.Load(ctx, h, 0, 0, func(rd io.Reader) error {
+			_, err := io.Copy(ioutil.Discard, rd)
+			return err
+		})
+	})
+	return res, err
+}
+
+// removeBenchmarkFiles removes all test files which exist. Removing a file
+// which does not exist is retried, so check for each file first.
+func removeBenchmarkFiles(ctx context.Context, be restic.Backend, handles []restic.Handle) error {
+	for _, h := range handles {
+		found, err := be.Test(ctx, h)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			continue
+		}
+
+		err = be.Remove(ctx, h)
+		if err != nil && !be.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeLeftoverBenchmarkFiles removes the test files of an earlier benchmark
+// which was interrupted, and returns the number of files removed.
+func removeLeftoverBenchmarkFiles(ctx context.Context, be restic.Backend) (int, error) {
+	var handles []restic.Handle
+	err := be.List(ctx, restic.LockFile, func(fi restic.FileInfo) error {
+		if strings.HasPrefix(fi.Name, benchmarkPrefix) {
+			handles = append(handles, restic.Handle{Type: restic.LockFile, Name: fi.Name})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, h := range handles {
+		err = be.Remove(ctx, h)
+		if err != nil && !be.IsNotExist(err) {
+			return 0, err
+		}
+	}
+	return len(handles), nil
+}
+
+// supportsConnections returns true if the backend has the extended option
+// "connections".
+func supportsConnections(scheme string) bool {
+	for _, opt := range options.List() {
+		if opt.Namespace == scheme && opt.Name == "connections" {
+			return true
+		}
+	}
+	return false
+}
+
+func runBenchmark(opts BenchmarkOptions, gopts GlobalOptions, args []string) error {
+	if len(args) > 0 {
+		return errors.Fatal("the benchmark command does not take arguments")
+	}
+
+	dataSize, err := parseSizeStr(opts.DataSize)
+	if err != nil {
+		return errors.Fatalf("invalid value for --data-size: %v", err)
+	}
+
+	files := int((dataSize + benchmarkPackSize - 1) / benchmarkPackSize)
+	if files < 1 {
+		files = 1
+	}
+
+	if opts.MaxConnections < 1 {
+		return errors.Fatal("--max-connections must be at least 1")
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	buf := make([]byte, benchmarkCPUDataSize)
+	_, _ = rand.New(rand.NewSource(time.Now().UnixNano())).Read(buf)
+
+	Printf("CPU (single core, %d cores available):\n", runtime.NumCPU())
+
+	chunking, err := benchmarkChunker(buf)
+	if err != nil {
+		return err
+	}
+	hashing := benchmarkHash(buf)
+	encryption := benchmarkEncryption(buf)
+
+	Printf("  chunking:     %12s\n", formatRate(benchmarkCPUDataSize, chunking))
+	Printf("  hashing:      %12s\n", formatRate(benchmarkCPUDataSize, hashing))
+	Printf("  encryption:   %12s\n", formatRate(benchmarkCPUDataSize, encryption))
+
+	// new data is chunked, hashed and encrypted, this runs in parallel on all
+	// cores
+	cpuRate := bytesPerSecond(benchmarkCPUDataSize, chunking+hashing+encryption) * float64(runtime.NumCPU())
+
+	if gopts.Repo == "" {
+		Printf("\nno repository given, skipping the backend benchmark\n")
+		return nil
+	}
+
+	loc, err := location.Parse(gopts.Repo)
+	if err != nil {
+		return err
+	}
+
+	// raise the connection limit of the backend so that all numbers of
+	// connections can be tested, unless the user has set a limit
+	connectionsOption := loc.Scheme + ".connections"
+	hasConnections := supportsConnections(loc.Scheme)
+	if _, ok := gopts.extended[connectionsOption]; hasConnections && !ok {
+		extended := make(options.Options)
+		for k, v := range gopts.extended {
+			extended[k] = v
+		}
+		extended[connectionsOption] = strconv.Itoa(opts.MaxConnections)
+		gopts.extended = extended
+	}
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	// the test files of an interrupted benchmark are removed below, which
+	// must not happen while another benchmark is running
+	lock, err := lockRepoExclusive(repo)
+	defer unlockRepo(lock)
+	if err != nil {
+		return err
+	}
+
+	be := repo.Backend()
+
+	leftover, err := removeLeftoverBenchmarkFiles(ctx, be)
+	if err != nil {
+		return err
+	}
+	if leftover > 0 {
+		Verbosef("removed %d test files left over from an interrupted benchmark\n", leftover)
+	}
+
+	var levels []int
+	for n := 1; n < opts.MaxConnections; n *= 2 {
+		levels = append(levels, n)
+	}
+	levels = append(levels, opts.MaxConnections)
+
+	// the file names are chosen in advance, so that they can be removed when
+	// the benchmark is interrupted
+	handles := make([][]restic.Handle, len(levels))
+	var all []restic.Handle
+	for i := range handles {
+		for j := 0; j < files; j++ {
+			h := restic.Handle{Type: restic.LockFile, Name: benchmarkPrefix + restic.NewRandomID().String()}
+			handles[i] = append(handles[i], h)
+			all = append(all, h)
+		}
+	}
+
+	var removeOnce sync.Once
+	remove := func() (err error) {
+		removeOnce.Do(func() {
+			err = removeBenchmarkFiles(context.Background(), be, all)
+		})
+		return err
+	}
+	AddCleanupHandler(remove)
+
+	data := buf[:benchmarkPackSize]
+	size := int64(files) * benchmarkPackSize
+
+	Printf("\n%v backend (%d files of %s for each test):\n", loc.Scheme, files, formatBytes(benchmarkPackSize))
+	Printf("  connections       upload     download\n")
+
+	var results []benchmarkBackendResult
+	for i, connections := range levels {
+		res, err := benchmarkBackend(ctx, be, handles[i], connections, data)
+		if err != nil {
+			_ = remove()
+			return err
+		}
+
+		err = removeBenchmarkFiles(ctx, be, handles[i])
+		if err != nil {
+			_ = remove()
+			return err
+		}
+
+		Printf("  %11d %12s %12s\n", connections, formatRate(uint64(size), res.upload), formatRate(uint64(size), res.download))
+		results = append(results, res)
+	}
+
+	if err = remove(); err != nil {
+		return err
+	}
+
+	// recommend the smallest number of connections which reaches 90% of the
+	// best upload rate, more connections only cause more load
+	var best float64
+	for _, res := range results {
+		if r := bytesPerSecond(size, res.upload); r > best {
+			best = r
+		}
+	}
+
+	recommended := results[len(results)-1]
+	for _, res := range results {
+		if bytesPerSecond(size, res.upload) >= 0.9*best {
+			recommended = res
+			break
+		}
+	}
+
+	Printf("\nrecommendations:\n")
+
+	if hasConnections {
+		Printf("  - use %d concurrent connections (-o %s=%d)\n", recommended.connections, connectionsOption, recommended.connections)
+	} else {
+		Printf("  - the number of connections cannot be configured for this backend, the best upload rate was reached with %d connections\n", recommended.connections)
+	}
+
+	if cpuRate < best {
+		Printf("  - backups of new data are limited by the CPU to about %s, the backend is faster\n", formatRate(uint64(cpuRate), time.Second))
+	} else {
+		Printf("  - backups of new data are limited by the upload rate of about %s, the CPU is faster\n", formatRate(uint64(best), time.Second))
+	}
+
+	// a file of the pack size is uploaded in the time it takes to send the
+	// data plus the latency of the request, which dominates for slow requests
+	single := results[0].upload / time.Duration(files)
+	transfer := time.Duration(float64(benchmarkPackSize) / best * float64(time.Second))
+	if single > 2*transfer {
+		Printf("  - uploading a single pack file of %s takes %v, mostly due to the latency of the backend, use more connections to hide it\n",
+			formatBytes(benchmarkPackSize), single.Round(time.Millisecond))
+	}
+
+	return nil
+}
@@ -19,6 +19,10 @@ var cmdCache = &cobra.Command{
 	Short: "Operate on local cache directories",
 	Long: `
 The "cache" command allows listing and cleaning local cache directories.
+
+Without flags, the cache directories of all repositories are listed with their
+size and the time they were last used. With --cleanup, the directories which
+were not used for --max-age days are removed, --clear removes all of them.
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -29,6 +33,7 @@ The "cache" command allows listing and cleaning local cache directories.
 // CacheOptions bundles all options for the snapshots command.
 type CacheOptions struct {
 	Cleanup bool
+	Clear   bool
 	MaxAge  uint
 	NoSize  bool
 }
@@ -40,6 +45,7 @@ func init() {
 
 	f := cmdCache.Flags()
 	f.BoolVar(&cacheOptions.Cleanup, "cleanup", false, "remove old cache directories")
+	f.BoolVar(&cacheOptions.Clear, "clear", false, "remove all cache directories")
 	f.UintVar(&cacheOptions.MaxAge, "max-age", 30, "max age in `days` for cache directories to be considered old")
 	f.BoolVar(&cacheOptions.NoSize, "no-size", false, "do not output the size of the cache directories")
 }
@@ -65,6 +71,26 @@ func runCache(opts CacheOptions, gopts GlobalOptions, args []string) error {
 		}
 	}
 
+	if opts.Clear && opts.Cleanup {
+		return errors.Fatal("--clear and --cleanup cannot be used together")
+	}
+
+	if opts.Clear {
+		dirs, err := cache.All(cachedir)
+		if err != nil {
+			return err
+		}
+
+		if len(dirs) == 0 {
+			Verbosef("no cache dirs found\n")
+			return nil
+		}
+
+		Verbosef("remove %d cache directories\n", len(dirs))
+		removeCacheDirs(cachedir, dirs)
+		return nil
+	}
+
 	if opts.Cleanup || gopts.CleanupCache {
 		oldDirs, err := cache.OlderThan(cachedir, time.Duration(opts.MaxAge)*24*time.Hour)
 		if err != nil {
@@ -77,15 +103,7 @@ func runCache(opts CacheOptions, gopts GlobalOptions, args []string) error {
 		}
 
 		Verbosef("remove %d old cache directories\n", len(oldDirs))
-
-		for _, item := range oldDirs {
-			dir := filepath.Join(cachedir, item.Name())
-			err = fs.RemoveAll(dir)
-			if err != nil {
-				Warnf("unable to remove %v: %v\n", dir, err)
-			}
-		}
-
+		removeCacheDirs(cachedir, oldDirs)
 		return nil
 	}
 
@@ -149,6 +167,17 @@ func runCache(opts CacheOptions, gopts GlobalOptions, args []string) error {
 	return nil
 }
 
+// removeCacheDirs removes the cache directories dirs below cachedir.
+func removeCacheDirs(cachedir string, dirs []os.FileInfo) {
+	for _, item := range dirs {
+		dir := filepath.Join(cachedir, item.Name())
+		err := fs.RemoveAll(dir)
+		if err != nil {
+			Warnf("unable to remove %v: %v\n", dir, err)
+		}
+	}
+}
+
 func dirSize(path string) (int64, error) {
 	var size int64
 	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
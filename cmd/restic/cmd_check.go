@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -50,7 +52,7 @@ func init() {
 
 	f := cmdCheck.Flags()
 	f.BoolVar(&checkOptions.ReadData, "read-data", false, "read all data blobs")
-	f.StringVar(&checkOptions.ReadDataSubset, "read-data-subset", "", "read subset n of m data packs (format: `n/m`)")
+	f.StringVar(&checkOptions.ReadDataSubset, "read-data-subset", "", "read a `subset` of data packs, specified as 'n/t' for a specific part, or either 'x%' or a size with a suffix K, M, G or T for a subset which changes daily")
 	f.BoolVar(&checkOptions.CheckUnused, "check-unused", false, "find unused blobs")
 	f.BoolVar(&checkOptions.WithCache, "with-cache", false, "use the cache")
 }
@@ -59,7 +61,22 @@ func checkFlags(opts CheckOptions) error {
 	if opts.ReadData && opts.ReadDataSubset != "" {
 		return errors.Fatalf("check flags --read-data and --read-data-subset cannot be used together")
 	}
-	if opts.ReadDataSubset != "" {
+	switch {
+	case opts.ReadDataSubset == "":
+
+	case strings.HasSuffix(opts.ReadDataSubset, "%"):
+		percentage, err := parsePercentage(opts.ReadDataSubset)
+		if err != nil || percentage <= 0 || percentage > 100 {
+			return errors.Fatalf("check flag --read-data-subset=x%% must be a percentage greater than 0 and at most 100, e.g. --read-data-subset=2.5%%")
+		}
+
+	case !strings.Contains(opts.ReadDataSubset, "/"):
+		size, err := parseSizeStr(opts.ReadDataSubset)
+		if err != nil || size == 0 {
+			return errors.Fatalf("check flag --read-data-subset must be n/t, a percentage or a size, e.g. --read-data-subset=1/2, 10%% or 50G")
+		}
+
+	default:
 		dataSubset, err := stringToIntSlice(opts.ReadDataSubset)
 		if err != nil || len(dataSubset) != 2 {
 			return errors.Fatalf("check flag --read-data-subset must have two positive integer values, e.g. --read-data-subset=1/2")
@@ -75,9 +92,112 @@ func checkFlags(opts CheckOptions) error {
 	return nil
 }
 
-// See doReadData in runCheck below for why this is 256.
+// See selectPacksByBucket below for why this is 256.
 const totalBucketsMax = 256
 
+// parsePercentage parses a percentage such as "2.5%".
+func parsePercentage(s string) (float64, error) {
+	if !strings.HasSuffix(s, "%") {
+		return 0, errors.Errorf("invalid percentage %q", s)
+	}
+
+	p, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid percentage %q", s)
+	}
+	return p, nil
+}
+
+// selectPacksByBucket returns the packs in group bucket out of totalBuckets
+// groups. The groups are determined by the first byte of the pack ID.
+func selectPacksByBucket(allPacks restic.IDSet, bucket, totalBuckets uint) restic.IDSet {
+	packs := restic.NewIDSet()
+	for pack := range allPacks {
+		// If we ever check more than the first byte
+		// of pack, update totalBucketsMax.
+		if (uint(pack[0]) % totalBuckets) == (bucket - 1) {
+			packs.Insert(pack)
+		}
+	}
+	return packs
+}
+
+// selectPacksByPercentage returns a subset of the packs with the given
+// percentage of all packs, at least one pack is selected. The packs are
+// ordered by their ID, and the subsets for consecutive values of run follow
+// each other, so that all packs are selected eventually.
+func selectPacksByPercentage(allPacks restic.IDSet, percentage float64, run int64) restic.IDSet {
+	ids := allPacks.List()
+	count := int(math.Ceil(float64(len(ids)) * percentage / 100))
+	if count > len(ids) {
+		count = len(ids)
+	}
+
+	packs := restic.NewIDSet()
+	if count == 0 {
+		return packs
+	}
+
+	start := int((run * int64(count)) % int64(len(ids)))
+	for i := 0; i < count; i++ {
+		packs.Insert(ids[(start+i)%len(ids)])
+	}
+	return packs
+}
+
+// selectPacksBySize returns a subset of the packs with a total size of at most
+// maxSize, but at least one pack. The packs are ordered by their ID, and the
+// subsets for consecutive values of run follow each other, so that all packs
+// are selected eventually.
+func selectPacksBySize(allPacks map[restic.ID]int64, maxSize int64, run int64) restic.IDSet {
+	ids := make(restic.IDs, 0, len(allPacks))
+	var total int64
+	for id, size := range allPacks {
+		ids = append(ids, id)
+		total += size
+	}
+	sort.Sort(ids)
+
+	packs := restic.NewIDSet()
+	if total == 0 {
+		return packs
+	}
+
+	// start with the pack at the offset where the subset of the previous
+	// run ended
+	_, frac := math.Modf(float64(run) * float64(maxSize) / float64(total))
+	offset := int64(frac * float64(total))
+	start := 0
+	var pos int64
+	for i, id := range ids {
+		if pos >= offset {
+			start = i
+			break
+		}
+		pos += allPacks[id]
+	}
+
+	// the first pack is always selected, even if it is larger than maxSize.
+	// Packs which don't fit are skipped, so that smaller packs can still use
+	// the remaining size.
+	var size int64
+	for i := 0; i < len(ids) && size < maxSize; i++ {
+		id := ids[(start+i)%len(ids)]
+		if i > 0 && size+allPacks[id] > maxSize {
+			continue
+		}
+		packs.Insert(id)
+		size += allPacks[id]
+	}
+	return packs
+}
+
+// checkRun returns the number of the current run for selecting a subset of
+// the packs, it is increased once per day.
+func checkRun() int64 {
+	return time.Now().Unix() / (24 * 60 * 60)
+}
+
 // stringToIntSlice converts string to []uint, using '/' as element separator
 func stringToIntSlice(param string) (split []uint, err error) {
 	if param == "" {
@@ -108,6 +228,10 @@ func newReadProgress(gopts GlobalOptions, todo restic.Stat) *restic.Progress {
 			formatPercent(s.Blobs, todo.Blobs),
 			s.Blobs, todo.Blobs)
 
+		if eta := estimateRemaining(s.Blobs, todo.Blobs, d); eta > 0 {
+			status += fmt.Sprintf(", ETA %s", formatSeconds(eta))
+		}
+
 		if w := stdoutTerminalWidth(); w > 0 {
 			if len(status) > w {
 				max := w - len(status) - 4
@@ -260,23 +384,9 @@ func runCheck(opts CheckOptions, gopts GlobalOptions, args []string) error {
 		}
 	}
 
-	doReadData := func(bucket, totalBuckets uint) {
-		packs := restic.IDSet{}
-		for pack := range chkr.GetPacks() {
-			// If we ever check more than the first byte
-			// of pack, update totalBucketsMax.
-			if (uint(pack[0]) % totalBuckets) == (bucket - 1) {
-				packs.Insert(pack)
-			}
-		}
+	doReadData := func(packs restic.IDSet) {
 		packCount := uint64(len(packs))
 
-		if packCount < chkr.CountPacks() {
-			Verbosef(fmt.Sprintf("read group #%d of %d data packs (out of total %d packs in %d groups)\n", bucket, packCount, chkr.CountPacks(), totalBuckets))
-		} else {
-			Verbosef("read all data\n")
-		}
-
 		p := newReadProgress(gopts, restic.Stat{Blobs: packCount})
 		errChan := make(chan error)
 
@@ -290,10 +400,42 @@ func runCheck(opts CheckOptions, gopts GlobalOptions, args []string) error {
 
 	switch {
 	case opts.ReadData:
-		doReadData(1, 1)
+		Verbosef("read all data\n")
+		doReadData(chkr.GetPacks())
+
+	case strings.HasSuffix(opts.ReadDataSubset, "%"):
+		percentage, _ := parsePercentage(opts.ReadDataSubset)
+		packs := selectPacksByPercentage(chkr.GetPacks(), percentage, checkRun())
+		Verbosef("read %d data packs (%.1f%% out of total %d packs)\n", len(packs), percentage, chkr.CountPacks())
+		doReadData(packs)
+
+	case opts.ReadDataSubset != "" && !strings.Contains(opts.ReadDataSubset, "/"):
+		maxSize, _ := parseSizeStr(opts.ReadDataSubset)
+
+		sizes := make(map[restic.ID]int64)
+		err = repo.List(gopts.ctx, restic.DataFile, func(id restic.ID, size int64) error {
+			if chkr.GetPacks().Has(id) {
+				sizes[id] = size
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		packs := selectPacksBySize(sizes, int64(maxSize), checkRun())
+		Verbosef("read %d data packs with at most %s (out of total %d packs)\n", len(packs), formatBytes(maxSize), chkr.CountPacks())
+		doReadData(packs)
+
 	case opts.ReadDataSubset != "":
 		dataSubset, _ := stringToIntSlice(opts.ReadDataSubset)
-		doReadData(dataSubset[0], dataSubset[1])
+		packs := selectPacksByBucket(chkr.GetPacks(), dataSubset[0], dataSubset[1])
+		if uint64(len(packs)) < chkr.CountPacks() {
+			Verbosef("read group #%d of %d data packs (out of total %d packs in %d groups)\n", dataSubset[0], len(packs), chkr.CountPacks(), dataSubset[1])
+		} else {
+			Verbosef("read all data\n")
+		}
+		doReadData(packs)
 	}
 
 	if errorsFound {
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestCheckFlagsReadDataSubset(t *testing.T) {
+	var tests = []struct {
+		subset string
+		valid  bool
+	}{
+		{"", true},
+		{"1/5", true},
+		{"5/5", true},
+		{"0/5", false},
+		{"6/5", false},
+		{"1/257", false},
+		{"1/2/3", false},
+		{"10%", true},
+		{"2.5%", true},
+		{"100%", true},
+		{"0%", false},
+		{"101%", false},
+		{"x%", false},
+		{"50G", true},
+		{"100", true},
+		{"0", false},
+		{"foo", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.subset, func(t *testing.T) {
+			err := checkFlags(CheckOptions{ReadDataSubset: test.subset})
+			if test.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !test.valid && err == nil {
+				t.Errorf("no error for invalid subset %q", test.subset)
+			}
+		})
+	}
+}
+
+func TestSelectPacksByBucket(t *testing.T) {
+	allPacks := restic.NewIDSet()
+	for i := 0; i < 100; i++ {
+		allPacks.Insert(restic.NewRandomID())
+	}
+
+	// all groups together must contain every pack exactly once
+	seen := restic.NewIDSet()
+	for bucket := uint(1); bucket <= 5; bucket++ {
+		for id := range selectPacksByBucket(allPacks, bucket, 5) {
+			rtest.Assert(t, !seen.Has(id), "pack %v selected for several groups", id.Str())
+			seen.Insert(id)
+		}
+	}
+	rtest.Equals(t, allPacks, seen)
+}
+
+func TestSelectPacksByPercentage(t *testing.T) {
+	allPacks := restic.NewIDSet()
+	for i := 0; i < 200; i++ {
+		allPacks.Insert(restic.NewRandomID())
+	}
+
+	packs := selectPacksByPercentage(allPacks, 10, 0)
+	rtest.Equals(t, 20, len(packs))
+	for id := range packs {
+		rtest.Assert(t, allPacks.Has(id), "unknown pack %v selected", id.Str())
+	}
+
+	// the selection is deterministic
+	rtest.Equals(t, packs, selectPacksByPercentage(allPacks, 10, 0))
+
+	// consecutive runs select all packs
+	seen := restic.NewIDSet()
+	for run := int64(100); run < 110; run++ {
+		packs := selectPacksByPercentage(allPacks, 10, run)
+		rtest.Equals(t, 20, len(packs))
+		for id := range packs {
+			rtest.Assert(t, !seen.Has(id), "pack %v selected twice", id.Str())
+			seen.Insert(id)
+		}
+	}
+	rtest.Equals(t, allPacks, seen)
+
+	rtest.Equals(t, 1, len(selectPacksByPercentage(allPacks, 0.1, 0)))
+	rtest.Equals(t, allPacks, selectPacksByPercentage(allPacks, 100, 0))
+}
+
+func TestSelectPacksBySize(t *testing.T) {
+	allPacks := make(map[restic.ID]int64)
+	for i := 0; i < 100; i++ {
+		allPacks[restic.NewRandomID()] = 1000
+	}
+
+	packs := selectPacksBySize(allPacks, 10500, 0)
+	rtest.Equals(t, 10, len(packs))
+	for id := range packs {
+		_, ok := allPacks[id]
+		rtest.Assert(t, ok, "unknown pack %v selected", id.Str())
+	}
+
+	// the selection is deterministic
+	rtest.Equals(t, packs, selectPacksBySize(allPacks, 10500, 0))
+
+	// consecutive runs select all packs
+	seen := restic.NewIDSet()
+	for run := int64(0); run < 10; run++ {
+		for id := range selectPacksBySize(allPacks, 10000, run) {
+			seen.Insert(id)
+		}
+	}
+	rtest.Equals(t, len(allPacks), len(seen))
+
+	// at least one pack is selected
+	rtest.Equals(t, 1, len(selectPacksBySize(allPacks, 999, 0)))
+	rtest.Equals(t, 100, len(selectPacksBySize(allPacks, 1000000, 0)))
+}
+
+func TestSelectPacksBySizeLargePack(t *testing.T) {
+	ids := make(restic.IDs, 0, 10)
+	for i := 0; i < 10; i++ {
+		ids = append(ids, restic.NewRandomID())
+	}
+	sort.Sort(ids)
+
+	// the pack at the offset of the first run is larger than maxSize
+	allPacks := make(map[restic.ID]int64)
+	for _, id := range ids {
+		allPacks[id] = 1000
+	}
+	allPacks[ids[0]] = 5000
+
+	packs := selectPacksBySize(allPacks, 2500, 0)
+	rtest.Assert(t, packs.Has(ids[0]), "large pack at the offset was not selected")
+	rtest.Equals(t, 1, len(packs))
+
+	// smaller packs are used to fill up the size
+	allPacks[ids[0]] = 1000
+	allPacks[ids[1]] = 5000
+	packs = selectPacksBySize(allPacks, 2500, 0)
+	rtest.Equals(t, restic.NewIDSet(ids[0], ids[2]), packs)
+}
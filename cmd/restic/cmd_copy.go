@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdCopy = &cobra.Command{
+	Use:   "copy [flags] [snapshotID ...]",
+	Short: "Copy snapshots from one repository to another",
+	Long: `
+The "copy" command copies one or more snapshots from the repository given
+with --repo to the destination repository given with --repo2.
+
+All data is decrypted and encrypted again with the key of the destination
+repository, blobs which are already stored there are not copied again.
+Snapshots which have been copied before are skipped.
+
+When no snapshot ID is given, all snapshots matching the host, tag and path
+filter criteria are copied.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCopy(copyOptions, globalOptions, args)
+	},
+}
+
+// CopyOptions bundles all options for the copy command.
+type CopyOptions struct {
+	secondaryRepoOptions
+
+	Host  string
+	Tags  restic.TagLists
+	Paths []string
+}
+
+var copyOptions CopyOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdCopy)
+
+	f := cmdCopy.Flags()
+	initSecondaryRepoOptions(f, &copyOptions.secondaryRepoOptions, "destination", "destination repository to copy snapshots to")
+
+	f.StringVarP(&copyOptions.Host, "host", "H", "", "only consider snapshots for this `host`, when no snapshot ID is given")
+	f.Var(&copyOptions.Tags, "tag", "only consider snapshots which include this `taglist`, when no snapshot ID is given")
+	f.StringArrayVar(&copyOptions.Paths, "path", nil, "only consider snapshots which include this (absolute) `path`, when no snapshot ID is given")
+}
+
+func runCopy(opts CopyOptions, gopts GlobalOptions, args []string) error {
+	dstGopts, err := fillSecondaryGlobalOpts(opts.secondaryRepoOptions, gopts, "destination")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	srcRepo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	dstRepo, err := OpenRepository(dstGopts)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.NoLock {
+		srcLock, err := lockRepo(srcRepo)
+		defer unlockRepo(srcLock)
+		if err != nil {
+			return err
+		}
+	}
+
+	dstLock, err := lockRepo(dstRepo)
+	defer unlockRepo(dstLock)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("loading indexes\n")
+	if err = srcRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+	if err = dstRepo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	// collect the IDs of the snapshots in the source repository which have
+	// already been copied to the destination repository
+	copied := restic.NewIDSet()
+	for sn := range FindFilteredSnapshots(ctx, dstRepo, "", nil, nil, nil) {
+		if sn.Original != nil {
+			copied.Insert(*sn.Original)
+		}
+		copied.Insert(*sn.ID())
+	}
+
+	c := &copier{
+		src:    srcRepo,
+		dst:    dstRepo,
+		copied: restic.NewBlobSet(),
+	}
+
+	for sn := range FindFilteredSnapshots(ctx, srcRepo, opts.Host, opts.Tags, opts.Paths, args) {
+		if copied.Has(*sn.ID()) || (sn.Original != nil && copied.Has(*sn.Original)) {
+			Verbosef("skipping snapshot %s, it has already been copied\n", sn.ID().Str())
+			continue
+		}
+
+		Verbosef("copying snapshot %s of %v at %s\n", sn.ID().Str(), sn.Paths, sn.Time)
+		if err = c.copyTree(ctx, *sn.Tree); err != nil {
+			return err
+		}
+
+		if err = dstRepo.Flush(ctx); err != nil {
+			return err
+		}
+
+		if err = dstRepo.SaveIndex(ctx); err != nil {
+			return err
+		}
+
+		// remember which snapshot this one is a copy of
+		if sn.Original == nil {
+			sn.Original = sn.ID()
+		}
+
+		id, err := dstRepo.SaveJSONUnpacked(ctx, restic.SnapshotFile, sn)
+		if err != nil {
+			return err
+		}
+		Verbosef("snapshot %s saved\n", id.Str())
+	}
+
+	return nil
+}
+
+// copier copies blobs from one repository to another.
+type copier struct {
+	src, dst *repository.Repository
+	buf      []byte
+
+	// copied contains all blobs which have been copied, they may not be part
+	// of the index of the destination repository yet
+	copied restic.BlobSet
+}
+
+// copyBlob copies the blob with the given type and ID unless it is already
+// stored in the destination repository.
+func (c *copier) copyBlob(ctx context.Context, t restic.BlobType, id restic.ID) error {
+	h := restic.BlobHandle{ID: id, Type: t}
+	if c.copied.Has(h) || c.dst.Index().Has(id, t) {
+		return nil
+	}
+
+	size, found := c.src.LookupBlobSize(id, t)
+	if !found {
+		return errors.Errorf("blob %v not found in the source repository", h)
+	}
+
+	if cap(c.buf) < restic.CiphertextLength(int(size)) {
+		c.buf = restic.NewBlobBuffer(int(size))
+	}
+
+	n, err := c.src.LoadBlob(ctx, t, id, c.buf[:cap(c.buf)])
+	if err != nil {
+		return err
+	}
+
+	debug.Log("copy %v (%d bytes)", h, n)
+	_, err = c.dst.SaveBlob(ctx, t, c.buf[:n], id)
+	if err != nil {
+		return err
+	}
+
+	c.copied.Insert(h)
+	return nil
+}
+
+// copyTree copies the tree with the given ID and everything it references.
+func (c *copier) copyTree(ctx context.Context, id restic.ID) error {
+	if c.copied.Has(restic.BlobHandle{ID: id, Type: restic.TreeBlob}) || c.dst.Index().Has(id, restic.TreeBlob) {
+		return nil
+	}
+
+	tree, err := c.src.LoadTree(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, node := range tree.Nodes {
+		switch {
+		case node.Type == "file":
+			for _, blobID := range node.Content {
+				if err = c.copyBlob(ctx, restic.DataBlob, blobID); err != nil {
+					return err
+				}
+			}
+
+		case node.Subtree != nil:
+			if err = c.copyTree(ctx, *node.Subtree); err != nil {
+				return err
+			}
+		}
+	}
+
+	// save the tree after all blobs it references
+	return c.copyBlob(ctx, restic.TreeBlob, id)
+}
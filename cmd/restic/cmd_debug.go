@@ -3,11 +3,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"sort"
 
 	"github.com/spf13/cobra"
 
@@ -34,9 +37,25 @@ is used for debugging purposes only.`,
 	},
 }
 
+var cmdDebugExamine = &cobra.Command{
+	Use:   "examine pack-ID...",
+	Short: "Examine data files",
+	Long: `
+The "examine" command downloads data files from the repository and prints the
+blobs they contain, with the offset, length and type of each blob as listed in
+the header of the file. Each blob is decrypted and its hash is compared to the
+ID. If the header cannot be read, the blobs which the index lists for the file
+are examined instead. It is used for debugging purposes only.`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runDebugExamine(globalOptions, args)
+	},
+}
+
 func init() {
 	cmdRoot.AddCommand(cmdDebug)
 	cmdDebug.AddCommand(cmdDebugDump)
+	cmdDebug.AddCommand(cmdDebugExamine)
 }
 
 func prettyPrintJSON(wr io.Writer, item interface{}) error {
@@ -170,3 +189,149 @@ func runDebugDump(gopts GlobalOptions, args []string) error {
 		return errors.Fatalf("no such type %q", tpe)
 	}
 }
+
+// examinePack loads the data file id and checks all blobs in it.
+func examinePack(ctx context.Context, repo *repository.Repository, id restic.ID) error {
+	h := restic.Handle{Type: restic.DataFile, Name: id.String()}
+
+	var buf []byte
+	err := repo.Backend().Load(ctx, h, 0, 0, func(rd io.Reader) (ierr error) {
+		buf, ierr = ioutil.ReadAll(rd)
+		return ierr
+	})
+	if err != nil {
+		return err
+	}
+
+	Printf("examining pack %v, %d bytes\n", id, len(buf))
+
+	if hash := restic.Hash(buf); !hash.Equal(id) {
+		Printf("  hash does not match the file name: %v\n", hash)
+	}
+
+	blobs, err := pack.List(repo.Key(), bytes.NewReader(buf), int64(len(buf)))
+	if err != nil {
+		Printf("  unable to read the header: %v\n", err)
+		Printf("  examining the blobs listed in the index instead\n")
+
+		blobs = nil
+		for pb := range repo.Index().Each(ctx) {
+			if pb.PackID.Equal(id) {
+				blobs = append(blobs, pb.Blob)
+			}
+		}
+
+		if len(blobs) == 0 {
+			Printf("  the index does not list any blobs for this pack\n")
+			return nil
+		}
+
+		sort.Slice(blobs, func(i, j int) bool {
+			return blobs[i].Offset < blobs[j].Offset
+		})
+	}
+
+	Printf("  %d blobs:\n", len(blobs))
+
+	var damaged int
+	for _, blob := range blobs {
+		status := examineBlob(repo, buf, blob)
+		if status != "ok" {
+			damaged++
+		}
+
+		var indexed string
+		if list, found := repo.Index().Lookup(blob.ID, blob.Type); found {
+			for _, pb := range list {
+				if pb.PackID.Equal(id) {
+					indexed = "indexed"
+					break
+				}
+			}
+			if indexed == "" {
+				indexed = "indexed in another pack"
+			}
+		} else {
+			indexed = "not indexed"
+		}
+
+		Printf("    %v blob %v, offset %-8d length %-8d %v, %v\n",
+			blob.Type, blob.ID, blob.Offset, blob.Length, status, indexed)
+	}
+
+	if damaged > 0 {
+		Printf("  %d blobs are damaged\n", damaged)
+	}
+
+	return nil
+}
+
+// examineBlob decrypts the blob from the pack in buf and compares its hash to
+// the ID. It returns "ok" or a description of the problem.
+func examineBlob(repo *repository.Repository, buf []byte, blob restic.Blob) string {
+	if uint(len(buf)) < blob.Offset+blob.Length {
+		return "beyond the end of the file"
+	}
+
+	ciphertext := buf[blob.Offset : blob.Offset+blob.Length]
+	if len(ciphertext) < repo.Key().NonceSize() {
+		return "too short"
+	}
+
+	nonce, ciphertext := ciphertext[:repo.Key().NonceSize()], ciphertext[repo.Key().NonceSize():]
+	plaintext, err := repo.Key().Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return fmt.Sprintf("decryption failed: %v", err)
+	}
+
+	if hash := restic.Hash(plaintext); !hash.Equal(blob.ID) {
+		return fmt.Sprintf("hash does not match: %v", hash.Str())
+	}
+
+	return "ok"
+}
+
+func runDebugExamine(gopts GlobalOptions, args []string) error {
+	if len(args) == 0 {
+		return errors.Fatal("no pack IDs specified")
+	}
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.NoLock {
+		lock, err := lockRepo(repo)
+		defer unlockRepo(lock)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = repo.LoadIndex(gopts.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, arg := range args {
+		name, err := restic.Find(repo.Backend(), restic.DataFile, arg)
+		if err != nil {
+			Warnf("error: %v: %v\n", arg, err)
+			continue
+		}
+
+		id, err := restic.ParseID(name)
+		if err != nil {
+			Warnf("error: %v: %v\n", arg, err)
+			continue
+		}
+
+		err = examinePack(gopts.ctx, repo, id)
+		if err != nil {
+			Warnf("error for pack %v: %v\n", id.Str(), err)
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"path"
 	"reflect"
 	"sort"
@@ -26,6 +27,9 @@ directory:
 * U  The metadata (access mode, timestamps, ...) for the item was updated
 * M  The file's content was modified
 * T  The type was changed, e.g. a file was made a symlink
+
+With --json, each change is printed as a JSON object on a separate line,
+followed by an object with the statistics.
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -58,15 +62,31 @@ func loadSnapshot(ctx context.Context, repo *repository.Repository, desc string)
 
 // Comparer collects all things needed to compare two snapshots.
 type Comparer struct {
-	repo restic.Repository
-	opts DiffOptions
+	repo        restic.Repository
+	opts        DiffOptions
+	printChange func(change *Change)
+}
+
+// Change is a single change of an item between two snapshots.
+type Change struct {
+	MessageType string `json:"message_type"` // "change"
+	Path        string `json:"path"`
+	Modifier    string `json:"modifier"`
+}
+
+// NewChange returns a change with the given modifier for the item at path.
+func NewChange(path string, mode string) *Change {
+	return &Change{MessageType: "change", Path: path, Modifier: mode}
 }
 
 // DiffStat collects stats for all types of items.
 type DiffStat struct {
-	Files, Dirs, Others  int
-	DataBlobs, TreeBlobs int
-	Bytes                uint64
+	Files     int    `json:"files"`
+	Dirs      int    `json:"directories"`
+	Others    int    `json:"others"`
+	DataBlobs int    `json:"data_blobs"`
+	TreeBlobs int    `json:"tree_blobs"`
+	Bytes     uint64 `json:"bytes"`
 }
 
 // Add adds stats information for node to s.
@@ -111,17 +131,23 @@ func addBlobs(bs restic.BlobSet, node *restic.Node) {
 
 // DiffStats collects the differences between two snapshots.
 type DiffStats struct {
-	ChangedFiles            int
-	Added                   DiffStat
-	Removed                 DiffStat
-	BlobsBefore, BlobsAfter restic.BlobSet
+	MessageType             string         `json:"message_type"` // "statistics"
+	SourceSnapshot          string         `json:"source_snapshot"`
+	TargetSnapshot          string         `json:"target_snapshot"`
+	ChangedFiles            int            `json:"changed_files"`
+	Added                   DiffStat       `json:"added"`
+	Removed                 DiffStat       `json:"removed"`
+	BlobsBefore, BlobsAfter restic.BlobSet `json:"-"`
 }
 
 // NewDiffStats creates new stats for a diff run.
-func NewDiffStats() *DiffStats {
+func NewDiffStats(sourceSnapshot, targetSnapshot string) *DiffStats {
 	return &DiffStats{
-		BlobsBefore: restic.NewBlobSet(),
-		BlobsAfter:  restic.NewBlobSet(),
+		MessageType:    "statistics",
+		SourceSnapshot: sourceSnapshot,
+		TargetSnapshot: targetSnapshot,
+		BlobsBefore:    restic.NewBlobSet(),
+		BlobsAfter:     restic.NewBlobSet(),
 	}
 }
 
@@ -157,7 +183,7 @@ func (c *Comparer) printDir(ctx context.Context, mode string, stats *DiffStat, b
 		if node.Type == "dir" {
 			name += "/"
 		}
-		Printf("%-5s%v\n", mode, name)
+		c.printChange(NewChange(name, mode))
 		stats.Add(node)
 		addBlobs(blobs, node)
 
@@ -239,7 +265,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStats, prefix string
 			}
 
 			if mod != "" {
-				Printf("%-5s%v\n", mod, name)
+				c.printChange(NewChange(name, mod))
 			}
 
 			if node1.Type == "dir" && node2.Type == "dir" {
@@ -253,7 +279,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStats, prefix string
 			if node1.Type == "dir" {
 				prefix += "/"
 			}
-			Printf("%-5s%v\n", "-", prefix)
+			c.printChange(NewChange(prefix, "-"))
 			stats.Removed.Add(node1)
 
 			if node1.Type == "dir" {
@@ -267,7 +293,7 @@ func (c *Comparer) diffTree(ctx context.Context, stats *DiffStats, prefix string
 			if node2.Type == "dir" {
 				prefix += "/"
 			}
-			Printf("%-5s%v\n", "+", prefix)
+			c.printChange(NewChange(prefix, "+"))
 			stats.Added.Add(node2)
 
 			if node2.Type == "dir" {
@@ -317,7 +343,9 @@ func runDiff(opts DiffOptions, gopts GlobalOptions, args []string) error {
 		return err
 	}
 
-	Verbosef("comparing snapshot %v to %v:\n\n", sn1.ID().Str(), sn2.ID().Str())
+	if !gopts.JSON {
+		Verbosef("comparing snapshot %v to %v:\n\n", sn1.ID().Str(), sn2.ID().Str())
+	}
 
 	if sn1.Tree == nil {
 		return errors.Errorf("snapshot %v has nil tree", sn1.ID().Str())
@@ -329,10 +357,23 @@ func runDiff(opts DiffOptions, gopts GlobalOptions, args []string) error {
 
 	c := &Comparer{
 		repo: repo,
-		opts: diffOptions,
+		opts: opts,
+		printChange: func(change *Change) {
+			Printf("%-5s%v\n", change.Modifier, change.Path)
+		},
 	}
 
-	stats := NewDiffStats()
+	if gopts.JSON {
+		enc := json.NewEncoder(gopts.stdout)
+		c.printChange = func(change *Change) {
+			err := enc.Encode(change)
+			if err != nil {
+				Warnf("JSON encode failed: %v\n", err)
+			}
+		}
+	}
+
+	stats := NewDiffStats(sn1.ID().String(), sn2.ID().String())
 
 	err = c.diffTree(ctx, stats, "/", *sn1.Tree, *sn2.Tree)
 	if err != nil {
@@ -343,6 +384,14 @@ func runDiff(opts DiffOptions, gopts GlobalOptions, args []string) error {
 	updateBlobs(repo, stats.BlobsBefore.Sub(both), &stats.Removed)
 	updateBlobs(repo, stats.BlobsAfter.Sub(both), &stats.Added)
 
+	if gopts.JSON {
+		err := json.NewEncoder(gopts.stdout).Encode(stats)
+		if err != nil {
+			Warnf("JSON encode failed: %v\n", err)
+		}
+		return nil
+	}
+
 	Printf("\n")
 	Printf("Files:       %5d new, %5d removed, %5d changed\n", stats.Added.Files, stats.Removed.Files, stats.ChangedFiles)
 	Printf("Dirs:        %5d new, %5d removed\n", stats.Added.Dirs, stats.Removed.Dirs)
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	"github.com/restic/restic/internal/walker"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdExport = &cobra.Command{
+	Use:   "export [flags] snapshotID",
+	Short: "Export a snapshot as a tar or zip archive",
+	Long: `
+The "export" command writes the contents of a snapshot to a tar or zip
+archive, so that it can be extracted without restic. The archive is written
+to stdout unless a file is given with --output.
+
+Tar archives keep the permissions, owners, modification times, symlinks and
+hard links of the files. Zip archives cannot store owners and hard links,
+hard linked files are stored once for each link.
+
+The special snapshot "latest" can be used to export the latest snapshot in the
+repository.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExport(exportOptions, globalOptions, args)
+	},
+}
+
+// ExportOptions collects all options for the export command.
+type ExportOptions struct {
+	Format string
+	Output string
+
+	Host  string
+	Paths []string
+	Tags  restic.TagLists
+}
+
+var exportOptions ExportOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdExport)
+
+	f := cmdExport.Flags()
+	f.StringVar(&exportOptions.Format, "format", "tar", "write an archive in `format` (tar, zip)")
+	f.StringVar(&exportOptions.Output, "output", "", "write the archive to `file` instead of stdout")
+
+	f.StringVarP(&exportOptions.Host, "host", "H", "", `only consider snapshots for this host when the snapshot ID is "latest"`)
+	f.Var(&exportOptions.Tags, "tag", "only consider snapshots which include this `taglist` for snapshot ID \"latest\"")
+	f.StringArrayVar(&exportOptions.Paths, "path", nil, "only consider snapshots which include this (absolute) `path` for snapshot ID \"latest\"")
+}
+
+// archiveWriter adds the nodes of a snapshot to an archive.
+type archiveWriter interface {
+	// Add adds node to the archive with the given name. Names of directories
+	// end with a slash.
+	Add(ctx context.Context, name string, node *restic.Node) error
+	Close() error
+}
+
+// zipWriter writes a zip archive.
+type zipWriter struct {
+	repo restic.Repository
+	zw   *zip.Writer
+}
+
+func newZipWriter(repo restic.Repository, wr io.Writer) *zipWriter {
+	return &zipWriter{
+		repo: repo,
+		zw:   zip.NewWriter(wr),
+	}
+}
+
+func (w *zipWriter) Add(ctx context.Context, name string, node *restic.Node) error {
+	switch node.Type {
+	case "dir", "symlink", "file":
+	default:
+		Warnf("skipping %v: type %v is not supported\n", name, node.Type)
+		return nil
+	}
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Modified: node.ModTime,
+	}
+	header.SetMode(node.Mode)
+	if node.Type == "file" {
+		header.Method = zip.Deflate
+	}
+
+	wr, err := w.zw.CreateHeader(header)
+	if err != nil {
+		return errors.Wrap(err, "CreateHeader")
+	}
+
+	switch node.Type {
+	case "symlink":
+		_, err = io.WriteString(wr, node.LinkTarget)
+		return errors.Wrap(err, "Write")
+	case "file":
+		return getNodeData(ctx, wr, w.repo, node)
+	}
+	return nil
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// exportTree adds all nodes in the tree id to the archive. The names in the
+// archive start with prefix.
+func exportTree(ctx context.Context, repo restic.Repository, id restic.ID, prefix string, aw archiveWriter) error {
+	return walker.Walk(ctx, repo, id, nil, func(_ restic.ID, nodepath string, node *restic.Node, err error) (bool, error) {
+		if err != nil {
+			return false, err
+		}
+		if node == nil {
+			return false, nil
+		}
+
+		name := strings.TrimPrefix(path.Join(prefix, nodepath), "/")
+		if node.Type == "dir" {
+			name += "/"
+		}
+
+		debug.Log("export %v", name)
+		return false, aw.Add(ctx, name, node)
+	})
+}
+
+func runExport(opts ExportOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("no snapshot ID specified")
+	}
+
+	if opts.Format != "tar" && opts.Format != "zip" {
+		return errors.Fatalf("unknown archive format %q", opts.Format)
+	}
+
+	if opts.Output == "" && stdoutIsTerminal() {
+		return errors.Fatal("stdout is the terminal, please redirect output or use --output")
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.NoLock {
+		lock, err := lockRepo(repo)
+		defer unlockRepo(lock)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = repo.LoadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	var id restic.ID
+	if args[0] == "latest" {
+		id, err = restic.FindLatestSnapshot(ctx, repo, opts.Paths, opts.Tags, opts.Host)
+		if err != nil {
+			return errors.Fatalf("latest snapshot for criteria not found: %v", err)
+		}
+	} else {
+		id, err = restic.FindSnapshot(repo, args[0])
+		if err != nil {
+			return errors.Fatalf("invalid id %q: %v", args[0], err)
+		}
+	}
+
+	sn, err := restic.LoadSnapshot(ctx, repo, id)
+	if err != nil {
+		return err
+	}
+
+	var f *os.File
+	var wr io.Writer = gopts.stdout
+	if opts.Output != "" {
+		f, err = os.Create(opts.Output)
+		if err != nil {
+			return errors.Fatalf("unable to create archive: %v", err)
+		}
+		defer f.Close()
+		wr = f
+	}
+
+	var aw archiveWriter
+	switch opts.Format {
+	case "tar":
+		aw = newTarWriter(repo, wr)
+	case "zip":
+		aw = newZipWriter(repo, wr)
+	}
+
+	err = exportTree(ctx, repo, *sn.Tree, "", aw)
+	if err != nil {
+		return err
+	}
+
+	err = aw.Close()
+	if err != nil {
+		return err
+	}
+
+	if f == nil {
+		return nil
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	Verbosef("exported snapshot %v to %v\n", sn.ID().Str(), opts.Output)
+	return nil
+}
@@ -1,19 +1,25 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
+	"github.com/spf13/pflag"
 )
 
 var cmdGenerate = &cobra.Command{
 	Use:   "generate [command]",
-	Short: "Generate manual pages and auto-completion files (bash, zsh)",
+	Short: "Generate manual pages and auto-completion files (bash, fish, zsh)",
 	Long: `
 The "generate" command writes automatically generated files (like the man pages
-and the auto-completion files for bash and zsh).
+and the auto-completion files for bash, fish and zsh).
 `,
 	DisableAutoGenTag: true,
 	RunE:              runGenerate,
@@ -23,6 +29,7 @@ type generateOptions struct {
 	ManDir             string
 	BashCompletionFile string
 	ZSHCompletionFile  string
+	FishCompletionFile string
 }
 
 var genOpts generateOptions
@@ -33,6 +40,7 @@ func init() {
 	fs.StringVar(&genOpts.ManDir, "man", "", "write man pages to `directory`")
 	fs.StringVar(&genOpts.BashCompletionFile, "bash-completion", "", "write bash completion `file`")
 	fs.StringVar(&genOpts.ZSHCompletionFile, "zsh-completion", "", "write zsh completion `file`")
+	fs.StringVar(&genOpts.FishCompletionFile, "fish-completion", "", "write fish completion `file`")
 }
 
 func writeManpages(dir string) error {
@@ -63,6 +71,84 @@ func writeZSHCompletion(file string) error {
 	return cmdRoot.GenZshCompletionFile(file)
 }
 
+// fishQuote returns s as a single-quoted string for fish.
+func fishQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `'`, `\'`, -1)
+	return "'" + s + "'"
+}
+
+// genFishFlags writes the completions for all flags in fs, which are only
+// offered if condition is true.
+func genFishFlags(w io.Writer, fs *pflag.FlagSet, condition string) {
+	fs.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+
+		line := "complete -c restic"
+		if condition != "" {
+			line += " -n " + fishQuote(condition)
+		}
+		line += " -l " + f.Name
+		if f.Shorthand != "" {
+			line += " -s " + f.Shorthand
+		}
+		// flags with an optional value, e.g. --verbose, can be used alone
+		if f.Value.Type() != "bool" && f.NoOptDefVal == "" {
+			line += " -r"
+		}
+		_, usage := pflag.UnquoteUsage(f)
+		line += " -d " + fishQuote(usage)
+
+		fmt.Fprintln(w, line)
+	})
+}
+
+// genFishCommands writes the completions for the subcommands of cmd and their
+// flags.
+func genFishCommands(w io.Writer, cmd *cobra.Command, condition string) {
+	for _, c := range cmd.Commands() {
+		if !c.IsAvailableCommand() {
+			continue
+		}
+
+		fmt.Fprintf(w, "complete -c restic -n %s -a %s -d %s\n",
+			fishQuote(condition), c.Name(), fishQuote(c.Short))
+
+		sub := "__fish_seen_subcommand_from " + c.Name()
+		genFishFlags(w, c.NonInheritedFlags(), sub)
+		genFishCommands(w, c, sub)
+	}
+}
+
+// genFishCompletion writes a fish completion script for the root command.
+func genFishCompletion(w io.Writer) error {
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "# fish completion for restic, generated by `restic generate`\n\n")
+	genFishFlags(buf, cmdRoot.PersistentFlags(), "")
+	genFishCommands(buf, cmdRoot, "__fish_use_subcommand")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeFishCompletion(file string) error {
+	Verbosef("writing fish completion file to %v\n", file)
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+
+	err = genFishCompletion(f)
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
 func runGenerate(cmd *cobra.Command, args []string) error {
 	if genOpts.ManDir != "" {
 		err := writeManpages(genOpts.ManDir)
@@ -85,6 +171,13 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if genOpts.FishCompletionFile != "" {
+		err := writeFishCompletion(genOpts.FishCompletionFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	var empty generateOptions
 	if genOpts == empty {
 		return errors.Fatal("nothing to do, please specify at least one output file/dir")
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestFishQuote(t *testing.T) {
+	var tests = []struct {
+		input, want string
+	}{
+		{"foo", `'foo'`},
+		{"don't", `'don\'t'`},
+		{`C:\foo`, `'C:\\foo'`},
+	}
+
+	for _, test := range tests {
+		rtest.Equals(t, test.want, fishQuote(test.input))
+	}
+}
+
+func TestGenFishCompletion(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	rtest.OK(t, genFishCompletion(buf))
+
+	for _, want := range []string{
+		"complete -c restic -l repo -s r -r -d ",
+		"complete -c restic -n '__fish_use_subcommand' -a backup -d ",
+		"complete -c restic -n '__fish_seen_subcommand_from backup' -l exclude -s e -r -d ",
+		"complete -c restic -n '__fish_seen_subcommand_from restore' -l target -s t -r -d ",
+	} {
+		rtest.Assert(t, strings.Contains(buf.String(), want),
+			"line %q not found in completion script", want)
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdImport = &cobra.Command{
+	Use:   "import [flags] archive.tar",
+	Short: "Create a snapshot from a tar archive",
+	Long: `
+The "import" command reads a tar archive and saves its contents as a new
+snapshot, e.g. to move backups made with other tools into the repository.
+The archive may be compressed with gzip. Use "-" to read the archive from
+stdin.
+
+The files are stored with the permissions, owners, modification times and
+extended attributes recorded in the archive. Use --host and --time to set
+the host name and time of the snapshot, e.g. to the values of the original
+backup.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runImport(importOptions, globalOptions, args)
+	},
+}
+
+// ImportOptions collects all options for the import command.
+type ImportOptions struct {
+	Host      string
+	TimeStamp string
+	Tags      []string
+}
+
+var importOptions ImportOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdImport)
+
+	f := cmdImport.Flags()
+	f.StringVarP(&importOptions.Host, "host", "H", "", "set the `hostname` for the snapshot (default: the current host)")
+	f.StringVar(&importOptions.TimeStamp, "time", "", "time of the snapshot (ex. '2012-11-01 22:08:41') (default: now)")
+	f.StringArrayVar(&importOptions.Tags, "tag", nil, "add a `tag` for the new snapshot (can be specified multiple times)")
+}
+
+// importDir is a directory which is assembled from the entries of the archive.
+type importDir struct {
+	node    *restic.Node
+	entries map[string]*restic.Node
+	subdirs map[string]*importDir
+}
+
+func newImportDir(node *restic.Node) *importDir {
+	return &importDir{
+		node:    node,
+		entries: make(map[string]*restic.Node),
+		subdirs: make(map[string]*importDir),
+	}
+}
+
+// importer saves the contents of a tar archive to the repository.
+type importer struct {
+	repo *repository.Repository
+	pol  chunker.Pol
+	buf  []byte
+
+	// saved contains the blobs which have been saved during this run, they
+	// are not part of the index yet
+	saved restic.BlobSet
+
+	root *importDir
+
+	// links maps the names of files to their nodes, so that hard links can
+	// refer to them
+	links map[string]*restic.Node
+
+	// inode is used to assign inode numbers to files, hard links share the
+	// inode number of their target
+	inode uint64
+
+	// defaultTime is used for directories which are not part of the archive
+	defaultTime time.Time
+
+	stats struct {
+		files, dirs, other uint
+		bytes              uint64
+	}
+}
+
+// saveBlob saves the blob unless it is already stored in the repository.
+func (imp *importer) saveBlob(ctx context.Context, t restic.BlobType, buf []byte) (restic.ID, error) {
+	id := restic.Hash(buf)
+	h := restic.BlobHandle{ID: id, Type: t}
+	if imp.saved.Has(h) || imp.repo.Index().Has(id, t) {
+		return id, nil
+	}
+
+	_, err := imp.repo.SaveBlob(ctx, t, buf, id)
+	if err != nil {
+		return restic.ID{}, err
+	}
+
+	imp.saved.Insert(h)
+	return id, nil
+}
+
+// saveContent splits the data read from rd into chunks and saves them.
+func (imp *importer) saveContent(ctx context.Context, rd io.Reader) (restic.IDs, error) {
+	// empty files have an empty list of blobs, not nil
+	content := restic.IDs{}
+	chnkr := chunker.New(rd, imp.pol)
+
+	for {
+		chunk, err := chnkr.Next(imp.buf)
+		if errors.Cause(err) == io.EOF {
+			return content, nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Next")
+		}
+
+		id, err := imp.saveBlob(ctx, restic.DataBlob, chunk.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		content = append(content, id)
+	}
+}
+
+// dir returns the directory with the given name, it is created if necessary.
+func (imp *importer) dir(name string) *importDir {
+	if name == "" || name == "." {
+		return imp.root
+	}
+
+	parent := imp.dir(path.Dir(name))
+	base := path.Base(name)
+
+	d, ok := parent.subdirs[base]
+	if !ok {
+		d = newImportDir(&restic.Node{
+			Name:       base,
+			Type:       "dir",
+			Mode:       os.ModeDir | 0755,
+			ModTime:    imp.defaultTime,
+			AccessTime: imp.defaultTime,
+			ChangeTime: imp.defaultTime,
+		})
+		parent.subdirs[base] = d
+		delete(parent.entries, base)
+	}
+	return d
+}
+
+// nodeFromHeader returns a node with the metadata from hdr.
+func nodeFromHeader(name string, hdr *tar.Header) *restic.Node {
+	node := &restic.Node{
+		Name:       path.Base(name),
+		Mode:       hdr.FileInfo().Mode(),
+		ModTime:    hdr.ModTime,
+		AccessTime: hdr.AccessTime,
+		ChangeTime: hdr.ChangeTime,
+		UID:        uint32(hdr.Uid),
+		GID:        uint32(hdr.Gid),
+		User:       hdr.Uname,
+		Group:      hdr.Gname,
+	}
+
+	if node.AccessTime.IsZero() {
+		node.AccessTime = node.ModTime
+	}
+	if node.ChangeTime.IsZero() {
+		node.ChangeTime = node.ModTime
+	}
+
+	var xattrs []string
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "SCHILY.xattr.") {
+			xattrs = append(xattrs, key)
+		}
+	}
+	sort.Strings(xattrs)
+
+	for _, key := range xattrs {
+		node.ExtendedAttributes = append(node.ExtendedAttributes, restic.ExtendedAttribute{
+			Name:  strings.TrimPrefix(key, "SCHILY.xattr."),
+			Value: []byte(hdr.PAXRecords[key]),
+		})
+	}
+
+	return node
+}
+
+// cleanName returns the name of an archive entry relative to the root of the
+// snapshot, it is empty for the root directory.
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// add adds the entry described by hdr to the snapshot, the contents of regular
+// files are read from rd.
+func (imp *importer) add(ctx context.Context, hdr *tar.Header, rd io.Reader) error {
+	name := cleanName(hdr.Name)
+	if name == "" {
+		debug.Log("ignoring root directory entry %q", hdr.Name)
+		return nil
+	}
+
+	node := nodeFromHeader(name, hdr)
+
+	var err error
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		d := imp.dir(name)
+		d.node = node
+		d.node.Type = "dir"
+		imp.stats.dirs++
+		return nil
+
+	case tar.TypeReg, tar.TypeRegA:
+		node.Type = "file"
+		node.Size = uint64(hdr.Size)
+		node.Content, err = imp.saveContent(ctx, rd)
+		if err != nil {
+			return err
+		}
+
+		imp.inode++
+		node.Inode = imp.inode
+		node.Links = 1
+		imp.links[name] = node
+
+		imp.stats.files++
+		imp.stats.bytes += node.Size
+
+	case tar.TypeLink:
+		orig, ok := imp.links[cleanName(hdr.Linkname)]
+		if !ok {
+			return errors.Errorf("hard link %v refers to unknown file %v", hdr.Name, hdr.Linkname)
+		}
+
+		// all links share the metadata of the file
+		orig.Links++
+		node = orig
+		imp.links[name] = node
+		imp.stats.files++
+
+	case tar.TypeSymlink:
+		node.Type = "symlink"
+		node.LinkTarget = hdr.Linkname
+		imp.stats.other++
+
+	case tar.TypeFifo:
+		node.Type = "fifo"
+		imp.stats.other++
+
+	default:
+		Warnf("skipping %v: unsupported type %q\n", hdr.Name, hdr.Typeflag)
+		return nil
+	}
+
+	parent := imp.dir(path.Dir(name))
+	base := path.Base(name)
+	delete(parent.subdirs, base)
+	parent.entries[base] = node
+	return nil
+}
+
+// saveDir saves the tree for d and all its subdirectories.
+func (imp *importer) saveDir(ctx context.Context, d *importDir) (restic.ID, error) {
+	tree := restic.NewTree()
+
+	for name, sub := range d.subdirs {
+		id, err := imp.saveDir(ctx, sub)
+		if err != nil {
+			return restic.ID{}, err
+		}
+
+		node := *sub.node
+		node.Name = name
+		node.Subtree = &id
+		err = tree.Insert(&node)
+		if err != nil {
+			return restic.ID{}, err
+		}
+	}
+
+	for name, entry := range d.entries {
+		// hard links share the node, so copy it before setting the name
+		node := *entry
+		node.Name = name
+		err := tree.Insert(&node)
+		if err != nil {
+			return restic.ID{}, err
+		}
+	}
+
+	return imp.repo.SaveTree(ctx, tree)
+}
+
+// openArchive opens the archive with the given name, "-" is stdin. Archives
+// compressed with gzip are decompressed.
+func openArchive(name string) (io.ReadCloser, error) {
+	var rc io.ReadCloser = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, errors.Fatalf("unable to open archive: %v", err)
+		}
+		rc = f
+	}
+
+	rd := bufio.NewReader(rc)
+	magic, err := rd.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(rd)
+		if err != nil {
+			_ = rc.Close()
+			return nil, errors.Wrap(err, "gzip.NewReader")
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{zr, rc}, nil
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{rd, rc}, nil
+}
+
+func runImport(opts ImportOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("please specify exactly one archive")
+	}
+
+	if args[0] == "-" && gopts.password == "" {
+		return errors.Fatal("unable to read password from stdin when data is to be read from stdin, use --password-file or $RESTIC_PASSWORD")
+	}
+
+	timeStamp := time.Now()
+	if opts.TimeStamp != "" {
+		var err error
+		timeStamp, err = time.ParseInLocation(TimeFormat, opts.TimeStamp, time.Local)
+		if err != nil {
+			return errors.Fatalf("error in time option: %v\n", err)
+		}
+	}
+
+	if opts.Host == "" {
+		var err error
+		opts.Host, err = os.Hostname()
+		if err != nil {
+			debug.Log("os.Hostname() returned err: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockRepo(repo)
+	defer unlockRepo(lock)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("loading indexes\n")
+	err = repo.LoadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	archive, err := openArchive(args[0])
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	imp := &importer{
+		repo:        repo,
+		pol:         repo.Config().ChunkerPolynomial,
+		buf:         make([]byte, chunker.MaxSize),
+		saved:       restic.NewBlobSet(),
+		links:       make(map[string]*restic.Node),
+		defaultTime: timeStamp,
+	}
+	imp.root = newImportDir(nil)
+
+	Verbosef("reading archive %v\n", args[0])
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Fatalf("unable to read archive: %v", err)
+		}
+
+		debug.Log("import %v", hdr.Name)
+		err = imp.add(ctx, hdr, tr)
+		if err != nil {
+			return err
+		}
+	}
+
+	treeID, err := imp.saveDir(ctx, imp.root)
+	if err != nil {
+		return err
+	}
+
+	if err = repo.Flush(ctx); err != nil {
+		return err
+	}
+
+	if err = repo.SaveIndex(ctx); err != nil {
+		return err
+	}
+
+	// the top-level entries of the archive are the paths of the snapshot
+	var paths []string
+	for name := range imp.root.subdirs {
+		paths = append(paths, "/"+name)
+	}
+	for name := range imp.root.entries {
+		paths = append(paths, "/"+name)
+	}
+	sort.Strings(paths)
+
+	sn, err := restic.NewSnapshot(paths, opts.Tags, opts.Host, timeStamp)
+	if err != nil {
+		return err
+	}
+	sn.Tree = &treeID
+
+	id, err := repo.SaveJSONUnpacked(ctx, restic.SnapshotFile, sn)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("imported %d files, %d directories and %d other items (%v)\n",
+		imp.stats.files, imp.stats.dirs, imp.stats.other, formatBytes(imp.stats.bytes))
+	Printf("snapshot %s saved\n", id.Str())
+	return nil
+}
@@ -1,8 +1,16 @@
 package main
 
 import (
+	"time"
+
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/mirror"
+	"github.com/restic/restic/internal/backend/tiered"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
 
 	"github.com/spf13/cobra"
 )
@@ -12,27 +20,125 @@ var cmdInit = &cobra.Command{
 	Short: "Initialize a new repository",
 	Long: `
 The "init" command initializes a new repository.
+
+With --copy-chunker-params, the new repository uses the same parameters for
+splitting files into chunks as the repository given with --repo2. This allows
+deduplicating data which is copied between the two repositories.
+
+The parameters for the key derivation function are chosen so that deriving
+the key takes about --kdf-time and uses at most --kdf-memory MiB of memory.
+Larger values make guessing the password more expensive.
+
+For a repository on a removable drive, -o local.store-filesystem-uuid=true
+stores the UUID of the filesystem in the repository config. Afterwards restic
+refuses to use the repository on a different filesystem, e.g. when the wrong
+drive is mounted. This is only supported on Linux.
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runInit(globalOptions, args)
+		return runInit(initOptions, globalOptions, args)
 	},
 }
 
+// InitOptions bundles all options for the init command.
+type InitOptions struct {
+	secondaryRepoOptions
+	CopyChunkerParameters bool
+
+	KDFTime   time.Duration
+	KDFMemory int
+}
+
+var initOptions InitOptions
+
 func init() {
 	cmdRoot.AddCommand(cmdInit)
+
+	f := cmdInit.Flags()
+	initSecondaryRepoOptions(f, &initOptions.secondaryRepoOptions, "source", "source repository to copy chunker parameters from")
+	f.BoolVar(&initOptions.CopyChunkerParameters, "copy-chunker-params", false, "copy chunker parameters from the repository given with --repo2")
+	f.DurationVar(&initOptions.KDFTime, "kdf-time", repository.KDFTimeout, "let the key derivation function run for about `duration`")
+	f.IntVar(&initOptions.KDFMemory, "kdf-memory", repository.KDFMemory, "let the key derivation function use at most `n` MiB of memory")
 }
 
-func runInit(gopts GlobalOptions, args []string) error {
+// readChunkerPolynomial returns the chunker polynomial of the repository
+// given with --repo2.
+func readChunkerPolynomial(opts InitOptions, gopts GlobalOptions) (*chunker.Pol, error) {
+	srcGopts, err := fillSecondaryGlobalOpts(opts.secondaryRepoOptions, gopts, "source")
+	if err != nil {
+		return nil, err
+	}
+
+	srcRepo, err := OpenRepository(srcGopts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = srcRepo.Close()
+	}()
+
+	pol := srcRepo.Config().ChunkerPolynomial
+	return &pol, nil
+}
+
+func runInit(opts InitOptions, gopts GlobalOptions, args []string) error {
 	if gopts.Repo == "" {
 		return errors.Fatal("Please specify repository location (-r)")
 	}
 
+	if opts.KDFTime <= 0 || opts.KDFMemory <= 0 {
+		return errors.Fatal("--kdf-time and --kdf-memory must be positive")
+	}
+
+	var chunkerPolynomial *chunker.Pol
+	if opts.CopyChunkerParameters {
+		var err error
+		chunkerPolynomial, err = readChunkerPolynomial(opts, gopts)
+		if err != nil {
+			return err
+		}
+	}
+
 	be, err := create(gopts.Repo, gopts.extended)
 	if err != nil {
 		return errors.Fatalf("create repository at %s failed: %v\n", gopts.Repo, err)
 	}
 
+	if gopts.Cold != "" {
+		cbe, err := create(gopts.Cold, gopts.extended)
+		if err != nil {
+			return errors.Fatalf("create repository at %s failed: %v\n", gopts.Cold, err)
+		}
+		be = tiered.New(be, cbe)
+	}
+
+	if len(gopts.Mirrors) > 0 {
+		backends := []restic.Backend{be}
+		for _, s := range gopts.Mirrors {
+			mbe, err := create(s, gopts.extended)
+			if err != nil {
+				return errors.Fatalf("create repository at %s failed: %v\n", s, err)
+			}
+			backends = append(backends, mbe)
+		}
+		be = mirror.New(backends...)
+	}
+
+	// the config and keys are needed in both locations, so that the
+	// repository can be opened from the failover location as well
+	if gopts.Failover != "" {
+		fbe, err := create(gopts.Failover, gopts.extended)
+		if err != nil {
+			return errors.Fatalf("create repository at %s failed: %v\n", gopts.Failover, err)
+		}
+		be = mirror.New(be, fbe)
+	}
+
+	filesystemUUID, err := readFilesystemUUID(gopts)
+	if err != nil {
+		return err
+	}
+
 	gopts.password, err = ReadPasswordTwice(gopts,
 		"enter password for new repository: ",
 		"enter password again: ")
@@ -42,7 +148,8 @@ func runInit(gopts GlobalOptions, args []string) error {
 
 	s := repository.New(be)
 
-	err = s.Init(gopts.ctx, gopts.password)
+	kdfLimits := repository.KDFLimits{Timeout: opts.KDFTime, Memory: opts.KDFMemory}
+	err = s.Init(gopts.ctx, gopts.password, chunkerPolynomial, filesystemUUID, kdfLimits)
 	if err != nil {
 		return errors.Fatalf("create key in repository at %s failed: %v\n", gopts.Repo, err)
 	}
@@ -55,3 +162,35 @@ func runInit(gopts GlobalOptions, args []string) error {
 
 	return nil
 }
+
+// readFilesystemUUID returns the UUID of the filesystem the new repository is
+// stored on if it is a local repository and -o local.store-filesystem-uuid=true
+// was given, and an empty string otherwise.
+func readFilesystemUUID(gopts GlobalOptions) (string, error) {
+	loc, err := location.Parse(gopts.Repo)
+	if err != nil {
+		return "", err
+	}
+
+	if loc.Scheme != "local" {
+		return "", nil
+	}
+
+	cfg, err := parseConfig(loc, gopts.extended)
+	if err != nil {
+		return "", err
+	}
+
+	lcfg := cfg.(local.Config)
+	if !lcfg.StoreFilesystemUUID {
+		return "", nil
+	}
+
+	uuid, err := local.FilesystemUUID(lcfg)
+	if err != nil {
+		return "", errors.Fatalf("unable to store the filesystem UUID: %v", err)
+	}
+
+	Verbosef("repository is stored on the filesystem with UUID %v\n", uuid)
+	return uuid, nil
+}
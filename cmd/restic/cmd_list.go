@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/index"
@@ -15,33 +16,74 @@ var cmdList = &cobra.Command{
 	Short: "List objects in the repository",
 	Long: `
 The "list" command allows listing objects in the repository based on type.
+
+The lock held by the list command itself is not listed. With --long, the
+host, process and user holding each lock are printed as well as the age of
+the lock, and whether it is stale.
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runList(cmd, globalOptions, args)
+		return runList(cmd, listOptions, globalOptions, args)
 	},
 }
 
+// ListOptions collects all options for the list command.
+type ListOptions struct {
+	Long bool
+}
+
+var listOptions ListOptions
+
 func init() {
 	cmdRoot.AddCommand(cmdList)
+
+	cmdList.Flags().BoolVarP(&listOptions.Long, "long", "l", false, "print details about each lock (only for locks)")
+}
+
+// formatLock returns a line describing the lock with the given ID.
+func formatLock(id restic.ID, lock *restic.Lock) string {
+	mode := "shared"
+	if lock.Exclusive {
+		mode = "exclusive"
+	}
+
+	text := fmt.Sprintf("%v %v, PID %d on %v by %v, created at %v (%v ago)",
+		id, mode, lock.PID, lock.Hostname, lock.Username,
+		lock.Time.Format(TimeFormat), time.Since(lock.Time).Round(time.Second))
+	if lock.Stale() {
+		text += ", stale"
+	}
+
+	return text
 }
 
-func runList(cmd *cobra.Command, opts GlobalOptions, args []string) error {
+func runList(cmd *cobra.Command, opts ListOptions, gopts GlobalOptions, args []string) error {
 	if len(args) != 1 {
 		return errors.Fatal("type not specified, usage: " + cmd.Use)
 	}
 
-	repo, err := OpenRepository(opts)
+	if opts.Long && args[0] != "locks" {
+		return errors.Fatal("--long is only supported for locks")
+	}
+
+	repo, err := OpenRepository(gopts)
 	if err != nil {
 		return err
 	}
 
-	if !opts.NoLock {
+	// the own lock is not listed, it is removed when the command exits
+	var ownLock *restic.ID
+	if !gopts.NoLock {
 		lock, err := lockRepo(repo)
 		defer unlockRepo(lock)
 		if err != nil {
 			return err
 		}
+		ownLock = lock.ID()
+	}
+
+	isOwnLock := func(id restic.ID) bool {
+		return ownLock != nil && id.Equal(*ownLock)
 	}
 
 	var t restic.FileType
@@ -57,7 +99,7 @@ func runList(cmd *cobra.Command, opts GlobalOptions, args []string) error {
 	case "locks":
 		t = restic.LockFile
 	case "blobs":
-		idx, err := index.Load(opts.ctx, repo, nil)
+		idx, err := index.Load(gopts.ctx, repo, nil)
 		if err != nil {
 			return err
 		}
@@ -73,7 +115,28 @@ func runList(cmd *cobra.Command, opts GlobalOptions, args []string) error {
 		return errors.Fatal("invalid type")
 	}
 
-	return repo.List(opts.ctx, t, func(id restic.ID, size int64) error {
+	if opts.Long {
+		return repo.List(gopts.ctx, t, func(id restic.ID, size int64) error {
+			if isOwnLock(id) {
+				return nil
+			}
+
+			lock, err := restic.LoadLock(gopts.ctx, repo, id)
+			if err != nil {
+				Warnf("unable to load lock %v: %v\n", id.Str(), err)
+				return nil
+			}
+
+			Printf("%s\n", formatLock(id, lock))
+			return nil
+		})
+	}
+
+	return repo.List(gopts.ctx, t, func(id restic.ID, size int64) error {
+		if t == restic.LockFile && isOwnLock(id) {
+			return nil
+		}
+
 		Printf("%s\n", id)
 		return nil
 	})
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdPing = &cobra.Command{
+	Use:   "ping [flags]",
+	Short: "Test the connection to the repository",
+	Long: `
+The "ping" command checks that the repository can be reached and that the
+credentials allow saving, reading and removing files. It saves a test file
+with random data, reads it back and removes it again, and reports how long
+each operation took. The repository password is not needed.
+
+The test file is saved as a lock file, which other restic processes ignore
+because it cannot be decrypted. If the command is interrupted, the file can
+be removed with "restic unlock --remove-all".
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPing(pingOptions, globalOptions)
+	},
+}
+
+// maxPingSize is the largest test file, it is held in memory twice.
+const maxPingSize = 64 << 20
+
+// PingOptions collects all options for the ping command.
+type PingOptions struct {
+	Size string
+}
+
+var pingOptions PingOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdPing)
+
+	f := cmdPing.Flags()
+	f.StringVar(&pingOptions.Size, "size", "1M", "save a test file of `size` bytes, at most 64M (allowed suffixes: k/K, m/M, g/G, t/T)")
+}
+
+// formatCapabilities returns a short description of the backend capabilities.
+func formatCapabilities(caps restic.Capabilities) string {
+	var list []string
+	if caps.AtomicSave {
+		list = append(list, "atomic save")
+	}
+	if caps.MaxFileSize > 0 {
+		list = append(list, "max file size "+formatBytes(uint64(caps.MaxFileSize)))
+	}
+
+	if len(list) == 0 {
+		return "none"
+	}
+	return strings.Join(list, ", ")
+}
+
+func runPing(opts PingOptions, gopts GlobalOptions) error {
+	if gopts.Repo == "" {
+		return errors.Fatal("Please specify repository location (-r)")
+	}
+
+	size, err := parseSizeStr(opts.Size)
+	if err != nil {
+		return errors.Fatalf("invalid --size: %v", err)
+	}
+
+	if size > maxPingSize {
+		return errors.Fatalf("--size %v is too large, at most %v are allowed", opts.Size, formatBytes(maxPingSize))
+	}
+
+	buf := make([]byte, size)
+	_, err = io.ReadFull(rand.Reader, buf)
+	if err != nil {
+		return errors.Wrap(err, "ReadFull")
+	}
+
+	ctx := gopts.ctx
+
+	start := time.Now()
+	be, err := open(gopts.Repo, gopts, gopts.extended)
+	if err != nil {
+		return err
+	}
+	defer be.Close()
+	Printf("open      %v\n", time.Since(start))
+	Verbosef("backend capabilities: %v\n", formatCapabilities(be.Capabilities()))
+
+	start = time.Now()
+	found, err := be.Test(ctx, restic.Handle{Type: restic.ConfigFile})
+	if err != nil {
+		return errors.Fatalf("unable to read from the repository: %v", err)
+	}
+	if !found {
+		return errors.Fatalf("there is no repository at %v", be.Location())
+	}
+	Printf("test      %v\n", time.Since(start))
+
+	h := restic.Handle{Type: restic.LockFile, Name: restic.Hash(buf).String()}
+
+	start = time.Now()
+	err = be.Save(ctx, h, restic.NewByteReader(buf))
+	if err != nil {
+		return errors.Fatalf("unable to save the test file: %v", err)
+	}
+	d := time.Since(start)
+	Printf("save      %v (%v, %v)\n", d, formatBytes(size), formatRate(size, d))
+
+	// make sure the test file is removed when one of the next steps fails
+	removed := false
+	defer func() {
+		if !removed {
+			_ = be.Remove(context.Background(), h)
+		}
+	}()
+
+	start = time.Now()
+	fi, err := be.Stat(ctx, h)
+	if err != nil {
+		return errors.Fatalf("unable to stat the test file: %v", err)
+	}
+	if uint64(fi.Size) != size {
+		return errors.Fatalf("test file has wrong size %d, want %d", fi.Size, size)
+	}
+	Printf("stat      %v\n", time.Since(start))
+
+	start = time.Now()
+	var data []byte
+	err = be.Load(ctx, h, 0, 0, func(rd io.Reader) (ierr error) {
+		data, ierr = ioutil.ReadAll(rd)
+		return ierr
+	})
+	if err != nil {
+		return errors.Fatalf("unable to load the test file: %v", err)
+	}
+	if !bytes.Equal(data, buf) {
+		return errors.Fatal("test file was not read back correctly")
+	}
+	d = time.Since(start)
+	Printf("load      %v (%v, %v)\n", d, formatBytes(size), formatRate(size, d))
+
+	start = time.Now()
+	err = be.Remove(ctx, h)
+	if err != nil {
+		return errors.Fatalf("unable to remove the test file: %v", err)
+	}
+	removed = true
+	Printf("remove    %v\n", time.Since(start))
+
+	Verbosef("repository at %v is reachable\n", be.Location())
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"path"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdRepair = &cobra.Command{
+	Use:   "repair",
+	Short: "Repair the repository",
+}
+
+var cmdRepairSnapshots = &cobra.Command{
+	Use:   "snapshots [flags] [snapshot-ID ...]",
+	Short: "Remove references to missing data from snapshots",
+	Long: `
+The "repair snapshots" command rewrites snapshots which reference data that is
+no longer available in the repository, for example because pack files were
+lost. Files with missing contents are removed from the snapshot, directories
+which cannot be read are replaced by empty directories. Everything else stays
+restorable.
+
+Run "restic rebuild-index" first, so that the index does not reference lost
+pack files any more.
+
+A repaired snapshot is saved as a new snapshot, the original snapshot is only
+removed when --forget is given. Snapshots which do not need to be repaired are
+left alone.
+
+When no snapshot-ID is given, all snapshots matching the host, tag and path
+filter criteria are checked.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRepairSnapshots(repairSnapshotsOptions, globalOptions, args)
+	},
+}
+
+// RepairSnapshotsOptions collects all options for the 'repair snapshots' command.
+type RepairSnapshotsOptions struct {
+	Forget bool
+	DryRun bool
+
+	Host  string
+	Tags  restic.TagLists
+	Paths []string
+}
+
+var repairSnapshotsOptions RepairSnapshotsOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdRepair)
+	cmdRepair.AddCommand(cmdRepairSnapshots)
+
+	f := cmdRepairSnapshots.Flags()
+	f.BoolVar(&repairSnapshotsOptions.Forget, "forget", false, "remove the original snapshots after saving the repaired ones")
+	f.BoolVarP(&repairSnapshotsOptions.DryRun, "dry-run", "n", false, "only report what would be repaired, do not modify the repository")
+
+	f.StringVarP(&repairSnapshotsOptions.Host, "host", "H", "", "only consider snapshots for this `host`, when no snapshot-ID is given")
+	f.Var(&repairSnapshotsOptions.Tags, "tag", "only consider snapshots which include this `taglist`, when no snapshot-ID is given")
+	f.StringArrayVar(&repairSnapshotsOptions.Paths, "path", nil, "only consider snapshots which include this (absolute) `path`, when no snapshot-ID is given")
+}
+
+// treeRepairer rewrites trees so that they only reference available blobs.
+type treeRepairer struct {
+	repo   *repository.Repository
+	dryRun bool
+
+	// packs contains the IDs of all pack files in the repository
+	packs restic.IDSet
+
+	// repaired maps the IDs of trees which have already been processed to
+	// their new IDs
+	repaired map[restic.ID]restic.ID
+}
+
+// available returns true if the blob is stored in a pack file which exists.
+func (r *treeRepairer) available(t restic.BlobType, id restic.ID) bool {
+	blobs, found := r.repo.Index().Lookup(id, t)
+	if !found {
+		return false
+	}
+
+	for _, pb := range blobs {
+		if r.packs.Has(pb.PackID) {
+			return true
+		}
+	}
+	return false
+}
+
+// saveTree stores the tree unless this is a dry run.
+func (r *treeRepairer) saveTree(ctx context.Context, tree *restic.Tree) (restic.ID, error) {
+	if r.dryRun {
+		return restic.ID{}, nil
+	}
+	return r.repo.SaveTree(ctx, tree)
+}
+
+// repairTree returns the ID of a tree which contains all nodes of the tree id
+// for which the data is available. The tree is loaded from and saved to the
+// repository as needed; changed is false if nothing had to be removed.
+func (r *treeRepairer) repairTree(ctx context.Context, dir string, id restic.ID) (newID restic.ID, changed bool, err error) {
+	if newID, ok := r.repaired[id]; ok {
+		return newID, newID != id, nil
+	}
+
+	var tree *restic.Tree
+	if r.available(restic.TreeBlob, id) {
+		tree, err = r.repo.LoadTree(ctx, id)
+	}
+	if tree == nil || err != nil {
+		debug.Log("unable to load tree %v: %v", id.Str(), err)
+		Printf("  dir %q: replaced with empty directory, contents are missing\n", dir)
+
+		newID, err = r.saveTree(ctx, restic.NewTree())
+		if err != nil {
+			return restic.ID{}, false, err
+		}
+		r.repaired[id] = newID
+		return newID, true, nil
+	}
+
+	newTree := restic.NewTree()
+	for _, node := range tree.Nodes {
+		nodePath := path.Join(dir, node.Name)
+
+		switch {
+		case node.Type == "file":
+			missing := false
+			for _, blobID := range node.Content {
+				if !r.available(restic.DataBlob, blobID) {
+					missing = true
+					break
+				}
+			}
+
+			if missing {
+				Printf("  file %q: removed, contents are missing\n", nodePath)
+				changed = true
+				continue
+			}
+
+		case node.Subtree != nil:
+			subtreeID, subtreeChanged, err := r.repairTree(ctx, nodePath, *node.Subtree)
+			if err != nil {
+				return restic.ID{}, false, err
+			}
+
+			if subtreeChanged {
+				node.Subtree = &subtreeID
+				changed = true
+			}
+		}
+
+		err = newTree.Insert(node)
+		if err != nil {
+			return restic.ID{}, false, err
+		}
+	}
+
+	newID = id
+	if changed {
+		newID, err = r.saveTree(ctx, newTree)
+		if err != nil {
+			return restic.ID{}, false, err
+		}
+	}
+
+	r.repaired[id] = newID
+	return newID, changed, nil
+}
+
+func runRepairSnapshots(opts RepairSnapshotsOptions, gopts GlobalOptions, args []string) error {
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockRepoExclusive(repo)
+	defer unlockRepo(lock)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	Verbosef("loading indexes\n")
+	if err = repo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	Verbosef("listing pack files\n")
+	packs := restic.NewIDSet()
+	err = repo.List(ctx, restic.DataFile, func(id restic.ID, size int64) error {
+		packs.Insert(id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r := &treeRepairer{
+		repo:     repo,
+		dryRun:   opts.DryRun,
+		packs:    packs,
+		repaired: make(map[restic.ID]restic.ID),
+	}
+
+	var repaired []*restic.Snapshot
+	for sn := range FindFilteredSnapshots(ctx, repo, opts.Host, opts.Tags, opts.Paths, args) {
+		Verbosef("checking snapshot %s of %v at %s\n", sn.ID().Str(), sn.Paths, sn.Time)
+
+		treeID, changed, err := r.repairTree(ctx, "/", *sn.Tree)
+		if err != nil {
+			return err
+		}
+
+		if !changed {
+			continue
+		}
+
+		Printf("snapshot %s needs to be repaired\n", sn.ID().Str())
+		repaired = append(repaired, sn)
+		sn.Tree = &treeID
+	}
+
+	if len(repaired) == 0 {
+		Printf("no snapshots need to be repaired\n")
+		return nil
+	}
+
+	if opts.DryRun {
+		Printf("would repair %d snapshots\n", len(repaired))
+		return nil
+	}
+
+	if err = saveRewrittenSnapshots(ctx, repo, repaired, opts.Forget); err != nil {
+		return err
+	}
+
+	Printf("repaired %d snapshots\n", len(repaired))
+	return nil
+}
+
+// saveRewrittenSnapshots saves the modified snapshots as new snapshots, after
+// flushing all trees saved for them. With forget, the original snapshots are
+// removed.
+func saveRewrittenSnapshots(ctx context.Context, repo *repository.Repository, snapshots []*restic.Snapshot, forget bool) error {
+	if err := repo.Flush(ctx); err != nil {
+		return err
+	}
+
+	if err := repo.SaveIndex(ctx); err != nil {
+		return err
+	}
+
+	for _, sn := range snapshots {
+		oldID := *sn.ID()
+
+		// remember which snapshot this one is a modified version of
+		if sn.Original == nil {
+			sn.Original = sn.ID()
+		}
+
+		id, err := repo.SaveJSONUnpacked(ctx, restic.SnapshotFile, sn)
+		if err != nil {
+			return err
+		}
+		Verbosef("snapshot %s saved as %s\n", oldID.Str(), id.Str())
+
+		if forget {
+			h := restic.Handle{Type: restic.SnapshotFile, Name: oldID.String()}
+			if err = repo.Backend().Remove(ctx, h); err != nil {
+				return err
+			}
+			Verbosef("removed snapshot %s\n", oldID.Str())
+		}
+	}
+
+	return nil
+}
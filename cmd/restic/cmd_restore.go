@@ -1,6 +1,11 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/filter"
@@ -20,6 +25,10 @@ a directory.
 
 The special snapshot "latest" can be used to restore the latest snapshot in the
 repository.
+
+The progress of the restore is shown on the terminal. With --json, status
+messages with the progress are printed every second, and a summary of the
+restore is printed as a JSON object at the end.
 `,
 	DisableAutoGenTag: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -38,10 +47,49 @@ type RestoreOptions struct {
 	Paths              []string
 	Tags               restic.TagLists
 	Verify             bool
+	Sparse             bool
+	ExcludeXattrs      []string
 }
 
 var restoreOptions RestoreOptions
 
+// selectXattrsByPattern returns a function which selects the extended
+// attributes whose name does not match one of the patterns.
+func selectXattrsByPattern(patterns []string) func(name string) bool {
+	return func(name string) bool {
+		for _, pattern := range patterns {
+			if matched, _ := filepath.Match(pattern, name); matched {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// restoreSummary is printed at the end of a restore with --json.
+type restoreSummary struct {
+	MessageType   string  `json:"message_type"` // "summary"
+	SnapshotID    string  `json:"snapshot_id"`
+	Target        string  `json:"target"`
+	FilesRestored uint64  `json:"files_restored"`
+	DirsRestored  uint64  `json:"dirs_restored"`
+	BytesRestored uint64  `json:"bytes_restored"`
+	FilesVerified int     `json:"files_verified,omitempty"`
+	Errors        int     `json:"errors"`
+	TotalDuration float64 `json:"total_duration"` // in seconds
+}
+
+// restoreStatus is printed every second during a restore with --json.
+type restoreStatus struct {
+	MessageType      string  `json:"message_type"` // "status"
+	SecondsElapsed   uint64  `json:"seconds_elapsed"`
+	SecondsRemaining uint64  `json:"seconds_remaining,omitempty"`
+	PercentDone      float64 `json:"percent_done"`
+	FilesRestored    uint64  `json:"files_restored"`
+	TotalBytes       uint64  `json:"total_bytes"`
+	BytesRestored    uint64  `json:"bytes_restored"`
+}
+
 func init() {
 	cmdRoot.AddCommand(cmdRestore)
 
@@ -56,6 +104,75 @@ func init() {
 	flags.Var(&restoreOptions.Tags, "tag", "only consider snapshots which include this `taglist` for snapshot ID \"latest\"")
 	flags.StringArrayVar(&restoreOptions.Paths, "path", nil, "only consider snapshots which include this (absolute) `path` for snapshot ID \"latest\"")
 	flags.BoolVar(&restoreOptions.Verify, "verify", false, "verify restored files content")
+	flags.StringArrayVar(&restoreOptions.ExcludeXattrs, "exclude-xattr", nil, "do not restore extended attributes matching `pattern`, e.g. \"security.*\" (can be specified multiple times)")
+	flags.BoolVar(&restoreOptions.Sparse, "sparse", false, "restore files as sparse files, blocks which only contain zeros are not written")
+}
+
+// newRestoreProgress returns a progress which shows how much of the files'
+// content has been restored by res, or prints it as status messages with
+// --json.
+func newRestoreProgress(gopts GlobalOptions, res *restorer.Restorer) *restic.Progress {
+	if gopts.Quiet {
+		return nil
+	}
+
+	var total restorer.Stats
+	var p *restic.Progress
+
+	if gopts.JSON {
+		enc := json.NewEncoder(gopts.stdout)
+		p = restic.NewProgressInterval(time.Second)
+		p.OnUpdate = func(s restic.Stat, d time.Duration, ticker bool) {
+			status := restoreStatus{
+				MessageType:      "status",
+				SecondsElapsed:   uint64(d / time.Second),
+				SecondsRemaining: estimateRemaining(s.Bytes, total.Bytes, d),
+				PercentDone:      1,
+				FilesRestored:    s.Files,
+				TotalBytes:       total.Bytes,
+				BytesRestored:    s.Bytes,
+			}
+			if total.Bytes > 0 {
+				status.PercentDone = float64(s.Bytes) / float64(total.Bytes)
+			}
+
+			err := enc.Encode(status)
+			if err != nil {
+				Warnf("JSON encode failed: %v\n", err)
+			}
+		}
+
+		// Done only prints the final status when OnDone is set
+		p.OnDone = func(s restic.Stat, d time.Duration, ticker bool) {}
+	} else {
+		p = restic.NewProgress()
+		p.OnUpdate = func(s restic.Stat, d time.Duration, ticker bool) {
+			status := fmt.Sprintf("[%s] %s  %d files %s / %s",
+				formatDuration(d),
+				formatPercent(s.Bytes, total.Bytes),
+				s.Files, formatBytes(s.Bytes), formatBytes(total.Bytes))
+
+			if eta := estimateRemaining(s.Bytes, total.Bytes, d); eta > 0 {
+				status += fmt.Sprintf(", %s, ETA %s", formatRate(s.Bytes, d), formatSeconds(eta))
+			}
+
+			if w := stdoutTerminalWidth(); w > 0 {
+				status = shortenStatus(w, status)
+			}
+
+			PrintProgress("%s", status)
+		}
+
+		p.OnDone = func(s restic.Stat, d time.Duration, ticker bool) {
+			fmt.Printf("\n")
+		}
+	}
+
+	p.OnStart = func() {
+		total = res.Stats()
+	}
+
+	return p
 }
 
 func runRestore(opts RestoreOptions, gopts GlobalOptions, args []string) error {
@@ -86,6 +203,12 @@ func runRestore(opts RestoreOptions, gopts GlobalOptions, args []string) error {
 		return errors.Fatal("exclude and include patterns are mutually exclusive")
 	}
 
+	for _, pattern := range opts.ExcludeXattrs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return errors.Fatalf("invalid pattern %q for --exclude-xattr: %v", pattern, err)
+		}
+	}
+
 	snapshotIDString := args[0]
 
 	debug.Log("restore %v to %v", snapshotIDString, opts.Target)
@@ -127,6 +250,12 @@ func runRestore(opts RestoreOptions, gopts GlobalOptions, args []string) error {
 		Exitf(2, "creating restorer failed: %v\n", err)
 	}
 
+	res.Sparse = opts.Sparse
+
+	if len(opts.ExcludeXattrs) > 0 {
+		res.XattrSelectFilter = selectXattrsByPattern(opts.ExcludeXattrs)
+	}
+
 	totalErrors := 0
 	res.Error = func(location string, err error) error {
 		Warnf("ignoring error for %s: %s\n", location, err)
@@ -178,14 +307,46 @@ func runRestore(opts RestoreOptions, gopts GlobalOptions, args []string) error {
 		res.SelectFilter = selectIncludeFilter
 	}
 
-	Verbosef("restoring %s to %s\n", res.Snapshot(), opts.Target)
+	if !gopts.JSON {
+		Verbosef("restoring %s to %s\n", res.Snapshot(), opts.Target)
+	}
+
+	res.Progress = newRestoreProgress(gopts, res)
 
+	start := time.Now()
+	var verified int
 	err = res.RestoreTo(ctx, opts.Target)
 	if err == nil && opts.Verify {
-		Verbosef("verifying files in %s\n", opts.Target)
-		var count int
-		count, err = res.VerifyFiles(ctx, opts.Target)
-		Verbosef("finished verifying %d files in %s\n", count, opts.Target)
+		if !gopts.JSON {
+			Verbosef("verifying files in %s\n", opts.Target)
+		}
+		verified, err = res.VerifyFiles(ctx, opts.Target)
+		if !gopts.JSON {
+			Verbosef("finished verifying %d files in %s\n", verified, opts.Target)
+		}
+	}
+
+	if gopts.JSON {
+		if err != nil {
+			return err
+		}
+
+		stats := res.Stats()
+		return json.NewEncoder(gopts.stdout).Encode(restoreSummary{
+			MessageType:   "summary",
+			SnapshotID:    id.String(),
+			Target:        opts.Target,
+			FilesRestored: stats.Files,
+			DirsRestored:  stats.Dirs,
+			BytesRestored: stats.Bytes,
+			FilesVerified: verified,
+			Errors:        totalErrors,
+			TotalDuration: time.Since(start).Seconds(),
+		})
+	}
+
+	if stats := formatTransferStats(repo); stats != "" {
+		Verbosef("%s\n", stats)
 	}
 	if totalErrors > 0 {
 		Printf("There were %d errors\n", totalErrors)
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSelectXattrsByPattern(t *testing.T) {
+	selectXattr := selectXattrsByPattern([]string{"security.*", "trusted.*", "user.secret"})
+
+	var tests = []struct {
+		name     string
+		selected bool
+	}{
+		{"user.comment", true},
+		{"user.secret", false},
+		{"user.secret2", true},
+		{"security.selinux", false},
+		{"security.capability", false},
+		{"trusted.overlay.opaque", false},
+		{"system.posix_acl_access", true},
+	}
+
+	for _, test := range tests {
+		if selectXattr(test.name) != test.selected {
+			t.Errorf("wrong result for %q, want selected = %v", test.name, test.selected)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"path"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdRewrite = &cobra.Command{
+	Use:   "rewrite [flags] [snapshot-ID ...]",
+	Short: "Remove files from existing snapshots",
+	Long: `
+The "rewrite" command removes files and directories matching the exclude
+patterns from existing snapshots, e.g. secrets or large directories which were
+backed up by mistake. The patterns are matched against the full path of the
+files in the snapshot, the same as for the "backup" command.
+
+A modified snapshot is saved as a new snapshot, the original snapshot is only
+removed when --forget is given. Snapshots which do not contain matching files
+are left alone. Run "restic prune" afterwards to remove the data which is no
+longer referenced.
+
+When no snapshot-ID is given, all snapshots matching the host, tag and path
+filter criteria are rewritten.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runRewrite(rewriteOptions, globalOptions, args)
+	},
+}
+
+// RewriteOptions collects all options for the rewrite command.
+type RewriteOptions struct {
+	Forget bool
+	DryRun bool
+
+	Excludes            []string
+	InsensitiveExcludes []string
+	ExcludeFiles        []string
+
+	Host  string
+	Tags  restic.TagLists
+	Paths []string
+}
+
+var rewriteOptions RewriteOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdRewrite)
+
+	f := cmdRewrite.Flags()
+	f.BoolVar(&rewriteOptions.Forget, "forget", false, "remove the original snapshots after saving the rewritten ones")
+	f.BoolVarP(&rewriteOptions.DryRun, "dry-run", "n", false, "only report what would be removed, do not modify the repository")
+
+	f.StringArrayVarP(&rewriteOptions.Excludes, "exclude", "e", nil, "exclude a `pattern` (can be specified multiple times)")
+	f.StringArrayVar(&rewriteOptions.InsensitiveExcludes, "iexclude", nil, "same as `--exclude` but ignores the casing of filenames")
+	f.StringArrayVar(&rewriteOptions.ExcludeFiles, "exclude-file", nil, "read exclude patterns from a `file` (can be specified multiple times)")
+
+	f.StringVarP(&rewriteOptions.Host, "host", "H", "", "only consider snapshots for this `host`, when no snapshot-ID is given")
+	f.Var(&rewriteOptions.Tags, "tag", "only consider snapshots which include this `taglist`, when no snapshot-ID is given")
+	f.StringArrayVar(&rewriteOptions.Paths, "path", nil, "only consider snapshots which include this (absolute) `path`, when no snapshot-ID is given")
+}
+
+// collectRewriteRejectFuncs returns the functions which select the files to
+// remove from the snapshots.
+func collectRewriteRejectFuncs(opts RewriteOptions) (fs []RejectByNameFunc, err error) {
+	excludes := opts.Excludes
+	if len(opts.ExcludeFiles) > 0 {
+		patterns, err := readExcludePatternsFromFiles(opts.ExcludeFiles)
+		if err != nil {
+			return nil, err
+		}
+		excludes = append(excludes, patterns...)
+	}
+
+	if len(opts.InsensitiveExcludes) > 0 {
+		fs = append(fs, rejectByInsensitivePattern(opts.InsensitiveExcludes))
+	}
+
+	if len(excludes) > 0 {
+		fs = append(fs, rejectByPattern(excludes))
+	}
+
+	return fs, nil
+}
+
+// treeRewriter removes the nodes selected by the reject functions from trees.
+type treeRewriter struct {
+	repo    *repository.Repository
+	dryRun  bool
+	rejects []RejectByNameFunc
+
+	// rewritten maps the trees which have already been processed to their
+	// new IDs, the same tree may be stored at several paths
+	rewritten map[rewrittenTree]rewriteResult
+}
+
+type rewrittenTree struct {
+	path string
+	id   restic.ID
+}
+
+// rewriteResult is the result of rewriting a tree. In dry-run mode, the ID is
+// not changed, so whether nodes were removed is stored separately.
+type rewriteResult struct {
+	id      restic.ID
+	changed bool
+}
+
+// rejected returns true if the file at the path must be removed.
+func (r *treeRewriter) rejected(item string) bool {
+	for _, reject := range r.rejects {
+		if reject(item) {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteTree returns the ID of a tree which contains all nodes of the tree id
+// below dir which are not rejected. Changed is false if nothing was removed.
+func (r *treeRewriter) rewriteTree(ctx context.Context, dir string, id restic.ID) (newID restic.ID, changed bool, err error) {
+	key := rewrittenTree{path: dir, id: id}
+	if res, ok := r.rewritten[key]; ok {
+		return res.id, res.changed, nil
+	}
+
+	tree, err := r.repo.LoadTree(ctx, id)
+	if err != nil {
+		return restic.ID{}, false, err
+	}
+
+	newTree := restic.NewTree()
+	for _, node := range tree.Nodes {
+		nodePath := path.Join(dir, node.Name)
+
+		if r.rejected(nodePath) {
+			Printf("  removing %q\n", nodePath)
+			changed = true
+			continue
+		}
+
+		if node.Subtree != nil {
+			subtreeID, subtreeChanged, err := r.rewriteTree(ctx, nodePath, *node.Subtree)
+			if err != nil {
+				return restic.ID{}, false, err
+			}
+
+			if subtreeChanged {
+				node.Subtree = &subtreeID
+				changed = true
+			}
+		}
+
+		err = newTree.Insert(node)
+		if err != nil {
+			return restic.ID{}, false, err
+		}
+	}
+
+	newID = id
+	if changed && !r.dryRun {
+		newID, err = r.repo.SaveTree(ctx, newTree)
+		if err != nil {
+			return restic.ID{}, false, err
+		}
+	}
+
+	r.rewritten[key] = rewriteResult{id: newID, changed: changed}
+	return newID, changed, nil
+}
+
+func runRewrite(opts RewriteOptions, gopts GlobalOptions, args []string) error {
+	rejects, err := collectRewriteRejectFuncs(opts)
+	if err != nil {
+		return err
+	}
+
+	if len(rejects) == 0 {
+		return errors.Fatal("nothing to remove, please specify exclude patterns")
+	}
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	// removing snapshots requires an exclusive lock
+	var lock *restic.Lock
+	if opts.Forget && !opts.DryRun {
+		lock, err = lockRepoExclusive(repo)
+	} else {
+		lock, err = lockRepo(repo)
+	}
+	defer unlockRepo(lock)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	if err = repo.LoadIndex(ctx); err != nil {
+		return err
+	}
+
+	r := &treeRewriter{
+		repo:      repo,
+		dryRun:    opts.DryRun,
+		rejects:   rejects,
+		rewritten: make(map[rewrittenTree]rewriteResult),
+	}
+
+	var rewritten []*restic.Snapshot
+	for sn := range FindFilteredSnapshots(ctx, repo, opts.Host, opts.Tags, opts.Paths, args) {
+		Verbosef("checking snapshot %s of %v at %s\n", sn.ID().Str(), sn.Paths, sn.Time)
+
+		treeID, changed, err := r.rewriteTree(ctx, "/", *sn.Tree)
+		if err != nil {
+			return err
+		}
+
+		if !changed {
+			continue
+		}
+
+		Printf("snapshot %s needs to be rewritten\n", sn.ID().Str())
+		rewritten = append(rewritten, sn)
+		sn.Tree = &treeID
+	}
+
+	if len(rewritten) == 0 {
+		Printf("no snapshots contain files to remove\n")
+		return nil
+	}
+
+	if opts.DryRun {
+		Printf("would rewrite %d snapshots\n", len(rewritten))
+		return nil
+	}
+
+	if err = saveRewrittenSnapshots(ctx, repo, rewritten, opts.Forget); err != nil {
+		return err
+	}
+
+	Printf("rewrote %d snapshots\n", len(rewritten))
+	return nil
+}
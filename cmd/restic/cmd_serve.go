@@ -0,0 +1,136 @@
+package main
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restserver"
+)
+
+var cmdServe = &cobra.Command{
+	Use:   "serve [flags] DIR",
+	Short: "Serve repositories over the REST protocol",
+	Long: `
+The "serve" command starts a server which makes the repositories stored below
+DIR available to other restic clients over HTTP, using the REST protocol. A
+repository in DIR/name is accessed by the clients as rest:http://host:8000/name.
+
+Clients authenticate with the credentials from an htpasswd file, passwords must
+be hashed with bcrypt (htpasswd -B), MD5 (htpasswd -m) or SHA1 (htpasswd -s).
+With --private-repos, each user can only access the repositories below the
+directory with the user's name. Authentication can be disabled with --no-auth.
+
+With --append-only, clients can add data, but cannot remove anything from the
+repositories except locks. Existing files are never overwritten, and files
+larger than 1 GiB are rejected. With --max-repo-size, the server refuses to
+store files once a repository would exceed the given size.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runServe(serveOptions, globalOptions, args)
+	},
+}
+
+// ServeOptions collects all options for the serve command.
+type ServeOptions struct {
+	Listen       string
+	HtpasswdFile string
+	NoAuth       bool
+	AppendOnly   bool
+	PrivateRepos bool
+	MaxRepoSize  string
+	TLSCert      string
+	TLSKey       string
+}
+
+var serveOptions ServeOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdServe)
+
+	f := cmdServe.Flags()
+	f.StringVar(&serveOptions.Listen, "listen", ":8000", "listen on this `address`")
+	f.StringVar(&serveOptions.HtpasswdFile, "htpasswd-file", "", "read the credentials of the clients from `file`")
+	f.BoolVar(&serveOptions.NoAuth, "no-auth", false, "allow access without authentication")
+	f.BoolVar(&serveOptions.AppendOnly, "append-only", false, "do not allow removing data from the repositories, except locks")
+	f.BoolVar(&serveOptions.PrivateRepos, "private-repos", false, "users can only access the repositories below the directory with their name")
+	f.StringVar(&serveOptions.MaxRepoSize, "max-repo-size", "", "refuse to store data in a repository which would grow beyond `size` (allowed suffixes: k/K, m/M, g/G, t/T)")
+	f.StringVar(&serveOptions.TLSCert, "tls-cert", "", "use TLS with the certificate in `file`")
+	f.StringVar(&serveOptions.TLSKey, "tls-key", "", "use TLS with the private key in `file`")
+}
+
+func runServe(opts ServeOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 1 {
+		return errors.Fatal("please specify the directory which contains the repositories")
+	}
+
+	if opts.HtpasswdFile == "" && !opts.NoAuth {
+		return errors.Fatal("please specify --htpasswd-file, or --no-auth to disable authentication")
+	}
+
+	if opts.HtpasswdFile != "" && opts.NoAuth {
+		return errors.Fatal("--htpasswd-file and --no-auth cannot be used together")
+	}
+
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return errors.Fatal("--tls-cert and --tls-key must be used together")
+	}
+
+	cfg := restserver.Config{
+		Path:         args[0],
+		AppendOnly:   opts.AppendOnly,
+		PrivateRepos: opts.PrivateRepos,
+	}
+
+	if opts.MaxRepoSize != "" {
+		size, err := parseSizeStr(opts.MaxRepoSize)
+		if err != nil {
+			return errors.Fatalf("invalid --max-repo-size: %v", err)
+		}
+		cfg.MaxRepoSize = size
+	}
+
+	if opts.HtpasswdFile != "" {
+		users, err := restserver.LoadHtpasswd(opts.HtpasswdFile)
+		if err != nil {
+			return errors.Fatalf("unable to read htpasswd file: %v", err)
+		}
+		cfg.Users = users
+	}
+
+	srv, err := restserver.New(cfg)
+	if err != nil {
+		return errors.Fatal(err.Error())
+	}
+
+	listener, err := net.Listen("tcp", opts.Listen)
+	if err != nil {
+		return errors.Fatalf("unable to listen: %v", err)
+	}
+
+	server := &http.Server{Handler: srv}
+	go func() {
+		<-gopts.ctx.Done()
+		_ = server.Close()
+	}()
+
+	scheme := "http"
+	if opts.TLSCert != "" {
+		scheme = "https"
+	}
+	Verbosef("serving repositories in %v on %v://%v\n", args[0], scheme, listener.Addr())
+
+	if opts.TLSCert != "" {
+		err = server.ServeTLS(listener, opts.TLSCert, opts.TLSKey)
+	} else {
+		err = server.Serve(listener)
+	}
+
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 	"github.com/restic/restic/internal/ui/table"
 	"github.com/spf13/cobra"
@@ -32,6 +33,7 @@ type SnapshotOptions struct {
 	Paths   []string
 	Compact bool
 	Last    bool
+	Latest  int
 	GroupBy string
 }
 
@@ -46,10 +48,20 @@ func init() {
 	f.StringArrayVar(&snapshotOptions.Paths, "path", nil, "only consider snapshots for this `path` (can be specified multiple times)")
 	f.BoolVarP(&snapshotOptions.Compact, "compact", "c", false, "use compact format")
 	f.BoolVar(&snapshotOptions.Last, "last", false, "only show the last snapshot for each host and path")
+	f.IntVar(&snapshotOptions.Latest, "latest", 0, "only show the last `n` snapshots for each host and path")
 	f.StringVarP(&snapshotOptions.GroupBy, "group-by", "g", "", "string for grouping snapshots by host,paths,tags")
 }
 
 func runSnapshots(opts SnapshotOptions, gopts GlobalOptions, args []string) error {
+	if opts.Latest < 0 {
+		return errors.Fatal("--latest must not be negative")
+	}
+
+	// --last is the same as --latest 1
+	if opts.Last && opts.Latest == 0 {
+		opts.Latest = 1
+	}
+
 	repo, err := OpenRepository(gopts)
 	if err != nil {
 		return err
@@ -76,8 +88,8 @@ func runSnapshots(opts SnapshotOptions, gopts GlobalOptions, args []string) erro
 	}
 
 	for k, list := range snapshotGroups {
-		if opts.Last {
-			list = FilterLastSnapshots(list)
+		if opts.Latest > 0 {
+			list = FilterLastSnapshots(list, opts.Latest)
 		}
 		sort.Sort(sort.Reverse(list))
 		snapshotGroups[k] = list
@@ -120,21 +132,21 @@ func newFilterLastSnapshotsKey(sn *restic.Snapshot) filterLastSnapshotsKey {
 	return filterLastSnapshotsKey{sn.Hostname, strings.Join(paths, "|")}
 }
 
-// FilterLastSnapshots filters a list of snapshots to only return the last
-// entry for each hostname and path. If the snapshot contains multiple paths,
+// FilterLastSnapshots filters a list of snapshots to only return the last n
+// entries for each hostname and path. If the snapshot contains multiple paths,
 // they will be joined and treated as one item.
-func FilterLastSnapshots(list restic.Snapshots) restic.Snapshots {
+func FilterLastSnapshots(list restic.Snapshots, n int) restic.Snapshots {
 	// Sort the snapshots so that the newer ones are listed first
 	sort.SliceStable(list, func(i, j int) bool {
 		return list[i].Time.After(list[j].Time)
 	})
 
 	var results restic.Snapshots
-	seen := make(map[filterLastSnapshotsKey]bool)
+	seen := make(map[filterLastSnapshotsKey]int)
 	for _, sn := range list {
 		key := newFilterLastSnapshotsKey(sn)
-		if !seen[key] {
-			seen[key] = true
+		if seen[key] < n {
+			seen[key]++
 			results = append(results, sn)
 		}
 	}
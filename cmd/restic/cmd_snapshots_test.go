@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestFilterLastSnapshots(t *testing.T) {
+	start := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	var list restic.Snapshots
+	for i, host := range []string{"foo", "bar", "foo", "foo", "bar"} {
+		sn, err := restic.NewSnapshot([]string{"/home"}, nil, host, start.Add(time.Duration(i)*time.Hour))
+		rtest.OK(t, err)
+		list = append(list, sn)
+	}
+
+	var tests = []struct {
+		n    int
+		want []int
+	}{
+		{1, []int{4, 3}},
+		{2, []int{4, 3, 2, 1}},
+		{10, []int{4, 3, 2, 1, 0}},
+	}
+
+	for _, test := range tests {
+		// FilterLastSnapshots sorts the list
+		input := append(restic.Snapshots{}, list...)
+
+		var want restic.Snapshots
+		for _, i := range test.want {
+			want = append(want, list[i])
+		}
+
+		rtest.Equals(t, want, FilterLastSnapshots(input, test.n))
+	}
+}
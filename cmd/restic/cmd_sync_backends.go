@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+
+	"github.com/spf13/cobra"
+)
+
+var cmdSyncBackends = &cobra.Command{
+	Use:   "sync-backends",
+	Short: "Move files from the failover location back to the repository",
+	Long: `
+The "sync-backends" command reconciles the repository with the location given
+by --failover. Files which were saved to the failover location while the
+repository was unavailable are copied to the repository and then removed from
+the failover location. Keys and the config are kept in both locations.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSyncBackends(globalOptions)
+	},
+}
+
+func init() {
+	cmdRoot.AddCommand(cmdSyncBackends)
+}
+
+// copyFile copies the file h from src to dst.
+func copyFile(ctx context.Context, src, dst restic.Backend, h restic.Handle) error {
+	buf, err := backend.LoadAll(ctx, nil, src, h)
+	if err != nil {
+		return err
+	}
+
+	return dst.Save(ctx, h, restic.NewByteReader(buf))
+}
+
+// copyMissing copies the file h from src to dst unless it is already there.
+func copyMissing(ctx context.Context, src, dst restic.Backend, h restic.Handle) error {
+	found, err := dst.Test(ctx, h)
+	if err != nil || found {
+		return err
+	}
+
+	Verbosef("copy %v to %v\n", h, dst.Location())
+	return copyFile(ctx, src, dst, h)
+}
+
+func runSyncBackends(gopts GlobalOptions) error {
+	if gopts.Failover == "" {
+		return errors.Fatal("no failover location specified (--failover)")
+	}
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	lock, err := lockRepoExclusive(repo)
+	defer unlockRepo(lock)
+	if err != nil {
+		return err
+	}
+
+	primary, err := open(gopts.Repo, gopts, gopts.extended)
+	if err != nil {
+		return err
+	}
+
+	secondary, err := open(gopts.Failover, gopts, gopts.extended)
+	if err != nil {
+		return err
+	}
+
+	ctx := gopts.ctx
+
+	// the config and the keys are needed in both locations
+	cfg := restic.Handle{Type: restic.ConfigFile}
+	for _, pair := range [][2]restic.Backend{{primary, secondary}, {secondary, primary}} {
+		src, dst := pair[0], pair[1]
+
+		found, err := src.Test(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		if found {
+			err = copyMissing(ctx, src, dst, cfg)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = src.List(ctx, restic.KeyFile, func(fi restic.FileInfo) error {
+			return copyMissing(ctx, src, dst, restic.Handle{Type: restic.KeyFile, Name: fi.Name})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// all other files are moved to the repository, locks are left alone
+	moved := 0
+	for _, t := range []restic.FileType{restic.DataFile, restic.IndexFile, restic.SnapshotFile} {
+		err = secondary.List(ctx, t, func(fi restic.FileInfo) error {
+			h := restic.Handle{Type: t, Name: fi.Name}
+			err := copyMissing(ctx, secondary, primary, h)
+			if err != nil {
+				return err
+			}
+
+			moved++
+			return secondary.Remove(ctx, h)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	Verbosef("moved %d files from %v to %v\n", moved, secondary.Location(), primary.Location())
+	return nil
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/restic/chunker"
+	"github.com/spf13/cobra"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/fs"
+	"github.com/restic/restic/internal/restic"
+)
+
+var cmdVerify = &cobra.Command{
+	Use:   "verify [flags] snapshotID dir",
+	Short: "Compare a snapshot with the files in a directory",
+	Long: `
+The "verify" command compares the directory dir with the same directory in a
+snapshot, without restoring anything. The content of the files is compared by
+splitting them into chunks and comparing the chunks' hashes with the snapshot,
+so no data needs to be downloaded from the repository.
+
+Each difference is printed with the path of the item in dir and one of the
+following codes:
+
+* +  The item exists in dir, but not in the snapshot
+* -  The item exists in the snapshot, but not in dir
+* M  The file's content is different
+* T  The type is different, e.g. a file was replaced by a symlink
+* U  The metadata (access mode, modification time, owner) is different
+
+Use --snapshot-dir to compare dir with a different directory in the snapshot,
+e.g. when the snapshot was restored to another location. The special snapshot
+"latest" can be used to compare with the latest snapshot in the repository.
+
+The command exits with an error if any differences were found.
+`,
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runVerify(verifyOptions, globalOptions, args)
+	},
+}
+
+// VerifyOptions collects all options for the verify command.
+type VerifyOptions struct {
+	SnapshotDir string
+
+	Host  string
+	Paths []string
+	Tags  restic.TagLists
+}
+
+var verifyOptions VerifyOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdVerify)
+
+	f := cmdVerify.Flags()
+	f.StringVar(&verifyOptions.SnapshotDir, "snapshot-dir", "", "compare with this `directory` in the snapshot instead of the absolute path of dir")
+
+	f.StringVarP(&verifyOptions.Host, "host", "H", "", `only consider snapshots for this host when the snapshot ID is "latest"`)
+	f.Var(&verifyOptions.Tags, "tag", "only consider snapshots which include this `taglist` for snapshot ID \"latest\"")
+	f.StringArrayVar(&verifyOptions.Paths, "path", nil, "only consider snapshots which include this (absolute) `path` for snapshot ID \"latest\"")
+}
+
+// snapshotPathComponents returns the names of the directories in the
+// snapshot which lead to the absolute path dir, e.g. "C", "Users" for
+// C:\Users on Windows.
+func snapshotPathComponents(dir string) []string {
+	var components []string
+
+	volume := filepath.VolumeName(dir)
+	if volume != "" {
+		// strip colon, the same as the archiver does
+		if len(volume) == 2 && volume[1] == ':' {
+			volume = volume[:1]
+		}
+		components = append(components, volume)
+	}
+
+	for _, name := range strings.Split(filepath.ToSlash(dir[len(filepath.VolumeName(dir)):]), "/") {
+		if name != "" {
+			components = append(components, name)
+		}
+	}
+
+	return components
+}
+
+// findSubtree returns the ID of the tree for the directory which is reached by
+// following the components from the tree id.
+func findSubtree(ctx context.Context, repo restic.Repository, id restic.ID, components []string) (restic.ID, error) {
+	for i, name := range components {
+		tree, err := repo.LoadTree(ctx, id)
+		if err != nil {
+			return restic.ID{}, err
+		}
+
+		item := "/" + path.Join(components[:i+1]...)
+
+		var node *restic.Node
+		for _, n := range tree.Nodes {
+			if n.Name == name {
+				node = n
+				break
+			}
+		}
+
+		if node == nil {
+			return restic.ID{}, errors.Errorf("path %q not found in snapshot", item)
+		}
+
+		if node.Type != "dir" || node.Subtree == nil {
+			return restic.ID{}, errors.Errorf("%q is not a directory in the snapshot", item)
+		}
+
+		id = *node.Subtree
+	}
+
+	return id, nil
+}
+
+// verifier compares the files in a directory with a tree in the repository.
+type verifier struct {
+	repo        restic.Repository
+	chunker     *chunker.Chunker
+	pol         chunker.Pol
+	buf         []byte
+	printChange func(*Change)
+
+	differences int
+	errors      int
+}
+
+func newVerifier(repo restic.Repository) *verifier {
+	pol := repo.Config().ChunkerPolynomial
+	return &verifier{
+		repo:    repo,
+		chunker: chunker.New(nil, pol),
+		pol:     pol,
+		buf:     make([]byte, chunker.MaxSize),
+	}
+}
+
+// sameContent returns true if splitting the file into chunks yields the
+// blobs of the node.
+func (v *verifier) sameContent(filename string, node *restic.Node) (bool, error) {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	v.chunker.Reset(f, v.pol)
+	for i := 0; ; i++ {
+		chunk, err := v.chunker.Next(v.buf)
+		if err == io.EOF {
+			return i == len(node.Content), nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if i >= len(node.Content) || !restic.Hash(chunk.Data).Equal(node.Content[i]) {
+			return false, nil
+		}
+	}
+}
+
+// sameMetadata returns true if the metadata of the nodes which is restored
+// from a snapshot is the same.
+func sameMetadata(node, other *restic.Node) bool {
+	return node.Mode == other.Mode &&
+		node.ModTime.Equal(other.ModTime) &&
+		node.UID == other.UID &&
+		node.GID == other.GID &&
+		node.LinkTarget == other.LinkTarget &&
+		node.Device == other.Device
+}
+
+func (v *verifier) report(item string, node *restic.Node, mod string) {
+	if node.Type == "dir" {
+		item += string(filepath.Separator)
+	}
+	v.differences++
+	v.printChange(NewChange(item, mod))
+}
+
+func (v *verifier) warn(item string, err error) {
+	Warnf("error for %v: %v\n", item, err)
+	v.errors++
+}
+
+// verifyDir compares the directory dir with the tree id.
+func (v *verifier) verifyDir(ctx context.Context, dir string, id restic.ID) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	tree, err := v.repo.LoadTree(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	entries, err := fs.ReadDirNames(&fs.Local{}, dir)
+	if err != nil {
+		v.warn(dir, err)
+		return nil
+	}
+
+	names := make(map[string]struct{})
+	treeNodes := make(map[string]*restic.Node)
+	for _, node := range tree.Nodes {
+		treeNodes[node.Name] = node
+		names[node.Name] = struct{}{}
+	}
+	for _, name := range entries {
+		names[name] = struct{}{}
+	}
+
+	sortedNames := make([]string, 0, len(names))
+	for name := range names {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		item := filepath.Join(dir, name)
+		snNode, inTree := treeNodes[name]
+
+		fi, err := fs.Lstat(item)
+		if err != nil && !os.IsNotExist(err) {
+			v.warn(item, err)
+			continue
+		}
+
+		if err != nil {
+			// the item may have been removed after reading the directory
+			if inTree {
+				v.report(item, snNode, "-")
+			}
+			continue
+		}
+
+		// errors for extended attributes are ignored, they are not compared
+		node, _ := restic.NodeFromFileInfo(item, fi)
+		if !inTree {
+			v.report(item, node, "+")
+			continue
+		}
+
+		mod := ""
+		if node.Type != snNode.Type {
+			mod += "T"
+		}
+
+		if node.Type == "file" && snNode.Type == "file" {
+			same := node.Size == snNode.Size
+			if same {
+				same, err = v.sameContent(item, snNode)
+				if err != nil {
+					v.warn(item, err)
+					continue
+				}
+			}
+
+			if !same {
+				mod += "M"
+			}
+		}
+
+		if mod == "" && !sameMetadata(node, snNode) {
+			mod += "U"
+		}
+
+		if mod != "" {
+			v.report(item, node, mod)
+		}
+
+		if node.Type == "dir" && snNode.Type == "dir" {
+			err = v.verifyDir(ctx, item, *snNode.Subtree)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func runVerify(opts VerifyOptions, gopts GlobalOptions, args []string) error {
+	if len(args) != 2 {
+		return errors.Fatal("please specify a snapshot ID and a directory")
+	}
+
+	dir, err := filepath.Abs(args[1])
+	if err != nil {
+		return errors.Wrap(err, "Abs")
+	}
+
+	fi, err := fs.Stat(dir)
+	if err != nil {
+		return errors.Fatalf("unable to read %v: %v", dir, err)
+	}
+	if !fi.IsDir() {
+		return errors.Fatalf("%v is not a directory", dir)
+	}
+
+	components := snapshotPathComponents(dir)
+	if opts.SnapshotDir != "" {
+		components = nil
+		for _, name := range strings.Split(opts.SnapshotDir, "/") {
+			if name != "" {
+				components = append(components, name)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	repo, err := OpenRepository(gopts)
+	if err != nil {
+		return err
+	}
+
+	if !gopts.NoLock {
+		lock, err := lockRepo(repo)
+		defer unlockRepo(lock)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = repo.LoadIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	var id restic.ID
+	if args[0] == "latest" {
+		id, err = restic.FindLatestSnapshot(ctx, repo, opts.Paths, opts.Tags, opts.Host)
+		if err != nil {
+			return errors.Fatalf("latest snapshot for criteria not found: %v", err)
+		}
+	} else {
+		id, err = restic.FindSnapshot(repo, args[0])
+		if err != nil {
+			return errors.Fatalf("invalid id %q: %v", args[0], err)
+		}
+	}
+
+	sn, err := restic.LoadSnapshot(ctx, repo, id)
+	if err != nil {
+		return err
+	}
+
+	if sn.Tree == nil {
+		return errors.Errorf("snapshot %v has nil tree", sn.ID().Str())
+	}
+
+	treeID, err := findSubtree(ctx, repo, *sn.Tree, components)
+	if err != nil {
+		return errors.Fatal(err.Error())
+	}
+
+	v := newVerifier(repo)
+	v.printChange = func(change *Change) {
+		Printf("%-5s%v\n", change.Modifier, change.Path)
+	}
+
+	if gopts.JSON {
+		enc := json.NewEncoder(gopts.stdout)
+		v.printChange = func(change *Change) {
+			err := enc.Encode(change)
+			if err != nil {
+				Warnf("JSON encode failed: %v\n", err)
+			}
+		}
+	} else {
+		Verbosef("comparing %v with /%v in snapshot %v\n\n", dir, path.Join(components...), sn.ID().Str())
+	}
+
+	err = v.verifyDir(ctx, dir, treeID)
+	if err != nil {
+		return err
+	}
+
+	if v.errors > 0 {
+		return errors.Fatalf("unable to compare %d items", v.errors)
+	}
+
+	if v.differences > 0 {
+		return errors.Fatalf("found %d differences", v.differences)
+	}
+
+	if !gopts.JSON {
+		Verbosef("no differences found\n")
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	tomb "gopkg.in/tomb.v2"
+
+	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/cache"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/ui/termstatus"
+)
+
+var cmdWatch = &cobra.Command{
+	Use:   "watch [flags] FILE/DIR [FILE/DIR] ...",
+	Short: "Create new snapshots whenever files are changed",
+	Long: `
+The "watch" command creates a snapshot of the files and directories given as
+the arguments, and then keeps running and watches them for changes. After a
+change, it waits until no further changes happened for the time given with
+--delay and creates a new snapshot. Files which are modified continuously are
+saved at least once within --max-delay.
+
+Detecting changes is only supported on Linux, where inotify is used. On all
+other systems (e.g. macOS, the BSDs and Windows), the command does not notice
+changes and --interval must be given.
+
+A new snapshot can be requested at any time by sending the signal SIGUSR1 to
+the process (not available on Windows). With --interval, a new snapshot is
+also created periodically, regardless of any changes.
+
+All options of the "backup" command can be used, except --stdin and --time.
+Failed backups are reported, the command continues to watch for changes.
+`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if watchOptions.Host == "" {
+			hostname, err := os.Hostname()
+			if err != nil {
+				debug.Log("os.Hostname() returned err: %v", err)
+				return
+			}
+			watchOptions.Host = hostname
+		}
+	},
+	DisableAutoGenTag: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var t tomb.Tomb
+		term := termstatus.New(globalOptions.stdout, globalOptions.stderr, globalOptions.Quiet)
+		t.Go(func() error { term.Run(t.Context(globalOptions.ctx)); return nil })
+
+		err := runWatch(watchOptions, globalOptions, term, args)
+		if err != nil {
+			return err
+		}
+		t.Kill(nil)
+		return t.Wait()
+	},
+}
+
+// WatchOptions collects all options for the watch command.
+type WatchOptions struct {
+	BackupOptions
+
+	Delay    time.Duration
+	MaxDelay time.Duration
+	Interval time.Duration
+}
+
+var watchOptions WatchOptions
+
+func init() {
+	cmdRoot.AddCommand(cmdWatch)
+
+	f := cmdWatch.Flags()
+	initBackupFlags(f, &watchOptions.BackupOptions)
+	f.DurationVar(&watchOptions.Delay, "delay", 30*time.Second, "wait until no files were changed for `duration` before creating a snapshot")
+	f.DurationVar(&watchOptions.MaxDelay, "max-delay", 10*time.Minute, "create a snapshot at most `duration` after the first change, even if files are still changing (0 to disable)")
+	f.DurationVar(&watchOptions.Interval, "interval", 0, "also create a snapshot every `duration`, regardless of changes")
+}
+
+// watchIgnoredPaths returns the directories which are written to by restic
+// itself, changes below them are not reported by the watcher.
+func watchIgnoredPaths(gopts GlobalOptions) []string {
+	var dirs []string
+
+	if !gopts.NoCache {
+		dir := gopts.CacheDir
+		if dir == "" {
+			var err error
+			dir, err = cache.DefaultDir()
+			if err != nil {
+				debug.Log("unable to find cache dir: %v", err)
+			}
+		}
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	loc, err := location.Parse(gopts.Repo)
+	if err == nil && loc.Scheme == "local" {
+		dirs = append(dirs, loc.Config.(local.Config).Path)
+	}
+
+	for i, dir := range dirs {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dirs[i] = abs
+		}
+	}
+
+	return dirs
+}
+
+func runWatch(opts WatchOptions, gopts GlobalOptions, term *termstatus.Terminal, args []string) error {
+	if opts.Stdin {
+		return errors.Fatal("--stdin cannot be used with the watch command")
+	}
+
+	for _, filename := range opts.FilesFrom {
+		if filename == "-" {
+			return errors.Fatal("--files-from - cannot be used with the watch command")
+		}
+	}
+
+	if opts.TimeStamp != "" {
+		return errors.Fatal("--time cannot be used with the watch command")
+	}
+
+	if opts.Delay < 0 || opts.MaxDelay < 0 || opts.Interval < 0 {
+		return errors.Fatal("durations must not be negative")
+	}
+
+	if opts.MaxDelay > 0 && opts.MaxDelay < opts.Delay {
+		return errors.Fatal("--max-delay must not be shorter than --delay")
+	}
+
+	targets, err := collectTargets(opts.BackupOptions, args)
+	if err != nil {
+		return err
+	}
+
+	for i, target := range targets {
+		targets[i], err = filepath.Abs(target)
+		if err != nil {
+			return errors.Wrap(err, "Abs")
+		}
+	}
+
+	ctx, cancel := context.WithCancel(gopts.ctx)
+	defer cancel()
+
+	changes, err := watchChanges(ctx, targets, watchIgnoredPaths(gopts))
+	if err != nil {
+		return err
+	}
+
+	if changes == nil && opts.Interval == 0 {
+		return errors.Fatal("watching for changes is not supported on this system, please use --interval")
+	}
+
+	var interval <-chan time.Time
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		interval = ticker.C
+	}
+
+	trigger := watchTriggerSignal()
+
+	// the first backup must succeed, otherwise the options are probably wrong
+	err = runBackup(opts.BackupOptions, gopts, term, args)
+	if err != nil {
+		return err
+	}
+
+	Verbosef("watching for changes\n")
+
+	// the timer is started on the first change and checks whether the files
+	// have stopped changing, first and last are the times of the first and
+	// the most recent change since the last backup
+	var (
+		timer       *time.Timer
+		timerC      <-chan time.Time
+		first, last time.Time
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-changes:
+			last = time.Now()
+			if timerC == nil {
+				first = last
+				timer = time.NewTimer(opts.Delay)
+				timerC = timer.C
+			}
+			continue
+
+		case <-timerC:
+			wait := opts.Delay - time.Since(last)
+			if opts.MaxDelay > 0 && time.Since(first)+wait > opts.MaxDelay {
+				wait = opts.MaxDelay - time.Since(first)
+			}
+
+			if wait > 0 {
+				timer.Reset(wait)
+				continue
+			}
+			Verbosef("files were changed, creating new snapshot\n")
+
+		case <-interval:
+			Verbosef("interval elapsed, creating new snapshot\n")
+
+		case <-trigger:
+			Verbosef("received signal, creating new snapshot\n")
+		}
+
+		if timer != nil {
+			timer.Stop()
+			timer, timerC = nil, nil
+		}
+
+		err = runBackup(opts.BackupOptions, gopts, term, args)
+		if err != nil {
+			Warnf("backup failed: %v\n", err)
+		}
+	}
+}
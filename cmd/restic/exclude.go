@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"github.com/restic/restic/internal/filter"
 	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/repository"
+	"github.com/restic/restic/internal/textfile"
 )
 
 type rejectionCache struct {
@@ -100,6 +102,132 @@ func rejectByInsensitivePattern(patterns []string) RejectByNameFunc {
 	}
 }
 
+// ignoreFileCache holds the patterns read from the ignore files in each
+// directory, directories without an ignore file map to nil.
+type ignoreFileCache struct {
+	fs   fs.FS
+	name string
+	m    map[string][]string
+	mtx  sync.Mutex
+}
+
+// patterns returns the patterns from the ignore file in dir.
+func (c *ignoreFileCache) patterns(dir string) []string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if patterns, ok := c.m[dir]; ok {
+		return patterns
+	}
+
+	var patterns []string
+	filename := c.fs.Join(dir, c.name)
+	fi, err := c.fs.Lstat(filename)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		Warnf("could not access ignore file: %v\n", err)
+	case fi.Mode().IsRegular():
+		patterns, err = c.read(filename)
+		if err != nil {
+			Warnf("could not read ignore file: %v\n", err)
+		}
+		debug.Log("read %d patterns from %v", len(patterns), filename)
+	}
+
+	c.m[dir] = patterns
+	return patterns
+}
+
+// read returns the patterns from the ignore file filename.
+func (c *ignoreFileCache) read(filename string) ([]string, error) {
+	f, err := c.fs.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadAll(f)
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadAll")
+	}
+
+	data, err = textfile.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseExcludePatterns(data)
+}
+
+// rejectByIgnoreFile returns a RejectByNameFunc which rejects files which
+// match a pattern from an ignore file called name in one of the directories
+// above the file. Only directories within the targets are searched for ignore
+// files, they are read from filesystem. The patterns are tested against the
+// path relative to the directory which contains the ignore file, so a leading
+// slash anchors a pattern at that directory.
+func rejectByIgnoreFile(filesystem fs.FS, name string, targets []string) (RejectByNameFunc, error) {
+	roots := make([]string, 0, len(targets))
+	for _, target := range targets {
+		root, err := filesystem.Abs(target)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+
+	cache := &ignoreFileCache{
+		fs:   filesystem,
+		name: name,
+		m:    make(map[string][]string),
+	}
+
+	return func(item string) bool {
+		item, err := filesystem.Abs(item)
+		if err != nil {
+			return false
+		}
+
+		// find the innermost target which contains the item
+		root := ""
+		for _, r := range roots {
+			if len(r) > len(root) && fs.HasPathPrefix(r, item) {
+				root = r
+			}
+		}
+		if root == "" {
+			return false
+		}
+
+		for dir := filesystem.Dir(item); fs.HasPathPrefix(root, dir); dir = filesystem.Dir(dir) {
+			patterns := cache.patterns(dir)
+			if len(patterns) > 0 {
+				rel, err := filepath.Rel(dir, item)
+				if err == nil {
+					matched, _, err := filter.List(patterns, filepath.Join(string(filepath.Separator), rel))
+					if err != nil {
+						Warnf("error for pattern in ignore file: %v\n", err)
+					}
+
+					if matched {
+						debug.Log("path %q excluded by ignore file in %v", item, dir)
+						return true
+					}
+				}
+			}
+
+			if dir == filesystem.Dir(dir) {
+				break
+			}
+		}
+
+		return false
+	}, nil
+}
+
 // rejectIfPresent returns a RejectByNameFunc which itself returns whether a path
 // should be excluded. The RejectByNameFunc considers a file to be excluded when
 // it resides in a directory with an exclusion file, that is specified by
@@ -269,6 +397,23 @@ func rejectByDevice(samples []string) (RejectFunc, error) {
 	}, nil
 }
 
+// rejectBySize returns a RejectFunc that rejects files which are larger than
+// maxSize bytes.
+func rejectBySize(maxSize int64) RejectFunc {
+	return func(item string, fi os.FileInfo) bool {
+		if fi == nil || !fi.Mode().IsRegular() {
+			return false
+		}
+
+		if fi.Size() > maxSize {
+			debug.Log("file %s is too large: %d > %d", item, fi.Size(), maxSize)
+			return true
+		}
+
+		return false
+	}
+}
+
 // rejectResticCache returns a RejectByNameFunc that rejects the restic cache
 // directory (if set).
 func rejectResticCache(repo *repository.Repository) (RejectByNameFunc, error) {
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/restic/restic/internal/fs"
 	"github.com/restic/restic/internal/test"
 )
 
@@ -189,3 +190,87 @@ func TestMultipleIsExcludedByFile(t *testing.T) {
 		}
 	}
 }
+
+func TestRejectByIgnoreFile(t *testing.T) {
+	tempDir, cleanup := test.TempDir(t)
+	defer cleanup()
+
+	files := []struct {
+		path string
+		incl bool
+	}{
+		{".resticignore", true},
+		{"foo.log", false},
+		{"build/output", false},
+		{"src/build/output", false},
+		{"src/main.go", true},
+		{"src/.resticignore", true},
+		{"src/main.o", false},
+		{"src/sub/main.o", false},
+		{"src/generated/file.go", false},
+		{"src/sub/generated/file.go", true},
+		{"other/main.o", true},
+	}
+
+	var errs []error
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		errs = append(errs, os.MkdirAll(filepath.Dir(p), 0700))
+		errs = append(errs, ioutil.WriteFile(p, []byte(f.path), 0600))
+	}
+	test.OKs(t, errs)
+
+	test.OK(t, ioutil.WriteFile(filepath.Join(tempDir, ".resticignore"), []byte("# comment\n*.log\nbuild\n"), 0600))
+	test.OK(t, ioutil.WriteFile(filepath.Join(tempDir, "src", ".resticignore"), []byte("*.o\n/generated\n"), 0600))
+
+	reject, err := rejectByIgnoreFile(fs.Local{}, ".resticignore", []string{tempDir})
+	test.OK(t, err)
+	for _, f := range files {
+		p := filepath.Join(tempDir, filepath.FromSlash(f.path))
+		if reject(p) == f.incl {
+			t.Errorf("inclusion status of %s is wrong: want %v", f.path, f.incl)
+		}
+	}
+
+	// ignore files above the target are not used
+	reject, err = rejectByIgnoreFile(fs.Local{}, ".resticignore", []string{filepath.Join(tempDir, "src")})
+	test.OK(t, err)
+	test.Assert(t, reject(filepath.Join(tempDir, "src", "main.o")), "src/main.o was not rejected")
+	test.Assert(t, !reject(filepath.Join(tempDir, "src", "foo.log")), "src/foo.log was rejected by an ignore file outside of the target")
+	test.Assert(t, !reject(filepath.Join(tempDir, "foo.log")), "foo.log outside of the target was rejected")
+}
+
+func TestRejectBySize(t *testing.T) {
+	tempDir, cleanup := test.TempDir(t)
+	defer cleanup()
+
+	files := []struct {
+		path string
+		size int64
+		incl bool
+	}{
+		{"empty", 0, true},
+		{"small", 1023, true},
+		{"limit", 1024, true},
+		{"large", 1025, false},
+		{"huge", 1 << 20, false},
+	}
+
+	reject := rejectBySize(1024)
+	for _, f := range files {
+		p := filepath.Join(tempDir, f.path)
+		test.OK(t, ioutil.WriteFile(p, make([]byte, f.size), 0600))
+
+		fi, err := os.Lstat(p)
+		test.OK(t, err)
+
+		if reject(p, fi) == f.incl {
+			t.Errorf("inclusion status of %s is wrong: want %v", f.path, f.incl)
+		}
+	}
+
+	// directories are never rejected
+	fi, err := os.Lstat(tempDir)
+	test.OK(t, err)
+	test.Assert(t, !reject(tempDir, fi), "directory %v was rejected", tempDir)
+}
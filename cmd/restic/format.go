@@ -3,8 +3,11 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"time"
 
+	"github.com/restic/restic/internal/errors"
+
 	"github.com/restic/restic/internal/restic"
 )
 
@@ -25,6 +28,42 @@ func formatBytes(c uint64) string {
 	}
 }
 
+// parseSizeStr parses a size such as "100M". The suffixes B, K, M, G and T
+// (case insensitive) select a unit of bytes, KiB, MiB, GiB and TiB,
+// respectively. Sizes without a suffix are taken as bytes.
+func parseSizeStr(s string) (uint64, error) {
+	if s == "" {
+		return 0, errors.New("expected size, got empty string")
+	}
+
+	num := s[:len(s)-1]
+	var unit uint64 = 1
+	switch s[len(s)-1] {
+	case 'b', 'B':
+	case 'k', 'K':
+		unit = 1 << 10
+	case 'm', 'M':
+		unit = 1 << 20
+	case 'g', 'G':
+		unit = 1 << 30
+	case 't', 'T':
+		unit = 1 << 40
+	default:
+		num = s
+	}
+
+	value, err := strconv.ParseUint(num, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid size %q", s)
+	}
+
+	if value > (1<<64-1)/unit {
+		return 0, errors.Errorf("size %q is too large", s)
+	}
+
+	return value * unit, nil
+}
+
 func formatSeconds(sec uint64) string {
 	hours := sec / 3600
 	sec -= hours * 3600
@@ -62,6 +101,17 @@ func formatDuration(d time.Duration) string {
 	return formatSeconds(sec)
 }
 
+// estimateRemaining returns the number of seconds it takes to process the
+// remaining part of total when done was processed in d. It returns zero when
+// nothing has been processed yet.
+func estimateRemaining(done, total uint64, d time.Duration) uint64 {
+	if done == 0 || done >= total {
+		return 0
+	}
+
+	return uint64(d.Seconds() * float64(total-done) / float64(done))
+}
+
 func formatNode(path string, n *restic.Node, long bool) string {
 	if !long {
 		return path
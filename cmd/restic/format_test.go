@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestParseSizeStr(t *testing.T) {
+	var tests = []struct {
+		s    string
+		size uint64
+	}{
+		{"1024", 1024},
+		{"1024b", 1024},
+		{"1024B", 1024},
+		{"1k", 1 << 10},
+		{"100K", 100 << 10},
+		{"100M", 100 << 20},
+		{"2g", 2 << 30},
+		{"10T", 10 << 40},
+	}
+
+	for _, test := range tests {
+		size, err := parseSizeStr(test.s)
+		rtest.OK(t, err)
+		rtest.Equals(t, test.size, size)
+	}
+}
+
+func TestParseSizeStrInvalid(t *testing.T) {
+	for _, s := range []string{"", "b", "-1k", "1.5G", "10x", "20000000T"} {
+		_, err := parseSizeStr(s)
+		rtest.Assert(t, err != nil, "expected error for %q not found", s)
+	}
+}
+
+func TestEstimateRemaining(t *testing.T) {
+	var tests = []struct {
+		done, total uint64
+		d           time.Duration
+		eta         uint64
+	}{
+		{0, 100, 10 * time.Second, 0},
+		{25, 100, 10 * time.Second, 30},
+		{50, 100, 10 * time.Second, 10},
+		{100, 100, 10 * time.Second, 0},
+		{120, 100, 10 * time.Second, 0},
+	}
+
+	for _, test := range tests {
+		eta := estimateRemaining(test.done, test.total, test.d)
+		if eta != test.eta {
+			t.Errorf("estimateRemaining(%d, %d, %v) = %d, want %d", test.done, test.total, test.d, eta, test.eta)
+		}
+	}
+}
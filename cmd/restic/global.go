@@ -13,16 +13,24 @@ import (
 	"time"
 
 	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/appendonly"
 	"github.com/restic/restic/internal/backend/azure"
 	"github.com/restic/restic/internal/backend/b2"
+	"github.com/restic/restic/internal/backend/failover"
 	"github.com/restic/restic/internal/backend/gs"
+	"github.com/restic/restic/internal/backend/ipfs"
 	"github.com/restic/restic/internal/backend/local"
 	"github.com/restic/restic/internal/backend/location"
+	"github.com/restic/restic/internal/backend/mirror"
+	"github.com/restic/restic/internal/backend/quota"
 	"github.com/restic/restic/internal/backend/rclone"
 	"github.com/restic/restic/internal/backend/rest"
 	"github.com/restic/restic/internal/backend/s3"
 	"github.com/restic/restic/internal/backend/sftp"
+	"github.com/restic/restic/internal/backend/static"
 	"github.com/restic/restic/internal/backend/swift"
+	"github.com/restic/restic/internal/backend/tiered"
+	"github.com/restic/restic/internal/backend/webdav"
 	"github.com/restic/restic/internal/cache"
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/fs"
@@ -47,6 +55,13 @@ const TimeFormat = "2006-01-02 15:04:05"
 // GlobalOptions hold all global options for restic.
 type GlobalOptions struct {
 	Repo            string
+	Profile         string
+	ConfigFile      string
+	Mirrors         []string
+	Failover        string
+	Cold            string
+	AppendOnly      bool
+	Quota           string
 	PasswordFile    string
 	PasswordCommand string
 	KeyHint         string
@@ -58,10 +73,13 @@ type GlobalOptions struct {
 	NoCache         bool
 	CACerts         []string
 	TLSClientCert   string
+	Proxy           string
 	CleanupCache    bool
 
 	LimitUploadKb   int
 	LimitDownloadKb int
+	BackendRetries  int
+	BackendTimeout  time.Duration
 
 	ctx      context.Context
 	password string
@@ -95,6 +113,13 @@ func init() {
 
 	f := cmdRoot.PersistentFlags()
 	f.StringVarP(&globalOptions.Repo, "repo", "r", os.Getenv("RESTIC_REPOSITORY"), "repository to backup to or restore from (default: $RESTIC_REPOSITORY)")
+	f.StringVar(&globalOptions.Profile, "profile", os.Getenv("RESTIC_PROFILE"), "use the options of the profile `name` from the configuration file (default: $RESTIC_PROFILE)")
+	f.StringVar(&globalOptions.ConfigFile, "config-file", os.Getenv("RESTIC_CONFIG_FILE"), "read profiles from `file` (default: $RESTIC_CONFIG_FILE or ~/.config/restic/config)")
+	f.StringArrayVar(&globalOptions.Mirrors, "mirror", nil, "also write all data to the repository at `location` (can be specified multiple times)")
+	f.StringVar(&globalOptions.Cold, "cold", "", "store pack files containing file data in the repository at `location`")
+	f.StringVar(&globalOptions.Failover, "failover", "", "write data to the repository at `location` while the repository is unavailable")
+	f.BoolVar(&globalOptions.AppendOnly, "append-only", false, "do not remove any files from the repository, except for locks (not enforced by the server)")
+	f.StringVar(&globalOptions.Quota, "quota", "", "refuse to store more than `size` in the repository (allowed suffixes: k/K, m/M, g/G, t/T)")
 	f.StringVarP(&globalOptions.PasswordFile, "password-file", "p", os.Getenv("RESTIC_PASSWORD_FILE"), "read the repository password from a file (default: $RESTIC_PASSWORD_FILE)")
 	f.StringVarP(&globalOptions.KeyHint, "key-hint", "", os.Getenv("RESTIC_KEY_HINT"), "key ID of key to try decrypting first (default: $RESTIC_KEY_HINT)")
 	f.StringVarP(&globalOptions.PasswordCommand, "password-command", "", os.Getenv("RESTIC_PASSWORD_COMMAND"), "specify a shell command to obtain a password (default: $RESTIC_PASSWORD_COMMAND)")
@@ -106,11 +131,20 @@ func init() {
 	f.BoolVar(&globalOptions.NoCache, "no-cache", false, "do not use a local cache")
 	f.StringSliceVar(&globalOptions.CACerts, "cacert", nil, "`file` to load root certificates from (default: use system certificates)")
 	f.StringVar(&globalOptions.TLSClientCert, "tls-client-cert", "", "path to a file containing PEM encoded TLS client certificate and private key")
+	f.StringVar(&globalOptions.Proxy, "proxy", "", "send requests for HTTP based backends via the proxy at `url`, not used for sftp (default: use $HTTP_PROXY/$HTTPS_PROXY)")
 	f.BoolVar(&globalOptions.CleanupCache, "cleanup-cache", false, "auto remove old cache directories")
 	f.IntVar(&globalOptions.LimitUploadKb, "limit-upload", 0, "limits uploads to a maximum rate in KiB/s. (default: unlimited)")
 	f.IntVar(&globalOptions.LimitDownloadKb, "limit-download", 0, "limits downloads to a maximum rate in KiB/s. (default: unlimited)")
+	f.IntVar(&globalOptions.BackendRetries, "backend-retries", 10, "retry failed backend operations up to `n` times")
+	f.DurationVar(&globalOptions.BackendTimeout, "backend-timeout", 0, "abort and retry backend operations which make no progress for `duration` (default: no timeout)")
 	f.StringSliceVarP(&globalOptions.Options, "option", "o", []string{}, "set extended option (`key=value`, can be specified multiple times)")
 
+	setFlagEnv(f, "repo", "RESTIC_REPOSITORY")
+	setFlagEnv(f, "key-hint", "RESTIC_KEY_HINT")
+	// a password from the environment replaces both ways to read it
+	setFlagEnv(f, "password-file", "RESTIC_PASSWORD_FILE", "RESTIC_PASSWORD_COMMAND", "RESTIC_PASSWORD")
+	setFlagEnv(f, "password-command", "RESTIC_PASSWORD_FILE", "RESTIC_PASSWORD_COMMAND", "RESTIC_PASSWORD")
+
 	restoreTerminal()
 }
 
@@ -240,8 +274,10 @@ func Exitf(exitcode int, format string, args ...interface{}) {
 	Exit(exitcode)
 }
 
-// resolvePassword determines the password to be used for opening the repository.
-func resolvePassword(opts GlobalOptions) (string, error) {
+// resolvePassword determines the password to be used for opening the
+// repository. If no password file or command is given, the password is read
+// from the environment variable envStr.
+func resolvePassword(opts GlobalOptions, envStr string) (string, error) {
 	if opts.PasswordFile != "" && opts.PasswordCommand != "" {
 		return "", errors.Fatalf("Password file and command are mutually exclusive options")
 	}
@@ -266,7 +302,7 @@ func resolvePassword(opts GlobalOptions) (string, error) {
 		return strings.TrimSpace(string(s)), errors.Wrap(err, "Readfile")
 	}
 
-	if pwd := os.Getenv("RESTIC_PASSWORD"); pwd != "" {
+	if pwd := os.Getenv(envStr); pwd != "" {
 		return pwd, nil
 	}
 
@@ -349,20 +385,131 @@ func ReadPasswordTwice(gopts GlobalOptions, prompt1, prompt2 string) (string, er
 
 const maxKeys = 20
 
+// transferStats returns the transfer statistics collected for the backend of
+// repo. The second return value is false if none are available.
+func transferStats(repo restic.Repository) (backend.TransferStats, bool) {
+	be := repo.Backend()
+	for {
+		switch b := be.(type) {
+		case *cache.Backend:
+			be = b.Backend
+		case *backend.RetryBackend:
+			be = b.Backend
+		case *backend.StatsBackend:
+			return b.Stats(), true
+		default:
+			return backend.TransferStats{}, false
+		}
+	}
+}
+
+// formatTransferStats returns a one-line summary of the transfer statistics
+// for repo.
+func formatTransferStats(repo restic.Repository) string {
+	stats, ok := transferStats(repo)
+	if !ok {
+		return ""
+	}
+
+	return fmt.Sprintf("backend: %d requests, %v uploaded, %v downloaded, %d errors, %d retries",
+		stats.Requests, formatBytes(stats.BytesUploaded), formatBytes(stats.BytesDownloaded),
+		stats.Errors, stats.Retries)
+}
+
+// openPrimary opens the backend of the repository, together with the cold
+// storage and the mirrors.
+func openPrimary(opts GlobalOptions) (restic.Backend, error) {
+	be, err := open(opts.Repo, opts, opts.extended)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Cold != "" {
+		cbe, err := open(opts.Cold, opts, opts.extended)
+		if err != nil {
+			return nil, err
+		}
+		be = tiered.New(be, cbe)
+	}
+
+	if len(opts.Mirrors) > 0 {
+		backends := []restic.Backend{be}
+		for _, s := range opts.Mirrors {
+			mbe, err := open(s, opts, opts.extended)
+			if err != nil {
+				return nil, err
+			}
+			backends = append(backends, mbe)
+		}
+		be = mirror.New(backends...)
+	}
+
+	return be, nil
+}
+
+// openFailover opens the failover location and returns a backend which saves
+// files to it while be is unavailable. If opening the repository failed with
+// err, only the failover location is used.
+func openFailover(opts GlobalOptions, be restic.Backend, err error) (restic.Backend, error) {
+	sbe, serr := open(opts.Failover, opts, opts.extended)
+	if serr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, serr
+	}
+
+	if err != nil {
+		Warnf("unable to open repository, using %v instead: %v\n", sbe.Location(), err)
+		return sbe, nil
+	}
+
+	primary, secondary := be.Location(), sbe.Location()
+	fbe := failover.New(be, sbe)
+	fbe.MaxTries = opts.BackendRetries
+	fbe.ReportRetry = func(msg string, err error, d time.Duration) {
+		Warnf("%v returned error, retrying after %v: %v\n", msg, d, err)
+	}
+	fbe.Report = func(err error) {
+		Warnf("saving to %v failed, using %v from now on: %v\n", primary, secondary, err)
+	}
+	return fbe, nil
+}
+
 // OpenRepository reads the password and opens the repository.
 func OpenRepository(opts GlobalOptions) (*repository.Repository, error) {
 	if opts.Repo == "" {
 		return nil, errors.Fatal("Please specify repository location (-r)")
 	}
 
-	be, err := open(opts.Repo, opts, opts.extended)
+	be, err := openPrimary(opts)
+	if opts.Failover != "" {
+		be, err = openFailover(opts, be, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	be = backend.NewRetryBackend(be, 10, func(msg string, err error, d time.Duration) {
+	if opts.AppendOnly {
+		be = appendonly.New(be)
+	}
+
+	if opts.Quota != "" {
+		limit, err := parseSizeStr(opts.Quota)
+		if err != nil {
+			return nil, errors.Fatalf("invalid --quota: %v", err)
+		}
+
+		be = quota.New(be, limit)
+	}
+
+	stats := backend.NewStatsBackend(be)
+	rbe := backend.NewRetryBackend(stats, opts.BackendRetries, func(msg string, err error, d time.Duration) {
+		stats.AddRetry()
 		Warnf("%v returned error, retrying after %v: %v\n", msg, d, err)
 	})
+	rbe.Timeout = opts.BackendTimeout
+	be = rbe
 
 	s := repository.New(be)
 
@@ -394,6 +541,11 @@ func OpenRepository(opts GlobalOptions) (*repository.Repository, error) {
 		return nil, errors.Fatalf("%s", err)
 	}
 
+	err = checkFilesystem(opts, s.Config())
+	if err != nil {
+		return nil, err
+	}
+
 	if stdoutIsTerminal() && !opts.JSON {
 		id := s.Config().ID
 		if len(id) > 8 {
@@ -452,6 +604,32 @@ func OpenRepository(opts GlobalOptions) (*repository.Repository, error) {
 	return s, nil
 }
 
+// checkFilesystem returns an error if the repository config contains a
+// filesystem UUID and the local repository is stored on a different
+// filesystem.
+func checkFilesystem(opts GlobalOptions, cfg restic.Config) error {
+	if cfg.FilesystemUUID == "" {
+		return nil
+	}
+
+	loc, err := location.Parse(opts.Repo)
+	if err != nil {
+		return err
+	}
+
+	// the repository may have been copied to a different backend
+	if loc.Scheme != "local" {
+		return nil
+	}
+
+	lcfg, err := parseConfig(loc, opts.extended)
+	if err != nil {
+		return err
+	}
+
+	return local.CheckFilesystem(lcfg.(local.Config), cfg.FilesystemUUID)
+}
+
 func parseConfig(loc location.Location, opts options.Options) (interface{}, error) {
 	// only apply options for a particular backend here
 	opts = opts.Extract(loc.Scheme)
@@ -581,11 +759,55 @@ func parseConfig(loc location.Location, opts options.Options) (interface{}, erro
 
 		debug.Log("opening rest repository at %#v", cfg)
 		return cfg, nil
+	case "webdav":
+		cfg := loc.Config.(webdav.Config)
+		if err := opts.Apply(loc.Scheme, &cfg); err != nil {
+			return nil, err
+		}
+
+		debug.Log("opening webdav repository at %#v", cfg)
+		return cfg, nil
+	case "static":
+		cfg := loc.Config.(static.Config)
+		if err := opts.Apply(loc.Scheme, &cfg); err != nil {
+			return nil, err
+		}
+
+		debug.Log("opening static repository at %#v", cfg)
+		return cfg, nil
+	case "ipfs":
+		cfg := loc.Config.(ipfs.Config)
+		if err := opts.Apply(loc.Scheme, &cfg); err != nil {
+			return nil, err
+		}
+
+		debug.Log("opening ipfs repository at %#v", cfg)
+		return cfg, nil
 	}
 
 	return nil, errors.Fatalf("invalid backend: %q", loc.Scheme)
 }
 
+// transportOptions returns the options for the HTTP transport of the backend
+// with the config cfg.
+func transportOptions(cfg interface{}) backend.TransportOptions {
+	tropts := backend.TransportOptions{
+		RootCertFilenames:        globalOptions.CACerts,
+		TLSClientCertKeyFilename: globalOptions.TLSClientCert,
+		Proxy:                    globalOptions.Proxy,
+	}
+
+	// the certificate of a WebDAV server, which is often self-signed, is
+	// only trusted for this backend
+	if cfg, ok := cfg.(webdav.Config); ok && cfg.CACert != "" {
+		certs := make([]string, 0, len(globalOptions.CACerts)+1)
+		certs = append(certs, globalOptions.CACerts...)
+		tropts.RootCertFilenames = append(certs, cfg.CACert)
+	}
+
+	return tropts
+}
+
 // Open the backend specified by a location config.
 func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend, error) {
 	debug.Log("parsing location %v", s)
@@ -601,11 +823,7 @@ func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend,
 		return nil, err
 	}
 
-	tropts := backend.TransportOptions{
-		RootCertFilenames:        globalOptions.CACerts,
-		TLSClientCertKeyFilename: globalOptions.TLSClientCert,
-	}
-	rt, err := backend.Transport(tropts)
+	rt, err := backend.Transport(transportOptions(cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -620,6 +838,7 @@ func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend,
 		// wrap the backend in a LimitBackend so that the throughput is limited
 		be = limiter.LimitBackend(be, lim)
 	case "sftp":
+		warnProxyIgnored()
 		be, err = sftp.Open(cfg.(sftp.Config))
 		// wrap the backend in a LimitBackend so that the throughput is limited
 		be = limiter.LimitBackend(be, lim)
@@ -637,6 +856,12 @@ func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend,
 		be, err = rest.Open(cfg.(rest.Config), rt)
 	case "rclone":
 		be, err = rclone.Open(cfg.(rclone.Config), lim)
+	case "webdav":
+		be, err = webdav.Open(cfg.(webdav.Config), rt)
+	case "static":
+		be, err = static.Open(cfg.(static.Config), rt)
+	case "ipfs":
+		be, err = ipfs.Open(cfg.(ipfs.Config), rt)
 
 	default:
 		return nil, errors.Fatalf("invalid backend: %q", loc.Scheme)
@@ -659,6 +884,14 @@ func open(s string, gopts GlobalOptions, opts options.Options) (restic.Backend,
 	return be, nil
 }
 
+// warnProxyIgnored prints a warning if --proxy was given for a backend which
+// does not use HTTP.
+func warnProxyIgnored() {
+	if globalOptions.Proxy != "" {
+		Warnf("--proxy is not used for the sftp backend, configure a proxy for ssh instead\n")
+	}
+}
+
 // Create the backend specified by URI.
 func create(s string, opts options.Options) (restic.Backend, error) {
 	debug.Log("parsing location %v", s)
@@ -672,11 +905,7 @@ func create(s string, opts options.Options) (restic.Backend, error) {
 		return nil, err
 	}
 
-	tropts := backend.TransportOptions{
-		RootCertFilenames:        globalOptions.CACerts,
-		TLSClientCertKeyFilename: globalOptions.TLSClientCert,
-	}
-	rt, err := backend.Transport(tropts)
+	rt, err := backend.Transport(transportOptions(cfg))
 	if err != nil {
 		return nil, err
 	}
@@ -685,6 +914,7 @@ func create(s string, opts options.Options) (restic.Backend, error) {
 	case "local":
 		return local.Create(cfg.(local.Config))
 	case "sftp":
+		warnProxyIgnored()
 		return sftp.Create(cfg.(sftp.Config))
 	case "s3":
 		return s3.Create(cfg.(s3.Config), rt)
@@ -700,6 +930,12 @@ func create(s string, opts options.Options) (restic.Backend, error) {
 		return rest.Create(cfg.(rest.Config), rt)
 	case "rclone":
 		return rclone.Open(cfg.(rclone.Config), nil)
+	case "webdav":
+		return webdav.Create(cfg.(webdav.Config), rt)
+	case "static":
+		return nil, errors.Fatal("the static backend is read-only, repositories must be created using a different backend")
+	case "ipfs":
+		return ipfs.Create(cfg.(ipfs.Config), rt)
 	}
 
 	debug.Log("invalid repository scheme: %v", s)
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/backend/rest"
+	"github.com/restic/restic/internal/backend/webdav"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestTransportOptionsCACert(t *testing.T) {
+	defer func(certs []string) {
+		globalOptions.CACerts = certs
+	}(globalOptions.CACerts)
+	globalOptions.CACerts = []string{"global.pem"}
+
+	cfg := webdav.NewConfig()
+	cfg.CACert = "webdav.pem"
+	tropts := transportOptions(cfg)
+	rtest.Equals(t, []string{"global.pem", "webdav.pem"}, tropts.RootCertFilenames)
+
+	// the certificate for the WebDAV server is not used for other backends
+	tropts = transportOptions(rest.NewConfig())
+	rtest.Equals(t, []string{"global.pem"}, tropts.RootCertFilenames)
+	rtest.Equals(t, []string{"global.pem"}, globalOptions.CACerts)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"archive/tar"
+	"archive/zip"
 	"bufio"
 	"bytes"
 	"context"
@@ -50,7 +52,11 @@ func testRunInit(t testing.TB, opts GlobalOptions) {
 	restic.TestDisableCheckPolynomial(t)
 	restic.TestSetLockTimeout(t, 0)
 
-	rtest.OK(t, runInit(opts, nil))
+	initOpts := InitOptions{
+		KDFTime:   repository.KDFTimeout,
+		KDFMemory: repository.KDFMemory,
+	}
+	rtest.OK(t, runInit(initOpts, opts, nil))
 	t.Logf("repository initialized at %v", opts.Repo)
 }
 
@@ -86,7 +92,7 @@ func testRunList(t testing.TB, tpe string, opts GlobalOptions) restic.IDs {
 		globalOptions.stdout = os.Stdout
 	}()
 
-	rtest.OK(t, runList(cmdList, opts, []string{tpe}))
+	rtest.OK(t, runList(cmdList, ListOptions{}, opts, []string{tpe}))
 	return parseIDsFromReader(t, buf)
 }
 
@@ -317,6 +323,608 @@ func TestBackup(t *testing.T) {
 	testRunCheck(t, env.gopts)
 }
 
+func TestBackupMirror(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	mirror := filepath.Join(env.base, "mirror")
+	env.gopts.Mirrors = []string{mirror}
+	testRunInit(t, env.gopts)
+
+	for i := 0; i < 5; i++ {
+		p := filepath.Join(env.testdata, fmt.Sprintf("file%d", i))
+		rtest.OK(t, appendRandomData(p, uint(mrand.Intn(2<<20))))
+	}
+
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	// both repositories must be complete on their own
+	for _, repo := range []string{env.repo, mirror} {
+		gopts := env.gopts
+		gopts.Repo = repo
+		gopts.Mirrors = nil
+
+		testRunCheck(t, gopts)
+
+		snapshotIDs := testRunList(t, "snapshots", gopts)
+		rtest.Assert(t, len(snapshotIDs) == 1,
+			"expected one snapshot in %v, got %v", repo, snapshotIDs)
+
+		restoredir := filepath.Join(env.base, "restore-"+filepath.Base(repo))
+		testRunRestore(t, gopts, restoredir, snapshotIDs[0])
+		rtest.Assert(t, directoriesEqualContents(env.testdata, filepath.Join(restoredir, "testdata")),
+			"directories are not equal")
+	}
+}
+
+func TestBackupAppendOnly(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	datafile := filepath.Join("testdata", "backup-data.tar.gz")
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, datafile)
+
+	env.gopts.AppendOnly = true
+	opts := BackupOptions{}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2,
+		"expected two snapshots, got %v", snapshotIDs)
+
+	err := runForget(ForgetOptions{}, env.gopts, []string{snapshotIDs[0].String()})
+	rtest.Assert(t, err != nil, "forget did not return an error in append-only mode")
+
+	snapshotIDs = testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2,
+		"expected two snapshots after forget, got %v", snapshotIDs)
+}
+
+func TestSyncBackends(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	env.gopts.Failover = filepath.Join(env.base, "failover")
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+
+	for _, name := range []string{"config", "keys"} {
+		_, err := os.Stat(filepath.Join(env.gopts.Failover, name))
+		rtest.OK(t, err)
+	}
+
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 1,
+		"expected one snapshot, got %v", snapshotIDs)
+
+	// simulate a snapshot which was saved while the repository was unavailable
+	name := snapshotIDs[0].String()
+	rtest.OK(t, os.MkdirAll(filepath.Join(env.gopts.Failover, "snapshots"), 0700))
+	rtest.OK(t, os.Rename(filepath.Join(env.repo, "snapshots", name),
+		filepath.Join(env.gopts.Failover, "snapshots", name)))
+
+	rtest.OK(t, runSyncBackends(env.gopts))
+
+	_, err := os.Stat(filepath.Join(env.repo, "snapshots", name))
+	rtest.OK(t, err)
+	_, err = os.Stat(filepath.Join(env.gopts.Failover, "snapshots", name))
+	rtest.Assert(t, os.IsNotExist(err),
+		"snapshot was not removed from the failover location: %v", err)
+
+	// the failover location is used if the repository cannot be opened
+	moved := env.repo + ".moved"
+	rtest.OK(t, os.Rename(env.repo, moved))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	rtest.OK(t, os.Rename(moved, env.repo))
+
+	failoverSnapshots, err := ioutil.ReadDir(filepath.Join(env.gopts.Failover, "snapshots"))
+	rtest.OK(t, err)
+	rtest.Equals(t, 1, len(failoverSnapshots))
+
+	rtest.OK(t, runSyncBackends(env.gopts))
+	env.gopts.Failover = ""
+	snapshotIDs = testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2,
+		"expected two snapshots, got %v", snapshotIDs)
+	testRunCheck(t, env.gopts)
+}
+
+func TestPing(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	rtest.OK(t, runPing(PingOptions{Size: "100K"}, env.gopts))
+
+	locks, err := ioutil.ReadDir(filepath.Join(env.repo, "locks"))
+	rtest.OK(t, err)
+	rtest.Assert(t, len(locks) == 0,
+		"test file was not removed, found %d locks", len(locks))
+
+	err = runPing(PingOptions{Size: "100K"}, GlobalOptions{})
+	rtest.Assert(t, err != nil, "ping without repository did not return an error")
+
+	err = runPing(PingOptions{Size: "1G"}, env.gopts)
+	rtest.Assert(t, err != nil, "ping with a too large size did not return an error")
+}
+
+func TestBenchmark(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	// a test file left over from an interrupted benchmark
+	leftover := filepath.Join(env.repo, "locks", benchmarkPrefix+restic.NewRandomID().String())
+	rtest.OK(t, ioutil.WriteFile(leftover, []byte("foo"), 0600))
+
+	rtest.OK(t, runBenchmark(BenchmarkOptions{DataSize: "4M", MaxConnections: 2}, env.gopts, nil))
+
+	// the test files must have been removed and must not be in the data dir
+	locks, err := ioutil.ReadDir(filepath.Join(env.repo, "locks"))
+	rtest.OK(t, err)
+	rtest.Assert(t, len(locks) == 0, "test files were not removed, found %d locks", len(locks))
+
+	packs := testRunList(t, "packs", env.gopts)
+	rtest.Assert(t, len(packs) == 0, "test files were saved as packs, found %d packs", len(packs))
+}
+
+func TestBackupCold(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	env.gopts.Cold = filepath.Join(env.base, "cold")
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	// tree packs stay in the repository, data packs are moved to the cold location
+	rtest.Assert(t, dirStats(filepath.Join(env.repo, "data")).files > 0,
+		"no tree packs found in the repository")
+	rtest.Assert(t, dirStats(filepath.Join(env.gopts.Cold, "data")).files > 0,
+		"no data packs found in the cold location")
+	rtest.Assert(t, dirStats(filepath.Join(env.gopts.Cold, "index")).files == 0,
+		"index files found in the cold location")
+
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 1,
+		"expected one snapshot, got %v", snapshotIDs)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestore(t, env.gopts, restoredir, snapshotIDs[0])
+	rtest.Assert(t, directoriesEqualContents(env.testdata, filepath.Join(restoredir, "testdata")),
+		"directories are not equal")
+}
+
+func TestCopy(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testRunInit(t, env.gopts)
+	testRunInit(t, env2.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	appendRandomData(filepath.Join(env.testdata, "0", "0", "9", "37"), 4096)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	passwordFile := filepath.Join(env.base, "password2")
+	rtest.OK(t, ioutil.WriteFile(passwordFile, []byte(rtest.TestPassword), 0600))
+	opts := CopyOptions{
+		secondaryRepoOptions: secondaryRepoOptions{
+			Repo:         env2.gopts.Repo,
+			PasswordFile: passwordFile,
+		},
+	}
+
+	// copying a second time must not add any snapshots
+	for i := 0; i < 2; i++ {
+		rtest.OK(t, runCopy(opts, env.gopts, nil))
+		testRunCheck(t, env2.gopts)
+
+		snapshotIDs := testRunList(t, "snapshots", env2.gopts)
+		rtest.Assert(t, len(snapshotIDs) == 2,
+			"expected two snapshots, got %v", snapshotIDs)
+	}
+
+	restoredir := filepath.Join(env2.base, "restore")
+	testRunRestoreLatest(t, env2.gopts, restoredir, nil, "")
+	rtest.Assert(t, directoriesEqualContents(env.testdata, filepath.Join(restoredir, "testdata")),
+		"directories are not equal")
+}
+
+func TestListLocksLong(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	buf := bytes.NewBuffer(nil)
+	globalOptions.stdout = buf
+	defer func() {
+		globalOptions.stdout = os.Stdout
+	}()
+
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	lock, err := restic.NewLock(context.TODO(), repo)
+	rtest.OK(t, err)
+
+	// the list command holds a lock on the repository itself, which must
+	// not be listed
+	rtest.OK(t, runList(cmdList, ListOptions{Long: true}, env.gopts, []string{"locks"}))
+	rtest.OK(t, lock.Unlock())
+
+	hostname, err := os.Hostname()
+	rtest.OK(t, err)
+	want := fmt.Sprintf("%v shared, PID %d on %v", lock.ID(), os.Getpid(), hostname)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	rtest.Assert(t, len(lines) == 1 && strings.HasPrefix(lines[0], want),
+		"expected only the lock details %q in output %q", want, buf.String())
+
+	err = runList(cmdList, ListOptions{Long: true}, env.gopts, []string{"snapshots"})
+	rtest.Assert(t, err != nil, "list snapshots --long did not return an error")
+}
+
+func TestRewrite(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	original := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(original) == 1, "expected one snapshot, got %v", original)
+
+	opts := RewriteOptions{Excludes: []string{"testdata/0/0/9"}}
+
+	// a dry run must not change anything
+	opts.DryRun = true
+	rtest.OK(t, runRewrite(opts, env.gopts, nil))
+	rtest.Equals(t, original, testRunList(t, "snapshots", env.gopts))
+
+	opts.DryRun = false
+	rtest.OK(t, runRewrite(opts, env.gopts, []string{original[0].String()}))
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2, "expected two snapshots, got %v", snapshotIDs)
+
+	// remove files from both snapshots and forget the originals
+	opts.Forget = true
+	opts.Excludes = []string{"testdata/0/tests/testfile*"}
+	rtest.OK(t, runRewrite(opts, env.gopts, nil))
+	snapshotIDs = testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2, "expected two snapshots, got %v", snapshotIDs)
+
+	// nothing is left to remove
+	rtest.OK(t, runRewrite(opts, env.gopts, nil))
+	rtest.Equals(t, snapshotIDs, testRunList(t, "snapshots", env.gopts))
+
+	testRunPrune(t, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	removedDirs := 0
+	for i, id := range snapshotIDs {
+		restoredir := filepath.Join(env.base, fmt.Sprintf("restore%d", i))
+		testRunRestore(t, env.gopts, restoredir, id)
+
+		_, err := os.Lstat(filepath.Join(restoredir, "testdata", "0", "tests", "testfile"))
+		rtest.Assert(t, os.IsNotExist(err), "file was not removed from snapshot %v", id.Str())
+		_, err = os.Lstat(filepath.Join(restoredir, "testdata", "0", "tests", "empty-file"))
+		rtest.OK(t, err)
+
+		_, err = os.Lstat(filepath.Join(restoredir, "testdata", "0", "0", "9"))
+		if os.IsNotExist(err) {
+			removedDirs++
+		}
+	}
+	rtest.Equals(t, 1, removedDirs)
+}
+
+func TestRewriteDryRunSharedTree(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+
+	// both snapshots reference the same trees
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	original := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(original) == 2, "expected two snapshots, got %v", original)
+
+	buf := bytes.NewBuffer(nil)
+	globalOptions.stdout = buf
+	defer func() {
+		globalOptions.stdout = os.Stdout
+	}()
+
+	opts := RewriteOptions{Excludes: []string{"testdata/0/0/9"}, DryRun: true}
+	rtest.OK(t, runRewrite(opts, env.gopts, nil))
+	rtest.Assert(t, strings.Contains(buf.String(), "would rewrite 2 snapshots"),
+		"unexpected output: %s", buf.String())
+	rtest.Equals(t, original, testRunList(t, "snapshots", env.gopts))
+}
+
+func testRunVerify(t testing.TB, gopts GlobalOptions, dir string) (changes []Change, err error) {
+	buf := bytes.NewBuffer(nil)
+	gopts.stdout = buf
+	gopts.JSON = true
+
+	err = runVerify(VerifyOptions{SnapshotDir: "/testdata"}, gopts, []string{"latest", dir})
+
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var change Change
+		rtest.OK(t, dec.Decode(&change))
+		changes = append(changes, change)
+	}
+
+	return changes, err
+}
+
+func TestVerify(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	for _, name := range []string{"file1", "file2", "subdir/file3"} {
+		p := filepath.Join(env.testdata, filepath.FromSlash(name))
+		rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+		rtest.OK(t, appendRandomData(p, 5000))
+	}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+
+	changes, err := testRunVerify(t, env.gopts, env.testdata)
+	rtest.OK(t, err)
+	rtest.Equals(t, 0, len(changes))
+
+	// modify the content without changing the size or the modification time
+	p := filepath.Join(env.testdata, "file1")
+	fi, err := os.Stat(p)
+	rtest.OK(t, err)
+	rtest.OK(t, ioutil.WriteFile(p, bytes.Repeat([]byte("x"), 5000), 0644))
+	rtest.OK(t, os.Chtimes(p, fi.ModTime(), fi.ModTime()))
+
+	rtest.OK(t, os.Remove(filepath.Join(env.testdata, "file2")))
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "subdir", "new"), 10))
+
+	changes, err = testRunVerify(t, env.gopts, env.testdata)
+	rtest.Assert(t, err != nil, "verify did not report the differences")
+
+	want := []Change{
+		*NewChange(filepath.Join(env.testdata, "file1"), "M"),
+		*NewChange(filepath.Join(env.testdata, "file2"), "-"),
+		// the modification time of the directory has changed as well
+		*NewChange(filepath.Join(env.testdata, "subdir")+string(filepath.Separator), "U"),
+		*NewChange(filepath.Join(env.testdata, "subdir", "new"), "+"),
+	}
+	rtest.Equals(t, want, changes)
+}
+
+func TestRepairSnapshots(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "0", "0", "9", "37"), 4096))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	// remove the pack file which contains the modified file data, it is the
+	// data pack with the fewest blobs
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	rtest.OK(t, repo.LoadIndex(env.gopts.ctx))
+
+	blobs := make(map[restic.ID]int)
+	for pb := range repo.Index().Each(env.gopts.ctx) {
+		if pb.Type == restic.DataBlob {
+			blobs[pb.PackID]++
+		}
+	}
+
+	var packID restic.ID
+	for id, n := range blobs {
+		if packID.IsNull() || n < blobs[packID] {
+			packID = id
+		}
+	}
+	rtest.Assert(t, len(blobs) > 1, "expected several data packs, got %d", len(blobs))
+	rtest.OK(t, os.Remove(filepath.Join(env.repo, "data", packID.String()[:2], packID.String())))
+
+	testRunRebuildIndex(t, env.gopts)
+
+	// a dry run must not change anything
+	rtest.OK(t, runRepairSnapshots(RepairSnapshotsOptions{DryRun: true}, env.gopts, nil))
+	_, err = testRunCheckOutput(env.gopts)
+	rtest.Assert(t, err != nil, "check did not find the missing data")
+
+	rtest.OK(t, runRepairSnapshots(RepairSnapshotsOptions{Forget: true}, env.gopts, nil))
+	testRunPrune(t, env.gopts)
+	testRunCheck(t, env.gopts)
+
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2,
+		"expected two snapshots, got %v", snapshotIDs)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestoreLatest(t, env.gopts, restoredir, nil, "")
+	rtest.Assert(t, dirStats(restoredir).files == dirStats(env.testdata).files-1,
+		"restored %d files, expected %d", dirStats(restoredir).files, dirStats(env.testdata).files-1)
+}
+
+func TestBackupDryRun(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+
+	opts := BackupOptions{DryRun: true}
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, len(testRunList(t, "snapshots", env.gopts)) == 0,
+		"dry run saved a snapshot")
+	rtest.Assert(t, len(testRunList(t, "packs", env.gopts)) == 0,
+		"dry run saved pack files")
+
+	locks, err := ioutil.ReadDir(filepath.Join(env.repo, "locks"))
+	rtest.OK(t, err)
+	rtest.Assert(t, len(locks) == 0,
+		"dry run left %d locks behind", len(locks))
+
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+	packs := testRunList(t, "packs", env.gopts)
+
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "0", "0", "9", "37"), 4096))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, opts, env.gopts)
+	rtest.Assert(t, len(testRunList(t, "snapshots", env.gopts)) == 1,
+		"dry run saved a snapshot")
+	rtest.Equals(t, packs, testRunList(t, "packs", env.gopts))
+
+	testRunCheck(t, env.gopts)
+}
+
+func TestInitCopyChunkerParams(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+	env2, cleanup2 := withTestEnvironment(t)
+	defer cleanup2()
+
+	testRunInit(t, env2.gopts)
+
+	passwordFile := filepath.Join(env.base, "password2")
+	rtest.OK(t, ioutil.WriteFile(passwordFile, []byte(rtest.TestPassword), 0600))
+
+	initOpts := InitOptions{
+		secondaryRepoOptions: secondaryRepoOptions{
+			Repo:         env2.gopts.Repo,
+			PasswordFile: passwordFile,
+		},
+		CopyChunkerParameters: true,
+		KDFTime:               repository.KDFTimeout,
+		KDFMemory:             repository.KDFMemory,
+	}
+	rtest.OK(t, runInit(initOpts, env.gopts, nil))
+
+	repo, err := OpenRepository(env.gopts)
+	rtest.OK(t, err)
+	otherRepo, err := OpenRepository(env2.gopts)
+	rtest.OK(t, err)
+
+	rtest.Assert(t, repo.Config().ChunkerPolynomial == otherRepo.Config().ChunkerPolynomial,
+		"expected equal chunker polynomials, got %v and %v",
+		repo.Config().ChunkerPolynomial, otherRepo.Config().ChunkerPolynomial)
+	rtest.Assert(t, repo.Config().ID != otherRepo.Config().ID,
+		"repositories have the same ID %v", repo.Config().ID)
+}
+
+func TestExport(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	want := dirStats(env.testdata).files
+
+	// checkFile compares the file with the original in the test data
+	checkFile := func(name string, rd io.Reader) {
+		buf, err := ioutil.ReadAll(rd)
+		rtest.OK(t, err)
+		orig, err := ioutil.ReadFile(filepath.Join(env.base, filepath.FromSlash(name)))
+		rtest.OK(t, err)
+		rtest.Assert(t, bytes.Equal(buf, orig), "wrong content for %v", name)
+	}
+
+	tarfile := filepath.Join(env.base, "snapshot.tar")
+	rtest.OK(t, runExport(ExportOptions{Format: "tar", Output: tarfile}, env.gopts, []string{"latest"}))
+
+	f, err := os.Open(tarfile)
+	rtest.OK(t, err)
+	defer f.Close()
+
+	var files uint
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		rtest.OK(t, err)
+
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			checkFile(hdr.Name, tr)
+			files++
+		case tar.TypeLink:
+			files++
+		}
+	}
+	rtest.Equals(t, want, files)
+
+	zipfile := filepath.Join(env.base, "snapshot.zip")
+	rtest.OK(t, runExport(ExportOptions{Format: "zip", Output: zipfile}, env.gopts, []string{"latest"}))
+
+	zr, err := zip.OpenReader(zipfile)
+	rtest.OK(t, err)
+	defer zr.Close()
+
+	files = 0
+	for _, zf := range zr.File {
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+
+		rd, err := zf.Open()
+		rtest.OK(t, err)
+		checkFile(zf.Name, rd)
+		rtest.OK(t, rd.Close())
+		files++
+	}
+	rtest.Equals(t, want, files)
+}
+
+func TestImport(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+	rtest.SetupTarTestFixture(t, env.testdata, filepath.Join("testdata", "backup-data.tar.gz"))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{"testdata"}, BackupOptions{}, env.gopts)
+
+	tarfile := filepath.Join(env.base, "snapshot.tar")
+	rtest.OK(t, runExport(ExportOptions{Format: "tar", Output: tarfile}, env.gopts, []string{"latest"}))
+
+	opts := ImportOptions{
+		Host:      "imported",
+		TimeStamp: "2019-10-01 12:00:00",
+	}
+	rtest.OK(t, runImport(opts, env.gopts, []string{tarfile}))
+	testRunCheck(t, env.gopts)
+
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2,
+		"expected two snapshots, got %v", snapshotIDs)
+
+	restoredir := filepath.Join(env.base, "restore")
+	testRunRestoreLatest(t, env.gopts, restoredir, nil, "imported")
+	rtest.Assert(t, directoriesEqualContents(env.testdata, filepath.Join(restoredir, "testdata")),
+		"directories are not equal")
+}
+
 func TestBackupNonExistingFile(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -771,6 +1379,63 @@ func TestRestore(t *testing.T) {
 		"directories are not equal")
 }
 
+func TestDiffRestoreJSON(t *testing.T) {
+	env, cleanup := withTestEnvironment(t)
+	defer cleanup()
+
+	testRunInit(t, env.gopts)
+
+	p := filepath.Join(env.testdata, "testfile")
+	rtest.OK(t, os.MkdirAll(filepath.Dir(p), 0755))
+	rtest.OK(t, appendRandomData(p, 100))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+	snapshotIDs := testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 1, "expected one snapshot, got %v", snapshotIDs)
+	first := snapshotIDs[0]
+
+	rtest.OK(t, appendRandomData(filepath.Join(env.testdata, "newfile"), 200))
+	testRunBackup(t, filepath.Dir(env.testdata), []string{filepath.Base(env.testdata)}, BackupOptions{}, env.gopts)
+	snapshotIDs = testRunList(t, "snapshots", env.gopts)
+	rtest.Assert(t, len(snapshotIDs) == 2, "expected two snapshots, got %v", snapshotIDs)
+	second := snapshotIDs[0]
+	if second == first {
+		second = snapshotIDs[1]
+	}
+
+	buf := bytes.NewBuffer(nil)
+	gopts := env.gopts
+	gopts.stdout = buf
+	gopts.JSON = true
+
+	rtest.OK(t, runDiff(DiffOptions{}, gopts, []string{first.String(), second.String()}))
+
+	dec := json.NewDecoder(buf)
+	var change Change
+	rtest.OK(t, dec.Decode(&change))
+	rtest.Equals(t, "change", change.MessageType)
+	rtest.Equals(t, "+", change.Modifier)
+	rtest.Equals(t, "/testdata/newfile", change.Path)
+
+	var stats DiffStats
+	rtest.OK(t, dec.Decode(&stats))
+	rtest.Equals(t, "statistics", stats.MessageType)
+	rtest.Equals(t, 1, stats.Added.Files)
+	rtest.Equals(t, 1, stats.Added.DataBlobs)
+
+	buf.Reset()
+	restoredir := filepath.Join(env.base, "restore")
+	rtest.OK(t, runRestore(RestoreOptions{Target: restoredir}, gopts, []string{second.String()}))
+
+	var summary restoreSummary
+	rtest.OK(t, json.Unmarshal(buf.Bytes(), &summary))
+	rtest.Equals(t, "summary", summary.MessageType)
+	rtest.Equals(t, second.String(), summary.SnapshotID)
+	rtest.Equals(t, uint64(2), summary.FilesRestored)
+	rtest.Equals(t, uint64(1), summary.DirsRestored)
+	rtest.Equals(t, uint64(300), summary.BytesRestored)
+	rtest.Equals(t, 0, summary.Errors)
+}
+
 func TestRestoreLatest(t *testing.T) {
 	env, cleanup := withTestEnvironment(t)
 	defer cleanup()
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"github.com/spf13/pflag"
+)
+
+// defaultProfile is used when no profile is selected with --profile.
+const defaultProfile = "default"
+
+// envAnnotation is the annotation of flags whose default value is read from
+// an environment variable.
+const envAnnotation = "restic_env"
+
+// setFlagEnv records that the flag name can be set with the environment
+// variables env, e.g. because its default is read from one of them. When one
+// of them is set, the environment takes precedence over a profile.
+func setFlagEnv(fs *pflag.FlagSet, name string, env ...string) {
+	err := fs.SetAnnotation(name, envAnnotation, env)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// profileOption is a single "key = value" line of a profile.
+type profileOption struct {
+	Key, Value string
+}
+
+// defaultConfigFile returns the location of the configuration file. On
+// Windows, it is stored below APPDATA, on all other systems the XDG basedir
+// spec is followed.
+func defaultConfigFile() string {
+	if runtime.GOOS == "windows" {
+		appdata := os.Getenv("APPDATA")
+		if appdata == "" {
+			return ""
+		}
+		return filepath.Join(appdata, "restic", "config")
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "restic", "config")
+	}
+
+	if home := os.Getenv("HOME"); home != "" {
+		return filepath.Join(home, ".config", "restic", "config")
+	}
+
+	return ""
+}
+
+// parseProfiles reads a configuration file, which consists of sections with
+// the profile name in square brackets, followed by "key = value" lines.
+// Empty lines and lines starting with # or ; are ignored.
+func parseProfiles(rd io.Reader) (map[string][]profileOption, error) {
+	profiles := make(map[string][]profileOption)
+	current := ""
+
+	sc := bufio.NewScanner(rd)
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(sc.Text())
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.TrimSpace(line[1 : len(line)-1])
+			if current == "" {
+				return nil, errors.Errorf("line %d: empty profile name", lineno)
+			}
+			if _, ok := profiles[current]; !ok {
+				profiles[current] = nil
+			}
+			continue
+		}
+
+		if current == "" {
+			return nil, errors.Errorf("line %d: option outside of a profile", lineno)
+		}
+
+		data := strings.SplitN(line, "=", 2)
+		if len(data) != 2 {
+			return nil, errors.Errorf("line %d: expected key = value, got %q", lineno, line)
+		}
+
+		opt := profileOption{
+			Key:   strings.TrimSpace(data[0]),
+			Value: strings.TrimSpace(data[1]),
+		}
+		profiles[current] = append(profiles[current], opt)
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+
+	return profiles, nil
+}
+
+// setInEnvironment returns the name of the first environment variable
+// recorded for f with setFlagEnv which is set, or an empty string.
+func setInEnvironment(f *pflag.Flag) string {
+	for _, env := range f.Annotations[envAnnotation] {
+		if os.Getenv(env) != "" {
+			return env
+		}
+	}
+	return ""
+}
+
+// applyProfile sets the flags in fs to the values in the profile. Flags which
+// were given on the command line or set with an environment variable (see
+// setFlagEnv) are left alone, so the precedence is command line, environment,
+// profile. Options for flags which do not exist in fs are ignored, they may
+// belong to other commands. Options given several times are all applied, e.g.
+// to add several exclude patterns.
+func applyProfile(fs *pflag.FlagSet, profile []profileOption) error {
+	// remember which flags were given on the command line before changing any
+	cmdline := make(map[string]bool)
+	fs.Visit(func(f *pflag.Flag) {
+		cmdline[f.Name] = true
+	})
+
+	for _, opt := range profile {
+		if opt.Key == "profile" || opt.Key == "config-file" {
+			return errors.Fatalf("option %q cannot be set in a profile", opt.Key)
+		}
+
+		f := fs.Lookup(opt.Key)
+		if f == nil {
+			debug.Log("ignoring option %q, it does not apply to this command", opt.Key)
+			continue
+		}
+
+		if cmdline[f.Name] {
+			debug.Log("ignoring option %q, it was given on the command line", opt.Key)
+			continue
+		}
+
+		if env := setInEnvironment(f); env != "" {
+			debug.Log("ignoring option %q, $%v is set", opt.Key, env)
+			continue
+		}
+
+		err := fs.Set(f.Name, opt.Value)
+		if err != nil {
+			return errors.Fatalf("invalid value for option %q in profile: %v", opt.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// loadProfile applies the profile selected with --profile to the flags of the
+// command. Without --profile, the profile "default" is used if it exists.
+func loadProfile(fs *pflag.FlagSet, opts GlobalOptions) error {
+	filename := opts.ConfigFile
+	if filename == "" {
+		filename = defaultConfigFile()
+	}
+
+	f, err := os.Open(filename)
+	if os.IsNotExist(err) && opts.ConfigFile == "" && opts.Profile == "" {
+		return nil
+	}
+	if err != nil {
+		return errors.Fatalf("unable to open configuration file: %v", err)
+	}
+	defer f.Close()
+
+	profiles, err := parseProfiles(f)
+	if err != nil {
+		return errors.Fatalf("unable to parse configuration file %v: %v", filename, err)
+	}
+
+	name := opts.Profile
+	if name == "" {
+		name = defaultProfile
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		if opts.Profile == "" {
+			return nil
+		}
+		return errors.Fatalf("profile %q not found in %v", name, filename)
+	}
+
+	debug.Log("using profile %q from %v", name, filename)
+	return applyProfile(fs, profile)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+
+	"github.com/spf13/pflag"
+)
+
+func TestParseProfiles(t *testing.T) {
+	profiles, err := parseProfiles(strings.NewReader(`
+# comment
+[default]
+repo = /srv/restic-repo
+password-file=/etc/restic/password
+
+; another comment
+[ offsite ]
+repo = sftp:user@host:/srv/restic-repo
+option = sftp.command=ssh -p 2222 user@host -s sftp
+`))
+	rtest.OK(t, err)
+
+	want := map[string][]profileOption{
+		"default": {
+			{"repo", "/srv/restic-repo"},
+			{"password-file", "/etc/restic/password"},
+		},
+		"offsite": {
+			{"repo", "sftp:user@host:/srv/restic-repo"},
+			{"option", "sftp.command=ssh -p 2222 user@host -s sftp"},
+		},
+	}
+	rtest.Equals(t, want, profiles)
+
+	for _, data := range []string{
+		"repo = /srv/restic-repo\n",
+		"[]\nrepo = /srv/restic-repo\n",
+		"[default]\nrepo\n",
+	} {
+		_, err := parseProfiles(strings.NewReader(data))
+		rtest.Assert(t, err != nil, "no error for invalid config %q", data)
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	var repo, passwordFile string
+	var excludes []string
+
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringVarP(&repo, "repo", "r", "", "")
+	fs.StringVar(&passwordFile, "password-file", "", "")
+	fs.StringArrayVar(&excludes, "exclude", nil, "")
+	rtest.OK(t, fs.Parse([]string{"--repo", "/srv/other"}))
+
+	err := applyProfile(fs, []profileOption{
+		{"repo", "/srv/restic-repo"},
+		{"password-file", "/etc/restic/password"},
+		{"exclude", "*.tmp"},
+		{"exclude", "*.bak"},
+		{"host", "foo"},
+	})
+	rtest.OK(t, err)
+
+	rtest.Equals(t, "/srv/other", repo)
+	rtest.Equals(t, "/etc/restic/password", passwordFile)
+	rtest.Equals(t, []string{"*.tmp", "*.bak"}, excludes)
+
+	err = applyProfile(fs, []profileOption{{"profile", "foo"}})
+	rtest.Assert(t, err != nil, "no error for setting the profile in a profile")
+}
+
+// setenv sets the environment variable for the test and returns a function
+// to restore the previous value.
+func setenv(t testing.TB, key, value string) func() {
+	old, ok := os.LookupEnv(key)
+	rtest.OK(t, os.Setenv(key, value))
+	return func() {
+		if ok {
+			rtest.OK(t, os.Setenv(key, old))
+		} else {
+			rtest.OK(t, os.Unsetenv(key))
+		}
+	}
+}
+
+func TestApplyProfileEnvironment(t *testing.T) {
+	defer setenv(t, "RESTIC_TEST_REPOSITORY", "/srv/env")()
+	defer setenv(t, "RESTIC_TEST_PASSWORD", "secret")()
+
+	var repo, passwordFile, passwordCommand, keyHint string
+
+	// the defaults are read from the environment like in global.go
+	fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	fs.StringVarP(&repo, "repo", "r", os.Getenv("RESTIC_TEST_REPOSITORY"), "")
+	fs.StringVar(&passwordFile, "password-file", os.Getenv("RESTIC_TEST_PASSWORD_FILE"), "")
+	fs.StringVar(&passwordCommand, "password-command", "", "")
+	fs.StringVar(&keyHint, "key-hint", os.Getenv("RESTIC_TEST_KEY_HINT"), "")
+	setFlagEnv(fs, "repo", "RESTIC_TEST_REPOSITORY")
+	setFlagEnv(fs, "password-file", "RESTIC_TEST_PASSWORD_FILE", "RESTIC_TEST_PASSWORD")
+	setFlagEnv(fs, "password-command", "RESTIC_TEST_PASSWORD_COMMAND")
+	setFlagEnv(fs, "key-hint", "RESTIC_TEST_KEY_HINT")
+	rtest.OK(t, fs.Parse(nil))
+
+	err := applyProfile(fs, []profileOption{
+		{"repo", "/srv/restic-repo"},
+		{"password-file", "/etc/restic/password"},
+		{"password-command", "pass restic"},
+		{"key-hint", "abcd"},
+	})
+	rtest.OK(t, err)
+
+	// the environment takes precedence over the profile
+	rtest.Equals(t, "/srv/env", repo)
+	rtest.Equals(t, "", passwordFile)
+
+	// flags without a value in the environment are set from the profile
+	rtest.Equals(t, "pass restic", passwordCommand)
+	rtest.Equals(t, "abcd", keyHint)
+
+	// the command line takes precedence over the environment
+	rtest.OK(t, fs.Parse([]string{"--repo", "/srv/other"}))
+	rtest.OK(t, applyProfile(fs, []profileOption{{"repo", "/srv/restic-repo"}}))
+	rtest.Equals(t, "/srv/other", repo)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+
+	"github.com/restic/restic/internal/errors"
+
+	"github.com/spf13/pflag"
+)
+
+// secondaryRepoOptions collects the options for accessing a second repository,
+// e.g. the destination of the copy command.
+type secondaryRepoOptions struct {
+	Repo            string
+	PasswordFile    string
+	PasswordCommand string
+	KeyHint         string
+}
+
+// initSecondaryRepoOptions adds the flags for a second repository to f. The
+// repository is described as e.g. "destination" or "source" in the help texts,
+// repoUsage is the help text for --repo2.
+func initSecondaryRepoOptions(f *pflag.FlagSet, opts *secondaryRepoOptions, repoType, repoUsage string) {
+	f.StringVarP(&opts.Repo, "repo2", "", os.Getenv("RESTIC_REPOSITORY2"), repoUsage+" (default: $RESTIC_REPOSITORY2)")
+	f.StringVarP(&opts.PasswordFile, "password-file2", "", os.Getenv("RESTIC_PASSWORD_FILE2"), "read the "+repoType+" repository password from a file (default: $RESTIC_PASSWORD_FILE2)")
+	f.StringVarP(&opts.KeyHint, "key-hint2", "", os.Getenv("RESTIC_KEY_HINT2"), "key ID of key to try decrypting the "+repoType+" repository first (default: $RESTIC_KEY_HINT2)")
+	f.StringVarP(&opts.PasswordCommand, "password-command2", "", os.Getenv("RESTIC_PASSWORD_COMMAND2"), "specify a shell command to obtain a password for the "+repoType+" repository (default: $RESTIC_PASSWORD_COMMAND2)")
+
+	setFlagEnv(f, "repo2", "RESTIC_REPOSITORY2")
+	setFlagEnv(f, "key-hint2", "RESTIC_KEY_HINT2")
+	// a password from the environment replaces both ways to read it
+	setFlagEnv(f, "password-file2", "RESTIC_PASSWORD_FILE2", "RESTIC_PASSWORD_COMMAND2", "RESTIC_PASSWORD2")
+	setFlagEnv(f, "password-command2", "RESTIC_PASSWORD_FILE2", "RESTIC_PASSWORD_COMMAND2", "RESTIC_PASSWORD2")
+}
+
+// fillSecondaryGlobalOpts returns the global options for accessing the second
+// repository, based on gopts. The options for storing the repository in
+// several locations only apply to the repository given with --repo.
+func fillSecondaryGlobalOpts(opts secondaryRepoOptions, gopts GlobalOptions, repoType string) (GlobalOptions, error) {
+	if opts.Repo == "" {
+		return GlobalOptions{}, errors.Fatalf("Please specify a %s repository location (--repo2)", repoType)
+	}
+
+	secondaryGopts := gopts
+	secondaryGopts.Repo = opts.Repo
+	secondaryGopts.PasswordFile = opts.PasswordFile
+	secondaryGopts.PasswordCommand = opts.PasswordCommand
+	secondaryGopts.KeyHint = opts.KeyHint
+	secondaryGopts.Mirrors = nil
+	secondaryGopts.Failover = ""
+	secondaryGopts.Cold = ""
+
+	var err error
+	secondaryGopts.password, err = resolvePassword(secondaryGopts, "RESTIC_PASSWORD2")
+	if err != nil {
+		return GlobalOptions{}, err
+	}
+
+	return secondaryGopts, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// hardlinkKey identifies a file which has several hard links.
+type hardlinkKey struct {
+	inode, device uint64
+}
+
+// tarWriter writes a tar archive for the dump and export commands.
+type tarWriter struct {
+	repo restic.Repository
+	tw   *tar.Writer
+
+	// links maps the files with several hard links to the name of the first
+	// link in the archive
+	links map[hardlinkKey]string
+}
+
+func newTarWriter(repo restic.Repository, wr io.Writer) *tarWriter {
+	return &tarWriter{
+		repo:  repo,
+		tw:    tar.NewWriter(wr),
+		links: make(map[hardlinkKey]string),
+	}
+}
+
+// tarMode returns the permission bits of mode in the format used by tar.
+func tarMode(mode os.FileMode) int64 {
+	m := int64(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		m |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		m |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		m |= 01000
+	}
+	return m
+}
+
+func (w *tarWriter) Add(ctx context.Context, name string, node *restic.Node) error {
+	header := &tar.Header{
+		Name:       name,
+		Mode:       tarMode(node.Mode),
+		Uid:        int(node.UID),
+		Gid:        int(node.GID),
+		Uname:      node.User,
+		Gname:      node.Group,
+		ModTime:    node.ModTime,
+		AccessTime: node.AccessTime,
+		ChangeTime: node.ChangeTime,
+		PAXRecords: parseXattrs(node.ExtendedAttributes),
+	}
+
+	switch node.Type {
+	case "dir":
+		header.Typeflag = tar.TypeDir
+	case "symlink":
+		header.Typeflag = tar.TypeSymlink
+		header.Linkname = node.LinkTarget
+	case "fifo":
+		header.Typeflag = tar.TypeFifo
+	case "file":
+		header.Typeflag = tar.TypeReg
+		header.Size = int64(node.Size)
+
+		if node.Links > 1 {
+			key := hardlinkKey{inode: node.Inode, device: node.DeviceID}
+			if target, ok := w.links[key]; ok {
+				header.Typeflag = tar.TypeLink
+				header.Linkname = target
+				header.Size = 0
+			} else {
+				w.links[key] = name
+			}
+		}
+	default:
+		Warnf("skipping %v: type %v is not supported\n", name, node.Type)
+		return nil
+	}
+
+	err := w.tw.WriteHeader(header)
+	if err != nil {
+		return errors.Wrap(err, "WriteHeader")
+	}
+
+	if header.Typeflag != tar.TypeReg {
+		return nil
+	}
+	return getNodeData(ctx, w.tw, w.repo, node)
+}
+
+func (w *tarWriter) Close() error {
+	return w.tw.Close()
+}
+
+func parseXattrs(xattrs []restic.ExtendedAttribute) map[string]string {
+	tmpMap := make(map[string]string)
+
+	for _, attr := range xattrs {
+		attrString := string(attr.Value)
+
+		if strings.HasPrefix(attr.Name, "system.posix_acl_") {
+			na := acl{}
+			na.decode(attr.Value)
+
+			if na.String() != "" {
+				if strings.Contains(attr.Name, "system.posix_acl_access") {
+					tmpMap["SCHILY.acl.access"] = na.String()
+				} else if strings.Contains(attr.Name, "system.posix_acl_default") {
+					tmpMap["SCHILY.acl.default"] = na.String()
+				}
+			}
+
+		} else {
+			tmpMap["SCHILY.xattr."+attr.Name] = attrString
+		}
+	}
+
+	return tmpMap
+}
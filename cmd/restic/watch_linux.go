@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchMask selects the inotify events which indicate a change.
+const watchMask = unix.IN_MODIFY | unix.IN_ATTRIB | unix.IN_CLOSE_WRITE |
+	unix.IN_CREATE | unix.IN_DELETE | unix.IN_DELETE_SELF |
+	unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_MOVE_SELF
+
+// inotifyWatcher reports changes to files and directories with inotify.
+type inotifyWatcher struct {
+	f      *os.File
+	fd     int
+	ignore []string
+
+	// paths maps the watch descriptors to the watched paths
+	paths map[int]string
+
+	ch chan struct{}
+}
+
+// watchChanges watches all targets and the directories below them for
+// changes, nothing below the directories in ignore is watched. The returned
+// channel receives a value whenever something was changed, several changes
+// which are not received in between are coalesced. Watching ends when ctx is
+// cancelled.
+func watchChanges(ctx context.Context, targets, ignore []string) (<-chan struct{}, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, errors.Wrap(err, "InotifyInit1")
+	}
+
+	w := &inotifyWatcher{
+		f:      os.NewFile(uintptr(fd), "inotify"),
+		fd:     fd,
+		ignore: ignore,
+		paths:  make(map[int]string),
+		ch:     make(chan struct{}, 1),
+	}
+
+	for _, target := range targets {
+		err = w.addTree(target)
+		if err != nil {
+			_ = w.f.Close()
+			return nil, err
+		}
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = w.f.Close()
+	}()
+
+	go w.run(ctx)
+
+	return w.ch, nil
+}
+
+// ignored returns true if p is one of the ignored directories or below one.
+func (w *inotifyWatcher) ignored(p string) bool {
+	for _, dir := range w.ignore {
+		if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// addTree adds watches for dir and all directories below it. Directories
+// which cannot be watched are skipped, unless the limit for the number of
+// watches was reached.
+func (w *inotifyWatcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			debug.Log("unable to watch %v: %v", p, err)
+			return nil
+		}
+
+		if w.ignored(p) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		// files are only watched when they were given as a target, changes
+		// to files below directories are reported by the directory
+		if !fi.IsDir() && p != dir {
+			return nil
+		}
+
+		wd, err := unix.InotifyAddWatch(w.fd, p, watchMask)
+		if err == unix.ENOSPC {
+			return errors.Fatal("too many directories to watch, please increase the limit in /proc/sys/fs/inotify/max_user_watches")
+		}
+		if err != nil {
+			debug.Log("unable to watch %v: %v", p, err)
+			return nil
+		}
+
+		w.paths[wd] = p
+		return nil
+	})
+}
+
+// notify reports a change without blocking.
+func (w *inotifyWatcher) notify() {
+	select {
+	case w.ch <- struct{}{}:
+	default:
+	}
+}
+
+// handle processes a single event.
+func (w *inotifyWatcher) handle(wd int, mask uint32, name string) {
+	if mask&unix.IN_Q_OVERFLOW != 0 {
+		debug.Log("event queue overflow")
+		w.notify()
+		return
+	}
+
+	dir, ok := w.paths[wd]
+	if !ok {
+		return
+	}
+
+	if mask&unix.IN_IGNORED != 0 {
+		// the watched path was removed
+		delete(w.paths, wd)
+		return
+	}
+
+	p := dir
+	if name != "" {
+		p = filepath.Join(dir, name)
+	}
+
+	if w.ignored(p) {
+		return
+	}
+
+	if mask&unix.IN_ISDIR != 0 && mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+		err := w.addTree(p)
+		if err != nil {
+			Warnf("%v\n", err)
+		}
+	}
+
+	debug.Log("change detected: %v (mask %#x)", p, mask)
+	w.notify()
+}
+
+// run reads events until the inotify file is closed.
+func (w *inotifyWatcher) run(ctx context.Context) {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				Warnf("unable to watch for changes: %v\n", err)
+			}
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			offset += unix.SizeofInotifyEvent
+
+			name := ""
+			if ev.Len > 0 {
+				name = strings.TrimRight(string(buf[offset:offset+int(ev.Len)]), "\x00")
+				offset += int(ev.Len)
+			}
+
+			w.handle(int(ev.Wd), ev.Mask, name)
+		}
+	}
+}
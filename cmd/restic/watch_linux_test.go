@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// waitForChange waits until a change is reported and then consumes all
+// further reports for the same change, which may arrive separately.
+func waitForChange(t testing.TB, ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change reported")
+	}
+
+	for {
+		select {
+		case <-ch:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func expectNoChange(t testing.TB, ch <-chan struct{}) {
+	select {
+	case <-ch:
+		t.Fatal("unexpected change reported")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatchChanges(t *testing.T) {
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	ignored := filepath.Join(tempdir, "ignored")
+	rtest.OK(t, os.Mkdir(ignored, 0700))
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+
+	ch, err := watchChanges(ctx, []string{tempdir}, []string{ignored})
+	rtest.OK(t, err)
+
+	rtest.OK(t, ioutil.WriteFile(filepath.Join(ignored, "file"), []byte("foo"), 0600))
+	expectNoChange(t, ch)
+
+	subdir := filepath.Join(tempdir, "subdir")
+	rtest.OK(t, os.Mkdir(subdir, 0700))
+	waitForChange(t, ch)
+
+	// directories created after the start are watched as well
+	rtest.OK(t, ioutil.WriteFile(filepath.Join(subdir, "file"), []byte("foo"), 0600))
+	waitForChange(t, ch)
+
+	rtest.OK(t, os.Remove(filepath.Join(subdir, "file")))
+	waitForChange(t, ch)
+	expectNoChange(t, ch)
+}
@@ -0,0 +1,10 @@
+// +build !linux
+
+package main
+
+import "context"
+
+// watchChanges is not supported on this system, it returns a nil channel.
+func watchChanges(ctx context.Context, targets, ignore []string) (<-chan struct{}, error) {
+	return nil, nil
+}
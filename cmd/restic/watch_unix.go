@@ -0,0 +1,17 @@
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchTriggerSignal returns a channel which receives SIGUSR1, which requests
+// a new snapshot from the watch command.
+func watchTriggerSignal() <-chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	return ch
+}
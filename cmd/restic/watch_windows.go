@@ -0,0 +1,9 @@
+package main
+
+import "os"
+
+// watchTriggerSignal returns nil, there is no signal to request a snapshot on
+// Windows.
+func watchTriggerSignal() <-chan os.Signal {
+	return nil
+}
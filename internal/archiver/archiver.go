@@ -78,10 +78,19 @@ type Archiver struct {
 	// WithAtime configures if the access time for files and directories should
 	// be saved. Enabling it may result in much metadata, so it's off by
 	// default.
-	WithAtime   bool
-	IgnoreInode bool
+	WithAtime bool
+
+	// ChangeIgnoreFlags selects the metadata which is not used to detect
+	// whether a file has changed since the previous snapshot.
+	ChangeIgnoreFlags uint
 }
 
+// Flags for the ChangeIgnoreFlags bitfield.
+const (
+	ChangeIgnoreCtime = 1 << iota
+	ChangeIgnoreInode
+)
+
 // Options is used to configure the archiver.
 type Options struct {
 	// FileReadConcurrency sets how many files are read in concurrently. If
@@ -134,7 +143,6 @@ func New(repo restic.Repository, fs fs.FS, opts Options) *Archiver {
 		CompleteItem: func(string, *restic.Node, *restic.Node, ItemStats, time.Duration) {},
 		StartFile:    func(string) {},
 		CompleteBlob: func(string, uint64) {},
-		IgnoreInode:  false,
 	}
 
 	return arch
@@ -385,7 +393,7 @@ func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous
 		}
 
 		// use previous list of blobs if the file hasn't changed
-		if previous != nil && !fileChanged(fi, previous, arch.IgnoreInode) {
+		if previous != nil && !fileChanged(fi, previous, arch.ChangeIgnoreFlags) {
 			debug.Log("%v hasn't changed, using old list of blobs", target)
 			arch.CompleteItem(snPath, previous, previous, ItemStats{}, time.Since(start))
 			arch.CompleteBlob(snPath, previous.Size)
@@ -445,7 +453,7 @@ func (arch *Archiver) Save(ctx context.Context, snPath, target string, previous
 
 // fileChanged returns true if the file's content has changed since the node
 // was created.
-func fileChanged(fi os.FileInfo, node *restic.Node, ignoreInode bool) bool {
+func fileChanged(fi os.FileInfo, node *restic.Node, ignoreFlags uint) bool {
 	if node == nil {
 		return true
 	}
@@ -462,7 +470,7 @@ func fileChanged(fi os.FileInfo, node *restic.Node, ignoreInode bool) bool {
 
 	// check status change timestamp
 	extFI := fs.ExtendedStat(fi)
-	if !ignoreInode && !extFI.ChangeTime.Equal(node.ChangeTime) {
+	if ignoreFlags&ChangeIgnoreCtime == 0 && !extFI.ChangeTime.Equal(node.ChangeTime) {
 		return true
 	}
 
@@ -472,7 +480,7 @@ func fileChanged(fi os.FileInfo, node *restic.Node, ignoreInode bool) bool {
 	}
 
 	// check inode
-	if !ignoreInode && node.Inode != extFI.Inode {
+	if ignoreFlags&ChangeIgnoreInode == 0 && node.Inode != extFI.Inode {
 		return true
 	}
 
@@ -0,0 +1,100 @@
+// Package appendonly implements a backend wrapper which prevents existing
+// files from being removed. It only guards against mistakes of the client,
+// protection against a compromised client must be enforced by the server,
+// e.g. by "restic serve --append-only".
+package appendonly
+
+import (
+	"context"
+	"sync"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// ErrAppendOnly is returned for all operations which would remove a file.
+var ErrAppendOnly = errors.New("repository is in append-only mode")
+
+// Backend passes all operations to the underlying backend, except for those
+// which would remove existing files. Lock files are exempt, so that restic can
+// still lock the repository. Files which this backend failed to save may also
+// be removed, so that the remains of failed uploads can be cleaned up.
+type Backend struct {
+	restic.Backend
+
+	m      sync.Mutex
+	failed map[restic.Handle]struct{}
+}
+
+// statically ensure that Backend implements restic.Backend.
+var _ restic.Backend = &Backend{}
+
+// New returns a backend which does not allow removing files in be.
+func New(be restic.Backend) *Backend {
+	return &Backend{Backend: be, failed: make(map[restic.Handle]struct{})}
+}
+
+// errAppendOnly returns ErrAppendOnly, marked so that the operation is not
+// retried.
+func errAppendOnly(op string, h restic.Handle) error {
+	return backend.Permanent(errors.Wrapf(ErrAppendOnly, "%v %v", op, h))
+}
+
+// readRecorder records whether data was read from the RewindReader.
+type readRecorder struct {
+	restic.RewindReader
+	read bool
+}
+
+func (rd *readRecorder) Read(p []byte) (int, error) {
+	rd.read = true
+	return rd.RewindReader.Read(p)
+}
+
+// Save stores the data in the backend under the given handle. Overwriting
+// existing files is left to the underlying backend to refuse, checking for
+// the file first would cost an additional request for every file.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	rec := &readRecorder{RewindReader: rd}
+	err := be.Backend.Save(ctx, h, rec)
+
+	// remember failed uploads so that their remains can be removed. A file
+	// is only written after reading data, so a backend which refuses to
+	// overwrite an existing file does not mark it as failed.
+	be.m.Lock()
+	if err != nil && rec.read {
+		be.failed[h] = struct{}{}
+	} else if err == nil {
+		delete(be.failed, h)
+	}
+	be.m.Unlock()
+
+	return err
+}
+
+// Remove removes lock files and files which could not be saved, an error is
+// returned for all other files.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	if h.Type != restic.LockFile && !be.hasFailed(h) {
+		debug.Log("refusing to remove %v", h)
+		return errAppendOnly("Remove", h)
+	}
+
+	return be.Backend.Remove(ctx, h)
+}
+
+// hasFailed returns true if saving the file h failed before.
+func (be *Backend) hasFailed(h restic.Handle) bool {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	_, ok := be.failed[h]
+	return ok
+}
+
+// Delete returns an error, the repository cannot be deleted.
+func (be *Backend) Delete(ctx context.Context) error {
+	return backend.Permanent(errors.Wrap(ErrAppendOnly, "Delete"))
+}
@@ -0,0 +1,113 @@
+package appendonly_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/appendonly"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestAppendOnly(t *testing.T) {
+	ctx := context.TODO()
+	be := appendonly.New(mem.New())
+
+	data := rtest.Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data)))
+
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	// the backend refuses to overwrite the file, which does not allow
+	// removing it afterwards
+	err = be.Save(ctx, h, restic.NewByteReader([]byte("foo")))
+	rtest.Assert(t, err != nil, "overwriting the file did not return an error")
+
+	err = be.Remove(ctx, h)
+	rtest.Assert(t, errors.Cause(err) == appendonly.ErrAppendOnly, "wrong error for removing file: %v", err)
+	rtest.Assert(t, backend.IsPermanent(err), "error is not permanent: %v", err)
+
+	buf, err = backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	// lock files can be created and removed
+	lock := restic.Handle{Type: restic.LockFile, Name: restic.NewRandomID().String()}
+	rtest.OK(t, be.Save(ctx, lock, restic.NewByteReader([]byte("lock"))))
+	rtest.OK(t, be.Remove(ctx, lock))
+
+	err = be.Delete(ctx)
+	rtest.Assert(t, errors.Cause(err) == appendonly.ErrAppendOnly, "wrong error for deleting the repository: %v", err)
+}
+
+// partialBackend stores only the first byte of the next failures files and
+// returns an error.
+type partialBackend struct {
+	restic.Backend
+	failures int
+}
+
+func (be *partialBackend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	if be.failures > 0 {
+		be.failures--
+		buf := make([]byte, 1)
+		_, err := io.ReadFull(rd, buf)
+		if err != nil {
+			return err
+		}
+		err = be.Backend.Save(ctx, h, restic.NewByteReader(buf))
+		if err != nil {
+			return err
+		}
+		return errors.New("connection reset")
+	}
+	return be.Backend.Save(ctx, h, rd)
+}
+
+// Capabilities returns the properties of the backend, which leaves partial
+// files behind.
+func (be *partialBackend) Capabilities() restic.Capabilities {
+	caps := be.Backend.Capabilities()
+	caps.AtomicSave = false
+	return caps
+}
+
+func TestAppendOnlyRetry(t *testing.T) {
+	ctx := context.TODO()
+	partial := &partialBackend{Backend: mem.New(), failures: 1}
+	be := backend.NewRetryBackend(appendonly.New(partial), 2, nil)
+
+	// the remains of the failed upload are removed before the retry
+	data := rtest.Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data)))
+
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	// the remains of a failed upload can be removed later
+	partial.failures = 1
+	data = rtest.Random(42, 1000)
+	h = restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	aobe := appendonly.New(partial)
+	err = aobe.Save(ctx, h, restic.NewByteReader(data))
+	rtest.Assert(t, err != nil, "Save did not return an error")
+	rtest.OK(t, aobe.Remove(ctx, h))
+	rtest.OK(t, aobe.Save(ctx, h, restic.NewByteReader(data)))
+
+	buf, err = backend.LoadAll(ctx, nil, aobe, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	// the complete file is protected again
+	err = aobe.Remove(ctx, h)
+	rtest.Assert(t, errors.Cause(err) == appendonly.ErrAppendOnly, "wrong error for removing file: %v", err)
+}
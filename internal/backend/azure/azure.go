@@ -111,6 +111,15 @@ func (be *Backend) Location() string {
 	return be.Join(be.container.Name, be.prefix)
 }
 
+// Capabilities returns the properties of the backend.
+func (be *Backend) Capabilities() restic.Capabilities {
+	// a blob only becomes visible after the upload or the list of blocks
+	// has been committed
+	return restic.Capabilities{
+		AtomicSave: true,
+	}
+}
+
 // Path returns the path in the bucket that is used for this backend.
 func (be *Backend) Path() string {
 	return be.prefix
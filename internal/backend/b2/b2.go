@@ -136,6 +136,14 @@ func (be *b2Backend) Location() string {
 	return be.cfg.Bucket
 }
 
+// Capabilities returns the properties of the backend.
+func (be *b2Backend) Capabilities() restic.Capabilities {
+	// a file only becomes visible after the upload has been completed
+	return restic.Capabilities{
+		AtomicSave: true,
+	}
+}
+
 // IsNotExist returns true if the error is caused by a non-existing file.
 func (be *b2Backend) IsNotExist(err error) bool {
 	return b2.IsNotExist(errors.Cause(err))
@@ -8,6 +8,7 @@ import (
 
 	"github.com/cenkalti/backoff"
 	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
 )
 
@@ -17,6 +18,12 @@ type RetryBackend struct {
 	restic.Backend
 	MaxTries int
 	Report   func(string, error, time.Duration)
+
+	// Timeout limits the duration of a single attempt of all operations
+	// except List, zero means no limit. For Save and Load, it limits the time
+	// without progress of the transfer, the time the consumer of Load takes
+	// is not included. Attempts which time out are retried.
+	Timeout time.Duration
 }
 
 // statically ensure that RetryBackend implements restic.Backend.
@@ -32,8 +39,58 @@ func NewRetryBackend(be restic.Backend, maxTries int, report func(string, error,
 	}
 }
 
+// permanentError marks an error which is not resolved by retrying the
+// operation.
+type permanentError struct {
+	error
+}
+
+// Cause returns the underlying error.
+func (e permanentError) Cause() error {
+	return e.error
+}
+
+// Permanent marks err so that RetryBackend does not retry the operation
+// which returned it, e.g. because the credentials were rejected.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent returns true if err or one of the errors it wraps was marked
+// with Permanent.
+func IsPermanent(err error) bool {
+	for err != nil {
+		if _, ok := err.(permanentError); ok {
+			return true
+		}
+
+		cause, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = cause.Cause()
+	}
+
+	return false
+}
+
 func (be *RetryBackend) retry(ctx context.Context, msg string, f func() error) error {
-	err := backoff.RetryNotify(f,
+	// don't retry operations which cannot succeed on a second try, such as
+	// requests with invalid credentials. Files which do not exist may still
+	// appear on backends which are only eventually consistent.
+	op := func() error {
+		err := f()
+		if err != nil && IsPermanent(err) {
+			debug.Log("%v failed with permanent error: %v", msg, err)
+			return backoff.Permanent(err)
+		}
+		return err
+	}
+
+	err := backoff.RetryNotify(op,
 		backoff.WithContext(backoff.WithMaxRetries(backoff.NewExponentialBackOff(), uint64(be.MaxTries)), ctx),
 		func(err error, d time.Duration) {
 			if be.Report != nil {
@@ -45,23 +102,45 @@ func (be *RetryBackend) retry(ctx context.Context, msg string, f func() error) e
 	return err
 }
 
+// withTimeout returns a context for a single attempt of an operation, which
+// is cancelled after be.Timeout.
+func (be *RetryBackend) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if be.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, be.Timeout)
+}
+
 // Save stores the data in the backend under the given handle.
 func (be *RetryBackend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	caps := be.Backend.Capabilities()
+	if caps.MaxFileSize > 0 && rd.Length() > caps.MaxFileSize {
+		return Permanent(errors.Errorf("Save(%v): file size %d exceeds the maximum file size %d of the backend",
+			h, rd.Length(), caps.MaxFileSize))
+	}
+
 	return be.retry(ctx, fmt.Sprintf("Save(%v)", h), func() error {
 		err := rd.Rewind()
 		if err != nil {
 			return err
 		}
 
-		err = be.Backend.Save(ctx, h, rd)
+		ictx := newIdleContext(ctx, be.Timeout)
+		err = be.Backend.Save(ictx, h, idleRewindReader{RewindReader: rd, ctx: ictx})
+		ictx.stop()
 		if err == nil {
 			return nil
 		}
 
-		debug.Log("Save(%v) failed with error, removing file: %v", h, err)
-		rerr := be.Backend.Remove(ctx, h)
-		if rerr != nil {
-			debug.Log("Remove(%v) returned error: %v", h, err)
+		// only backends which save files atomically never leave partial files,
+		// permanent errors are returned before anything was written, e.g.
+		// when the file already exists
+		if !caps.AtomicSave && !IsPermanent(err) {
+			debug.Log("Save(%v) failed with error, removing file: %v", h, err)
+			rerr := be.Backend.Remove(ctx, h)
+			if rerr != nil {
+				debug.Log("Remove(%v) returned error: %v", h, err)
+			}
 		}
 
 		// return original error
@@ -76,7 +155,13 @@ func (be *RetryBackend) Save(ctx context.Context, h restic.Handle, rd restic.Rew
 func (be *RetryBackend) Load(ctx context.Context, h restic.Handle, length int, offset int64, consumer func(rd io.Reader) error) (err error) {
 	return be.retry(ctx, fmt.Sprintf("Load(%v, %v, %v)", h, length, offset),
 		func() error {
-			return be.Backend.Load(ctx, h, length, offset, consumer)
+			ictx := newIdleContext(ctx, be.Timeout)
+			defer ictx.stop()
+			return be.Backend.Load(ictx, h, length, offset, func(rd io.Reader) error {
+				ictx.pause()
+				defer ictx.progress()
+				return consumer(idleReader{Reader: rd, ctx: ictx})
+			})
 		})
 }
 
@@ -84,8 +169,11 @@ func (be *RetryBackend) Load(ctx context.Context, h restic.Handle, length int, o
 func (be *RetryBackend) Stat(ctx context.Context, h restic.Handle) (fi restic.FileInfo, err error) {
 	err = be.retry(ctx, fmt.Sprintf("Stat(%v)", h),
 		func() error {
+			tctx, cancel := be.withTimeout(ctx)
+			defer cancel()
+
 			var innerError error
-			fi, innerError = be.Backend.Stat(ctx, h)
+			fi, innerError = be.Backend.Stat(tctx, h)
 
 			return innerError
 		})
@@ -95,15 +183,20 @@ func (be *RetryBackend) Stat(ctx context.Context, h restic.Handle) (fi restic.Fi
 // Remove removes a File with type t and name.
 func (be *RetryBackend) Remove(ctx context.Context, h restic.Handle) (err error) {
 	return be.retry(ctx, fmt.Sprintf("Remove(%v)", h), func() error {
-		return be.Backend.Remove(ctx, h)
+		tctx, cancel := be.withTimeout(ctx)
+		defer cancel()
+		return be.Backend.Remove(tctx, h)
 	})
 }
 
 // Test a boolean value whether a File with the name and type exists.
 func (be *RetryBackend) Test(ctx context.Context, h restic.Handle) (exists bool, err error) {
 	err = be.retry(ctx, fmt.Sprintf("Test(%v)", h), func() error {
+		tctx, cancel := be.withTimeout(ctx)
+		defer cancel()
+
 		var innerError error
-		exists, innerError = be.Backend.Test(ctx, h)
+		exists, innerError = be.Backend.Test(tctx, h)
 
 		return innerError
 	})
@@ -6,6 +6,7 @@ import (
 	"io"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/mock"
@@ -52,6 +53,94 @@ func TestBackendSaveRetry(t *testing.T) {
 	}
 }
 
+func TestBackendSaveRemovePartial(t *testing.T) {
+	for _, atomicSave := range []bool{false, true} {
+		attempt, removed := 0, 0
+		be := &mock.Backend{
+			SaveFn: func(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+				attempt++
+				if attempt == 1 {
+					return errors.New("injected error")
+				}
+				return nil
+			},
+			RemoveFn: func(ctx context.Context, h restic.Handle) error {
+				removed++
+				return nil
+			},
+			CapsFn: func() restic.Capabilities {
+				return restic.Capabilities{AtomicSave: atomicSave}
+			},
+		}
+
+		retryBackend := RetryBackend{
+			MaxTries: 2,
+			Backend:  be,
+		}
+
+		test.OK(t, retryBackend.Save(context.TODO(), restic.Handle{}, restic.NewByteReader([]byte("foo"))))
+		test.Equals(t, 2, attempt)
+
+		// partial files are only removed if the backend may leave them
+		if atomicSave {
+			test.Equals(t, 0, removed)
+		} else {
+			test.Equals(t, 1, removed)
+		}
+	}
+}
+
+func TestBackendSavePermanentKeepsFile(t *testing.T) {
+	attempt, removed := 0, 0
+	be := &mock.Backend{
+		SaveFn: func(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+			attempt++
+			return Permanent(errors.New("file already exists"))
+		},
+		RemoveFn: func(ctx context.Context, h restic.Handle) error {
+			removed++
+			return nil
+		},
+	}
+
+	retryBackend := RetryBackend{
+		MaxTries: 5,
+		Backend:  be,
+	}
+
+	err := retryBackend.Save(context.TODO(), restic.Handle{}, restic.NewByteReader([]byte("foo")))
+	test.Assert(t, err != nil, "Save did not return an error")
+	test.Equals(t, 1, attempt)
+
+	// the existing file must not be removed
+	test.Equals(t, 0, removed)
+}
+
+func TestBackendSaveMaxFileSize(t *testing.T) {
+	attempt := 0
+	be := &mock.Backend{
+		SaveFn: func(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+			attempt++
+			return nil
+		},
+		CapsFn: func() restic.Capabilities {
+			return restic.Capabilities{MaxFileSize: 10}
+		},
+	}
+
+	retryBackend := RetryBackend{
+		MaxTries: 5,
+		Backend:  be,
+	}
+
+	test.OK(t, retryBackend.Save(context.TODO(), restic.Handle{}, restic.NewByteReader(make([]byte, 10))))
+	test.Equals(t, 1, attempt)
+
+	err := retryBackend.Save(context.TODO(), restic.Handle{}, restic.NewByteReader(make([]byte, 11)))
+	test.Assert(t, IsPermanent(err), "error not marked as permanent: %v", err)
+	test.Equals(t, 1, attempt)
+}
+
 func TestBackendListRetry(t *testing.T) {
 	const (
 		ID1 = "id1"
@@ -247,3 +336,113 @@ func TestBackendLoadRetry(t *testing.T) {
 	test.Equals(t, data, buf)
 	test.Equals(t, 2, attempt)
 }
+
+func TestBackendStatNotExists(t *testing.T) {
+	errNotExist := errors.New("not found")
+	attempt := 0
+
+	be := mock.NewBackend()
+	be.StatFn = func(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+		attempt++
+		return restic.FileInfo{}, errors.Wrap(errNotExist, "Stat")
+	}
+	be.IsNotExistFn = func(err error) bool {
+		return errors.Cause(err) == errNotExist
+	}
+
+	retryBackend := RetryBackend{
+		MaxTries: 2,
+		Backend:  be,
+	}
+
+	// the file may still appear, so the request is retried
+	_, err := retryBackend.Stat(context.TODO(), restic.Handle{})
+	test.Assert(t, be.IsNotExist(err), "wrong error returned: %v", err)
+	test.Equals(t, 3, attempt)
+}
+
+func TestBackendPermanentError(t *testing.T) {
+	errAuth := errors.New("access denied")
+	attempt := 0
+
+	be := mock.NewBackend()
+	be.SaveFn = func(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+		attempt++
+		return errors.Wrap(Permanent(errAuth), "Save")
+	}
+
+	retryBackend := RetryBackend{
+		MaxTries: 5,
+		Backend:  be,
+	}
+
+	err := retryBackend.Save(context.TODO(), restic.Handle{}, restic.NewByteReader([]byte("foo")))
+	test.Assert(t, IsPermanent(err), "error not marked as permanent: %v", err)
+	test.Equals(t, errAuth, errors.Cause(err))
+	test.Equals(t, 1, attempt)
+}
+
+func TestBackendTimeout(t *testing.T) {
+	attempt := 0
+
+	be := mock.NewBackend()
+	be.StatFn = func(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+		attempt++
+		if attempt == 1 {
+			// the first attempt hangs until it is cancelled
+			<-ctx.Done()
+			return restic.FileInfo{}, ctx.Err()
+		}
+		return restic.FileInfo{Size: 23}, nil
+	}
+
+	retryBackend := RetryBackend{
+		MaxTries: 5,
+		Backend:  be,
+		Timeout:  10 * time.Millisecond,
+	}
+
+	fi, err := retryBackend.Stat(context.TODO(), restic.Handle{})
+	test.OK(t, err)
+	test.Equals(t, int64(23), fi.Size)
+	test.Equals(t, 2, attempt)
+}
+
+func TestBackendLoadIdleTimeout(t *testing.T) {
+	data := test.Random(23, 1000)
+	attempt := 0
+
+	be := mock.NewBackend()
+	be.OpenReaderFn = func(ctx context.Context, h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+		attempt++
+		if attempt == 1 {
+			// the first attempt hangs until it is cancelled
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+
+	retryBackend := RetryBackend{
+		MaxTries: 5,
+		Backend:  NewStatsBackend(be),
+		Timeout:  20 * time.Millisecond,
+	}
+
+	var buf []byte
+	err := retryBackend.Load(context.TODO(), restic.Handle{}, 0, 0, func(rd io.Reader) error {
+		// a slow consumer does not cause a timeout
+		time.Sleep(100 * time.Millisecond)
+
+		var err error
+		buf, err = ioutil.ReadAll(rd)
+		return err
+	})
+	test.OK(t, err)
+	test.Equals(t, data, buf)
+	test.Equals(t, 2, attempt)
+
+	// the timeout is counted as an error
+	stats := retryBackend.Backend.(*StatsBackend).Stats()
+	test.Equals(t, uint64(1), stats.Errors)
+}
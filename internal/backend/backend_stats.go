@@ -0,0 +1,121 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// TransferStats contains counters for the operations executed on a backend.
+type TransferStats struct {
+	Requests        uint64
+	Errors          uint64
+	Retries         uint64
+	BytesUploaded   uint64
+	BytesDownloaded uint64
+}
+
+// StatsBackend counts the requests sent to the underlying backend and the
+// number of bytes transferred.
+type StatsBackend struct {
+	// stats must be the first field so that the counters are aligned
+	// correctly for atomic access on 32 bit platforms.
+	stats TransferStats
+	restic.Backend
+}
+
+// statically ensure that StatsBackend implements restic.Backend.
+var _ restic.Backend = &StatsBackend{}
+
+// NewStatsBackend wraps be with a backend that collects transfer statistics.
+func NewStatsBackend(be restic.Backend) *StatsBackend {
+	return &StatsBackend{Backend: be}
+}
+
+// Stats returns a snapshot of the current counters.
+func (be *StatsBackend) Stats() TransferStats {
+	return TransferStats{
+		Requests:        atomic.LoadUint64(&be.stats.Requests),
+		Errors:          atomic.LoadUint64(&be.stats.Errors),
+		Retries:         atomic.LoadUint64(&be.stats.Retries),
+		BytesUploaded:   atomic.LoadUint64(&be.stats.BytesUploaded),
+		BytesDownloaded: atomic.LoadUint64(&be.stats.BytesDownloaded),
+	}
+}
+
+// AddRetry records that an operation is retried, it is called by the
+// RetryBackend which wraps this backend.
+func (be *StatsBackend) AddRetry() {
+	atomic.AddUint64(&be.stats.Retries, 1)
+}
+
+// count records a request which returned err. Errors caused by files which
+// do not exist or by cancelling ctx are not counted, but timeouts are.
+func (be *StatsBackend) count(ctx context.Context, err error) {
+	atomic.AddUint64(&be.stats.Requests, 1)
+	if err != nil && !be.Backend.IsNotExist(err) && ctx.Err() != context.Canceled {
+		atomic.AddUint64(&be.stats.Errors, 1)
+	}
+}
+
+// Save stores the data in the backend under the given handle.
+func (be *StatsBackend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	err := be.Backend.Save(ctx, h, rd)
+	be.count(ctx, err)
+	if err == nil {
+		atomic.AddUint64(&be.stats.BytesUploaded, uint64(rd.Length()))
+	}
+	return err
+}
+
+// countingReader counts the number of bytes read from the underlying reader.
+type countingReader struct {
+	io.Reader
+	n *uint64
+}
+
+func (rd countingReader) Read(p []byte) (int, error) {
+	n, err := rd.Reader.Read(p)
+	atomic.AddUint64(rd.n, uint64(n))
+	return n, err
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (be *StatsBackend) Load(ctx context.Context, h restic.Handle, length int, offset int64, consumer func(rd io.Reader) error) error {
+	err := be.Backend.Load(ctx, h, length, offset, func(rd io.Reader) error {
+		return consumer(countingReader{Reader: rd, n: &be.stats.BytesDownloaded})
+	})
+	be.count(ctx, err)
+	return err
+}
+
+// Stat returns information about the File identified by h.
+func (be *StatsBackend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	fi, err := be.Backend.Stat(ctx, h)
+	be.count(ctx, err)
+	return fi, err
+}
+
+// Test returns a boolean value whether a File with the name and type exists.
+func (be *StatsBackend) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	found, err := be.Backend.Test(ctx, h)
+	be.count(ctx, err)
+	return found, err
+}
+
+// Remove removes a File with type t and name.
+func (be *StatsBackend) Remove(ctx context.Context, h restic.Handle) error {
+	err := be.Backend.Remove(ctx, h)
+	be.count(ctx, err)
+	return err
+}
+
+// List runs fn for each file in the backend which has the type t.
+func (be *StatsBackend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	err := be.Backend.List(ctx, t, fn)
+	be.count(ctx, err)
+	return err
+}
@@ -0,0 +1,40 @@
+package backend_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestStatsBackend(t *testing.T) {
+	ctx := context.TODO()
+	be := backend.NewStatsBackend(mem.New())
+
+	data := rtest.Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data)))
+
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	// missing files are not counted as errors
+	_, err = be.Stat(ctx, restic.Handle{Type: restic.DataFile, Name: restic.NewRandomID().String()})
+	rtest.Assert(t, be.IsNotExist(err), "wrong error returned: %v", err)
+
+	// saving the same file twice fails
+	rtest.Assert(t, be.Save(ctx, h, restic.NewByteReader(data)) != nil, "Save did not return an error")
+	be.AddRetry()
+
+	rtest.Equals(t, backend.TransferStats{
+		Requests:        4,
+		Errors:          1,
+		Retries:         1,
+		BytesUploaded:   1000,
+		BytesDownloaded: 1000,
+	}, be.Stats())
+}
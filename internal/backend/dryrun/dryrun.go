@@ -0,0 +1,51 @@
+// Package dryrun implements a backend wrapper which discards all changes, it
+// is used to find out what an operation would do to the repository.
+package dryrun
+
+import (
+	"context"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Backend passes all read operations to the underlying backend and discards
+// all operations which would modify it. Lock files are exempt, so that the
+// repository is still locked while the dry run is in progress.
+type Backend struct {
+	restic.Backend
+}
+
+// statically ensure that Backend implements restic.Backend.
+var _ restic.Backend = &Backend{}
+
+// New returns a backend which does not modify be.
+func New(be restic.Backend) *Backend {
+	return &Backend{Backend: be}
+}
+
+// Save discards the data unless h is a lock file.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	if h.Type == restic.LockFile {
+		return be.Backend.Save(ctx, h, rd)
+	}
+
+	debug.Log("dry run, not saving %v (%d bytes)", h, rd.Length())
+	return nil
+}
+
+// Remove removes lock files and does nothing for all other files.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	if h.Type == restic.LockFile {
+		return be.Backend.Remove(ctx, h)
+	}
+
+	debug.Log("dry run, not removing %v", h)
+	return nil
+}
+
+// Delete does nothing.
+func (be *Backend) Delete(ctx context.Context) error {
+	debug.Log("dry run, not deleting the repository")
+	return nil
+}
@@ -0,0 +1,52 @@
+package dryrun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/dryrun"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestDryRun(t *testing.T) {
+	ctx := context.TODO()
+	m := mem.New()
+	be := dryrun.New(m)
+
+	data := rtest.Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, m.Save(ctx, h, restic.NewByteReader(data)))
+
+	// existing files can be read
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	// saving and removing files has no effect
+	other := restic.Handle{Type: restic.SnapshotFile, Name: restic.NewRandomID().String()}
+	rtest.OK(t, be.Save(ctx, other, restic.NewByteReader([]byte("foo"))))
+	found, err := m.Test(ctx, other)
+	rtest.OK(t, err)
+	rtest.Assert(t, !found, "file %v was saved", other)
+
+	rtest.OK(t, be.Remove(ctx, h))
+	rtest.OK(t, be.Delete(ctx))
+	found, err = m.Test(ctx, h)
+	rtest.OK(t, err)
+	rtest.Assert(t, found, "file %v was removed", h)
+
+	// lock files can be created and removed
+	lock := restic.Handle{Type: restic.LockFile, Name: restic.NewRandomID().String()}
+	rtest.OK(t, be.Save(ctx, lock, restic.NewByteReader([]byte("lock"))))
+	found, err = m.Test(ctx, lock)
+	rtest.OK(t, err)
+	rtest.Assert(t, found, "lock file %v was not saved", lock)
+
+	rtest.OK(t, be.Remove(ctx, lock))
+	found, err = m.Test(ctx, lock)
+	rtest.OK(t, err)
+	rtest.Assert(t, !found, "lock file %v was not removed", lock)
+}
@@ -0,0 +1,220 @@
+// Package failover implements a backend which writes to a secondary backend
+// while the primary one is unavailable.
+package failover
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Backend saves files to the primary backend. As soon as saving a file to the
+// primary backend fails MaxTries+1 times in a row, all further files are saved
+// to the secondary backend. Files are read from the primary backend first, the secondary
+// backend is used for files which are missing or cannot be read.
+type Backend struct {
+	primary, secondary restic.Backend
+
+	// MaxTries is the number of times saving a file to the primary backend
+	// is retried before switching to the secondary backend.
+	MaxTries int
+
+	// ReportRetry is called when saving a file to the primary backend is
+	// retried.
+	ReportRetry func(string, error, time.Duration)
+
+	// Report is called with the error returned by the primary backend when
+	// switching to the secondary backend.
+	Report func(error)
+
+	// failed is set to 1 after the primary backend failed to save a file.
+	failed int32
+}
+
+// statically ensure that Backend implements restic.Backend.
+var _ restic.Backend = &Backend{}
+
+// New returns a backend which fails over from primary to secondary.
+func New(primary, secondary restic.Backend) *Backend {
+	return &Backend{primary: primary, secondary: secondary}
+}
+
+// Location returns the location of the primary backend.
+func (be *Backend) Location() string {
+	return be.primary.Location()
+}
+
+// Capabilities returns the properties offered by both backends.
+func (be *Backend) Capabilities() restic.Capabilities {
+	return be.primary.Capabilities().Intersect(be.secondary.Capabilities())
+}
+
+// Save stores the data in the primary backend, or in the secondary backend
+// if this fails after all retries.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	if atomic.LoadInt32(&be.failed) == 0 {
+		primary := be.primary
+		if be.MaxTries > 0 {
+			primary = backend.NewRetryBackend(be.primary, be.MaxTries, be.ReportRetry)
+		}
+
+		err := primary.Save(ctx, h, rd)
+		if err == nil || backend.IsPermanent(err) || ctx.Err() != nil {
+			return err
+		}
+
+		debug.Log("Save(%v) to %v failed, switching to %v: %v", h, be.primary.Location(), be.secondary.Location(), err)
+		if atomic.CompareAndSwapInt32(&be.failed, 0, 1) && be.Report != nil {
+			be.Report(err)
+		}
+
+		// remove the remains of the failed upload, if possible
+		_ = be.primary.Remove(ctx, h)
+
+		if err = rd.Rewind(); err != nil {
+			return err
+		}
+	}
+
+	return be.secondary.Save(ctx, h, rd)
+}
+
+// Remove removes the file from both backends. Files which do not exist in
+// one of the backends are ignored, unless the file does not exist in either.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	removed := false
+	var notExistErr error
+	for _, b := range []restic.Backend{be.primary, be.secondary} {
+		err := b.Remove(ctx, h)
+		if err != nil && b.IsNotExist(err) {
+			notExistErr = err
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "Remove(%v) from %v", h, b.Location())
+		}
+		removed = true
+	}
+
+	if !removed {
+		return notExistErr
+	}
+
+	return nil
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset. If loading the file from the primary backend fails, it is
+// loaded from the secondary backend.
+func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	err := be.primary.Load(ctx, h, length, offset, fn)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	debug.Log("Load(%v) from %v failed: %v", h, be.primary.Location(), err)
+	serr := be.secondary.Load(ctx, h, length, offset, fn)
+	if serr != nil && be.secondary.IsNotExist(serr) {
+		// report the original error
+		return err
+	}
+
+	return serr
+}
+
+// Stat returns information about the file identified by h, which is looked up
+// in the secondary backend if the primary backend fails.
+func (be *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	fi, err := be.primary.Stat(ctx, h)
+	if err == nil || ctx.Err() != nil {
+		return fi, err
+	}
+
+	debug.Log("Stat(%v) on %v failed: %v", h, be.primary.Location(), err)
+	fi, serr := be.secondary.Stat(ctx, h)
+	if serr != nil && be.secondary.IsNotExist(serr) {
+		return fi, err
+	}
+
+	return fi, serr
+}
+
+// Test returns whether the file exists in either backend.
+func (be *Backend) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	found, err := be.primary.Test(ctx, h)
+	if (err == nil && found) || ctx.Err() != nil {
+		return found, err
+	}
+
+	if err != nil {
+		debug.Log("Test(%v) on %v failed: %v", h, be.primary.Location(), err)
+	}
+
+	found, serr := be.secondary.Test(ctx, h)
+	if serr == nil && !found && err != nil {
+		return false, err
+	}
+
+	return found, serr
+}
+
+// List runs fn for each file in both backends. Files which are stored in both
+// backends are only reported once. If listing the primary backend fails
+// before fn has been called, only the files in the secondary backend are
+// listed.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	seen := make(map[string]struct{})
+	err := be.primary.List(ctx, t, func(fi restic.FileInfo) error {
+		seen[fi.Name] = struct{}{}
+		return fn(fi)
+	})
+	if err != nil && (len(seen) > 0 || ctx.Err() != nil) {
+		return err
+	}
+
+	if err != nil {
+		debug.Log("List(%v) on %v failed: %v", t, be.primary.Location(), err)
+	}
+
+	return be.secondary.List(ctx, t, func(fi restic.FileInfo) error {
+		if _, ok := seen[fi.Name]; ok {
+			return nil
+		}
+		return fn(fi)
+	})
+}
+
+// IsNotExist returns true if the error was caused by a non-existing file in
+// either backend.
+func (be *Backend) IsNotExist(err error) bool {
+	return be.primary.IsNotExist(err) || be.secondary.IsNotExist(err)
+}
+
+// Delete removes all data in both backends.
+func (be *Backend) Delete(ctx context.Context) error {
+	for _, b := range []restic.Backend{be.primary, be.secondary} {
+		err := b.Delete(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "Delete %v", b.Location())
+		}
+	}
+
+	return nil
+}
+
+// Close closes both backends and returns the first error.
+func (be *Backend) Close() error {
+	err := be.primary.Close()
+	if serr := be.secondary.Close(); err == nil {
+		err = serr
+	}
+
+	return err
+}
@@ -0,0 +1,183 @@
+package failover_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/failover"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type failoverConfig struct {
+	be restic.Backend
+}
+
+func newTestSuite() *test.Suite {
+	return &test.Suite{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			return &failoverConfig{}, nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*failoverConfig)
+			if c.be != nil {
+				ok, err := c.be.Test(context.TODO(), restic.Handle{Type: restic.ConfigFile})
+				if err != nil {
+					return nil, err
+				}
+
+				if ok {
+					return nil, errors.New("config already exists")
+				}
+			}
+
+			c.be = failover.New(mem.New(), mem.New())
+			return c.be, nil
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*failoverConfig)
+			if c.be == nil {
+				c.be = failover.New(mem.New(), mem.New())
+			}
+			return c.be, nil
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(cfg interface{}) error {
+			// no cleanup needed
+			return nil
+		},
+	}
+}
+
+func TestSuiteBackendFailover(t *testing.T) {
+	newTestSuite().RunTests(t)
+}
+
+func listNames(t testing.TB, be restic.Backend, tpe restic.FileType) []string {
+	var names []string
+	rtest.OK(t, be.List(context.TODO(), tpe, func(fi restic.FileInfo) error {
+		names = append(names, fi.Name)
+		return nil
+	}))
+	sort.Strings(names)
+	return names
+}
+
+// flakyBackend fails to save files while broken is set, and for the next
+// failures calls to Save.
+type flakyBackend struct {
+	restic.Backend
+	broken   bool
+	failures int
+}
+
+func (be *flakyBackend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	if be.failures > 0 {
+		be.failures--
+		return errors.New("connection reset")
+	}
+	if be.broken {
+		return errors.New("connection refused")
+	}
+	return be.Backend.Save(ctx, h, rd)
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.TODO()
+
+	primary := mem.New()
+	flaky := &flakyBackend{Backend: primary}
+
+	secondary := mem.New()
+	var reported []error
+	be := failover.New(flaky, secondary)
+	be.Report = func(err error) {
+		reported = append(reported, err)
+	}
+
+	save := func(data []byte) restic.Handle {
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data)))
+		return h
+	}
+
+	h1 := save(rtest.Random(1, 100))
+	flaky.broken = true
+	h2 := save(rtest.Random(2, 100))
+	flaky.broken = false
+	h3 := save(rtest.Random(3, 100))
+
+	rtest.Equals(t, 1, len(reported))
+
+	// after the first failure, all files are saved to the secondary backend
+	rtest.Equals(t, []string{h1.Name}, listNames(t, primary, restic.DataFile))
+	rtest.Equals(t, 2, len(listNames(t, secondary, restic.DataFile)))
+
+	want := []string{h1.Name, h2.Name, h3.Name}
+	sort.Strings(want)
+	rtest.Equals(t, want, listNames(t, be, restic.DataFile))
+
+	for _, h := range []restic.Handle{h1, h2, h3} {
+		_, err := backend.LoadAll(ctx, nil, be, h)
+		rtest.OK(t, err)
+
+		found, err := be.Test(ctx, h)
+		rtest.OK(t, err)
+		rtest.Assert(t, found, "file %v not found", h)
+
+		rtest.OK(t, be.Remove(ctx, h))
+	}
+
+	rtest.Equals(t, []string(nil), listNames(t, be, restic.DataFile))
+}
+
+func TestFailoverRetry(t *testing.T) {
+	ctx := context.TODO()
+
+	primary := mem.New()
+	flaky := &flakyBackend{Backend: primary}
+
+	secondary := mem.New()
+	var reported []error
+	retries := 0
+	be := failover.New(flaky, secondary)
+	be.MaxTries = 2
+	be.ReportRetry = func(msg string, err error, d time.Duration) {
+		retries++
+	}
+	be.Report = func(err error) {
+		reported = append(reported, err)
+	}
+
+	save := func(data []byte) restic.Handle {
+		h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+		rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data)))
+		return h
+	}
+
+	// a transient error does not switch to the secondary backend
+	flaky.failures = 2
+	h1 := save(rtest.Random(1, 100))
+	rtest.Equals(t, 2, retries)
+	rtest.Equals(t, 0, len(reported))
+	rtest.Equals(t, []string{h1.Name}, listNames(t, primary, restic.DataFile))
+
+	// the switch happens after all retries failed
+	flaky.broken = true
+	h2 := save(rtest.Random(2, 100))
+	rtest.Equals(t, 4, retries)
+	rtest.Equals(t, 1, len(reported))
+	rtest.Equals(t, []string{h2.Name}, listNames(t, secondary, restic.DataFile))
+}
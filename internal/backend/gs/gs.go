@@ -190,6 +190,15 @@ func (be *Backend) Location() string {
 	return be.Join(be.bucketName, be.prefix)
 }
 
+// Capabilities returns the properties of the backend.
+func (be *Backend) Capabilities() restic.Capabilities {
+	// an object only becomes visible after the upload has been completed
+	return restic.Capabilities{
+		AtomicSave:  true,
+		MaxFileSize: 5 << 40,
+	}
+}
+
 // Path returns the path in the bucket that is used for this backend.
 func (be *Backend) Path() string {
 	return be.prefix
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -22,6 +23,10 @@ type TransportOptions struct {
 
 	// contains the name of a file containing the TLS client certificate and private key in PEM format
 	TLSClientCertKeyFilename string
+
+	// URL of the proxy server to use for all requests, the proxy is taken
+	// from the environment (HTTP_PROXY, HTTPS_PROXY, NO_PROXY) if empty
+	Proxy string
 }
 
 // readPEMCertKey reads a file and returns the PEM encoded certificate and key
@@ -78,6 +83,20 @@ func Transport(opts TransportOptions) (http.RoundTripper, error) {
 		TLSClientConfig:       &tls.Config{},
 	}
 
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, errors.Wrap(err, "parse proxy URL")
+		}
+
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, errors.Errorf("invalid proxy URL %q, only http, https and socks5 proxies are supported", opts.Proxy)
+		}
+		tr.Proxy = http.ProxyURL(u)
+	}
+
 	if opts.TLSClientCertKeyFilename != "" {
 		certs, key, err := readPEMCertKey(opts.TLSClientCertKeyFilename)
 		if err != nil {
@@ -111,3 +130,14 @@ func Transport(opts TransportOptions) (http.RoundTripper, error) {
 	// wrap in the debug round tripper (if active)
 	return debug.RoundTripper(tr), nil
 }
+
+// HTTPAuthError returns a permanent error if the status code of resp indicates
+// that the server rejected the credentials, and nil otherwise.
+func HTTPAuthError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return Permanent(errors.Errorf("access denied by server: %v", resp.Status))
+	}
+
+	return nil
+}
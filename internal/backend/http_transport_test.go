@@ -0,0 +1,37 @@
+package backend_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func TestTransportProxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	rt, err := backend.Transport(backend.TransportOptions{Proxy: proxy.URL})
+	rtest.OK(t, err)
+
+	client := &http.Client{Transport: rt}
+	resp, err := client.Get("http://repo.example.com/config")
+	rtest.OK(t, err)
+	rtest.OK(t, resp.Body.Close())
+
+	rtest.Equals(t, http.StatusNoContent, resp.StatusCode)
+	rtest.Equals(t, "http://repo.example.com/config", requested)
+}
+
+func TestTransportProxyInvalid(t *testing.T) {
+	for _, proxy := range []string{"ftp://proxy:21", "://proxy"} {
+		_, err := backend.Transport(backend.TransportOptions{Proxy: proxy})
+		rtest.Assert(t, err != nil, "expected error for proxy %q not found", proxy)
+	}
+}
@@ -0,0 +1,221 @@
+// Package httpfile implements reading the files of a repository from an HTTP
+// server with plain GET and HEAD requests. It is shared by the backends which
+// store the repository on a web server.
+package httpfile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Client reads the files of a repository stored with the default layout below
+// the URL on an HTTP server.
+type Client struct {
+	url    *url.URL
+	sem    *backend.Semaphore
+	client *http.Client
+	backend.Layout
+}
+
+// New returns a client for the repository at u, which sends at most
+// connections requests at the same time via rt.
+func New(u *url.URL, rt http.RoundTripper, connections uint) (*Client, error) {
+	sem, err := backend.NewSemaphore(connections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		url:    u,
+		sem:    sem,
+		client: &http.Client{Transport: rt},
+		Layout: &backend.DefaultLayout{Path: u.Path, Join: path.Join},
+	}, nil
+}
+
+// URL returns the URL of the repository.
+func (c *Client) URL() *url.URL {
+	return c.url
+}
+
+// FileURL returns the URL for the path p on the server.
+func (c *Client) FileURL(p string) string {
+	u := *c.url
+	u.Path = p
+	return u.String()
+}
+
+// Do sends req to the server while holding a connection token. An error is
+// returned if the server rejects the credentials.
+func (c *Client) Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	c.sem.GetToken()
+	resp, err := ctxhttp.Do(ctx, c.client, req)
+	c.sem.ReleaseToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = backend.HTTPAuthError(resp); err != nil {
+		_ = Drain(resp)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// Drain reads the remaining body of resp and closes it.
+func Drain(resp *http.Response) error {
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	return resp.Body.Close()
+}
+
+// ErrIsNotExist is returned whenever the requested file does not exist on the
+// server.
+type ErrIsNotExist struct {
+	restic.Handle
+}
+
+func (e ErrIsNotExist) Error() string {
+	return fmt.Sprintf("%v does not exist", e.Handle)
+}
+
+// IsNotExist returns true if the error was caused by a non-existing file.
+func (c *Client) IsNotExist(err error) bool {
+	err = errors.Cause(err)
+	_, ok := err.(ErrIsNotExist)
+	return ok
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (c *Client) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	return backend.DefaultLoad(ctx, h, length, offset, c.openReader, fn)
+}
+
+// limitedReadCloser limits the number of bytes read from the underlying
+// ReadCloser.
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func (c *Client) openReader(ctx context.Context, h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	debug.Log("Load %v, length %v, offset %v", h, length, offset)
+	if err := h.Valid(); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		return nil, errors.New("offset is negative")
+	}
+
+	if length < 0 {
+		return nil, errors.Errorf("invalid length %d", length)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.FileURL(c.Filename(h)), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewRequest")
+	}
+
+	byteRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		byteRange = fmt.Sprintf("bytes=%d-%d", offset, offset+int64(length)-1)
+	}
+	req.Header.Set("Range", byteRange)
+
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "GET")
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		_ = Drain(resp)
+		return nil, ErrIsNotExist{h}
+	case http.StatusOK:
+		// the server ignored the range header, skip to the requested part
+		// of the file manually
+	default:
+		_ = Drain(resp)
+		return nil, errors.Errorf("unexpected HTTP response (%v): %v", resp.StatusCode, resp.Status)
+	}
+
+	if offset > 0 {
+		_, err = io.CopyN(ioutil.Discard, resp.Body, offset)
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, errors.Wrap(err, "Seek")
+		}
+	}
+
+	if length > 0 {
+		return limitedReadCloser{io.LimitReader(resp.Body, int64(length)), resp.Body}, nil
+	}
+
+	return resp.Body, nil
+}
+
+// Stat returns information about a blob.
+func (c *Client) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	debug.Log("Stat %v", h)
+	if err := h.Valid(); err != nil {
+		return restic.FileInfo{}, err
+	}
+
+	req, err := http.NewRequest(http.MethodHead, c.FileURL(c.Filename(h)), nil)
+	if err != nil {
+		return restic.FileInfo{}, errors.Wrap(err, "NewRequest")
+	}
+
+	resp, err := c.Do(ctx, req)
+	if err != nil {
+		return restic.FileInfo{}, errors.Wrap(err, "HEAD")
+	}
+
+	if err = Drain(resp); err != nil {
+		return restic.FileInfo{}, errors.Wrap(err, "Close")
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return restic.FileInfo{}, ErrIsNotExist{h}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return restic.FileInfo{}, errors.Errorf("unexpected HTTP response (%v): %v", resp.StatusCode, resp.Status)
+	}
+
+	if resp.ContentLength < 0 {
+		return restic.FileInfo{}, errors.New("negative content length")
+	}
+
+	return restic.FileInfo{Size: resp.ContentLength, Name: h.Name}, nil
+}
+
+// Test returns true if a blob of the given type and name exists in the backend.
+func (c *Client) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	_, err := c.Stat(ctx, h)
+	if c.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
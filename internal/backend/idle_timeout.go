@@ -0,0 +1,100 @@
+package backend
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/restic/restic/internal/restic"
+)
+
+// idleContext is a context which is cancelled when its parent is done, or when
+// no progress was reported for a duration. In the latter case, Err returns
+// context.DeadlineExceeded.
+type idleContext struct {
+	context.Context
+	cancel context.CancelFunc
+
+	timeout  time.Duration
+	timer    *time.Timer
+	timedOut int32
+}
+
+// newIdleContext returns a context which is cancelled when ctx is done or when
+// no progress was reported for timeout. A timeout of zero or less disables
+// the timeout. The timer starts immediately, stop must be called to release
+// the resources.
+func newIdleContext(ctx context.Context, timeout time.Duration) *idleContext {
+	ictx, cancel := context.WithCancel(ctx)
+	c := &idleContext{
+		Context: ictx,
+		cancel:  cancel,
+		timeout: timeout,
+	}
+
+	if timeout > 0 {
+		c.timer = time.AfterFunc(timeout, func() {
+			if ctx.Err() == nil {
+				atomic.StoreInt32(&c.timedOut, 1)
+			}
+			cancel()
+		})
+	}
+
+	return c
+}
+
+// Err returns context.DeadlineExceeded if the timeout expired, and the error
+// of the parent context otherwise.
+func (c *idleContext) Err() error {
+	if atomic.LoadInt32(&c.timedOut) == 1 {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
+
+// progress restarts the timer.
+func (c *idleContext) progress() {
+	if c.timer != nil {
+		c.timer.Reset(c.timeout)
+	}
+}
+
+// pause stops the timer until progress is called again.
+func (c *idleContext) pause() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// stop stops the timer and cancels the context.
+func (c *idleContext) stop() {
+	c.pause()
+	c.cancel()
+}
+
+// idleRewindReader reports progress to ctx when the backend reads data.
+type idleRewindReader struct {
+	restic.RewindReader
+	ctx *idleContext
+}
+
+func (rd idleRewindReader) Read(p []byte) (int, error) {
+	rd.ctx.progress()
+	return rd.RewindReader.Read(p)
+}
+
+// idleReader runs the timer of ctx only while waiting for data from the
+// backend, so the time the consumer of the data takes is not included.
+type idleReader struct {
+	io.Reader
+	ctx *idleContext
+}
+
+func (rd idleReader) Read(p []byte) (int, error) {
+	rd.ctx.progress()
+	n, err := rd.Reader.Read(p)
+	rd.ctx.pause()
+	return n, err
+}
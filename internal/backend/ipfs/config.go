@@ -0,0 +1,46 @@
+package ipfs
+
+import (
+	"path"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Config contains all configuration necessary to store a repository in the
+// mutable file system (MFS) of an IPFS node.
+type Config struct {
+	Path        string
+	API         string `option:"api" help:"URL of the HTTP API of the IPFS node (default: http://127.0.0.1:5001)"`
+	Connections uint   `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+}
+
+func init() {
+	options.Register("ipfs", Config{})
+}
+
+// NewConfig returns a new Config with the default values filled in.
+func NewConfig() Config {
+	return Config{
+		API:         "http://127.0.0.1:5001",
+		Connections: 5,
+	}
+}
+
+// ParseConfig parses the string s and extracts the path of the repository in
+// the MFS. The supported format is ipfs:/path.
+func ParseConfig(s string) (interface{}, error) {
+	if !strings.HasPrefix(s, "ipfs:") {
+		return nil, errors.New("invalid IPFS backend specification")
+	}
+
+	p := path.Clean("/" + s[5:])
+	if p == "/" {
+		return nil, errors.New("invalid IPFS backend specification: the repository cannot be stored in the MFS root")
+	}
+
+	cfg := NewConfig()
+	cfg.Path = p
+	return cfg, nil
+}
@@ -0,0 +1,57 @@
+package ipfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+var configTests = []struct {
+	s   string
+	cfg Config
+}{
+	{
+		s: "ipfs:/restic",
+		cfg: Config{
+			Path:        "/restic",
+			API:         "http://127.0.0.1:5001",
+			Connections: 5,
+		},
+	},
+	{
+		s: "ipfs:backups//repo/",
+		cfg: Config{
+			Path:        "/backups/repo",
+			API:         "http://127.0.0.1:5001",
+			Connections: 5,
+		},
+	},
+}
+
+func TestParseConfig(t *testing.T) {
+	for _, test := range configTests {
+		t.Run("", func(t *testing.T) {
+			cfg, err := ParseConfig(test.s)
+			if err != nil {
+				t.Fatalf("%s failed: %v", test.s, err)
+			}
+
+			if !reflect.DeepEqual(cfg, test.cfg) {
+				t.Fatalf("\ninput: %s\n wrong config, want:\n  %v\ngot:\n  %v",
+					test.s, test.cfg, cfg)
+			}
+		})
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	for _, s := range []string{
+		"ipfs:",
+		"ipfs:/",
+		"ipns:/restic",
+	} {
+		_, err := ParseConfig(s)
+		if err == nil {
+			t.Errorf("expected error for %q not found", s)
+		}
+	}
+}
@@ -0,0 +1,450 @@
+// Package ipfs implements an experimental backend which stores the repository
+// in the mutable file system (MFS) of an IPFS node. The files are added to
+// IPFS as content-addressed objects, the MFS directory of the repository maps
+// the file type and name to the content identifier (CID) of each file.
+//
+// The MFS directory is used as the index of the repository instead of a
+// separate index object: the node updates single directory entries
+// atomically, while a separate object would have to be rewritten for every
+// file, so concurrent restic processes (e.g. creating lock files) would lose
+// each other's updates.
+package ipfs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context/ctxhttp"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// make sure the IPFS backend implements restic.Backend
+var _ restic.Backend = &Backend{}
+
+// Backend stores data in the MFS of an IPFS node, which is accessed via its
+// HTTP API.
+type Backend struct {
+	api    string
+	root   string
+	sem    *backend.Semaphore
+	client *http.Client
+	backend.Layout
+}
+
+// Open opens the IPFS backend with the given config.
+func Open(cfg Config, rt http.RoundTripper) (*Backend, error) {
+	debug.Log("open, config %#v", cfg)
+
+	sem, err := backend.NewSemaphore(cfg.Connections)
+	if err != nil {
+		return nil, err
+	}
+
+	be := &Backend{
+		api:    strings.TrimSuffix(cfg.API, "/") + "/api/v0/",
+		root:   cfg.Path,
+		client: &http.Client{Transport: rt},
+		Layout: &backend.DefaultLayout{Path: cfg.Path, Join: path.Join},
+		sem:    sem,
+	}
+
+	return be, nil
+}
+
+// Create creates all the necessary directories for a new repository in the
+// MFS.
+func Create(cfg Config, rt http.RoundTripper) (*Backend, error) {
+	be, err := Open(cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = be.Stat(context.TODO(), restic.Handle{Type: restic.ConfigFile})
+	if err == nil {
+		return nil, errors.Fatal("config file already exists")
+	}
+
+	if !be.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, d := range be.Paths() {
+		err = be.call(context.TODO(), "files/mkdir", url.Values{"arg": {d}, "parents": {"true"}}, nil, "", nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return be, nil
+}
+
+// ErrIsNotExist is returned whenever the requested file does not exist.
+type ErrIsNotExist struct {
+	restic.Handle
+}
+
+func (e ErrIsNotExist) Error() string {
+	return fmt.Sprintf("%v does not exist", e.Handle)
+}
+
+// IsNotExist returns true if the error was caused by a non-existing file.
+func (be *Backend) IsNotExist(err error) bool {
+	err = errors.Cause(err)
+	_, ok := err.(ErrIsNotExist)
+	return ok
+}
+
+// Location returns this backend's location (the path in the MFS).
+func (be *Backend) Location() string {
+	return "ipfs:" + be.root
+}
+
+// Capabilities returns the properties of the backend.
+func (be *Backend) Capabilities() restic.Capabilities {
+	// files/write creates the file before all data has been received
+	return restic.Capabilities{}
+}
+
+// apiError is returned by the node when a command failed.
+type apiError struct {
+	Message string
+	Code    int
+}
+
+func (e apiError) Error() string {
+	return "IPFS API error: " + e.Message
+}
+
+// isNotFound returns true if err was returned by the node because a file or
+// directory does not exist.
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(apiError)
+	return ok && strings.Contains(e.Message, "does not exist")
+}
+
+// request sends the API command cmd to the node while holding a connection
+// token. An apiError is returned if the command failed, the caller must close
+// the body of the response otherwise.
+func (be *Backend) request(ctx context.Context, cmd string, args url.Values, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, be.api+cmd+"?"+args.Encode(), body)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewRequest")
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	be.sem.GetToken()
+	resp, err := ctxhttp.Do(ctx, be.client, req)
+	be.sem.ReleaseToken()
+	if err != nil {
+		return nil, errors.Wrap(err, cmd)
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if err = backend.HTTPAuthError(resp); err != nil {
+		return nil, err
+	}
+
+	var e apiError
+	if json.NewDecoder(resp.Body).Decode(&e) != nil || e.Message == "" {
+		return nil, errors.Errorf("%v failed, server response: %v (%v)", cmd, resp.Status, resp.StatusCode)
+	}
+
+	return nil, e
+}
+
+// call runs the API command cmd and decodes the JSON response into result,
+// unless result is nil.
+func (be *Backend) call(ctx context.Context, cmd string, args url.Values, body io.Reader, contentType string, result interface{}) error {
+	resp, err := be.request(ctx, cmd, args, body, contentType)
+	if err != nil {
+		return err
+	}
+
+	if result != nil {
+		err = json.NewDecoder(resp.Body).Decode(result)
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	cerr := resp.Body.Close()
+	if err != nil {
+		return errors.Wrap(err, "Decode")
+	}
+
+	return errors.Wrap(cerr, "Close")
+}
+
+// Save stores data in the backend at the handle.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	debug.Log("Save %v", h)
+	if err := h.Valid(); err != nil {
+		return err
+	}
+
+	// files/write has no option to fail for existing files, so check first
+	// to never overwrite a file silently. All errors are permanent, otherwise
+	// the caller may remove the file, which might exist after all.
+	_, err := be.Stat(ctx, h)
+	if err == nil {
+		return backend.Permanent(errors.Errorf("%v already exists", h))
+	}
+
+	if !be.IsNotExist(err) {
+		return backend.Permanent(err)
+	}
+
+	// stream the data as a multipart form to the node
+	pr, pw := io.Pipe()
+	defer func() {
+		_ = pr.Close()
+	}()
+
+	filename := be.Filename(h)
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", path.Base(filename))
+		if err == nil {
+			_, err = io.Copy(part, rd)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	args := url.Values{
+		"arg":      {filename},
+		"create":   {"true"},
+		"parents":  {"true"},
+		"truncate": {"true"},
+	}
+
+	return be.call(ctx, "files/write", args, pr, mw.FormDataContentType(), nil)
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset.
+func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	return backend.DefaultLoad(ctx, h, length, offset, be.openReader, fn)
+}
+
+func (be *Backend) openReader(ctx context.Context, h restic.Handle, length int, offset int64) (io.ReadCloser, error) {
+	debug.Log("Load %v, length %v, offset %v", h, length, offset)
+	if err := h.Valid(); err != nil {
+		return nil, err
+	}
+
+	if offset < 0 {
+		return nil, errors.New("offset is negative")
+	}
+
+	if length < 0 {
+		return nil, errors.Errorf("invalid length %d", length)
+	}
+
+	args := url.Values{
+		"arg":    {be.Filename(h)},
+		"offset": {strconv.FormatInt(offset, 10)},
+	}
+	if length > 0 {
+		args.Set("count", strconv.Itoa(length))
+	}
+
+	resp, err := be.request(ctx, "files/read", args, nil, "")
+	if isNotFound(err) {
+		return nil, ErrIsNotExist{h}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// fileStat is the response to the files/stat command.
+type fileStat struct {
+	Hash string
+	Size int64
+	Type string
+}
+
+// Stat returns information about a blob.
+func (be *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	debug.Log("Stat %v", h)
+	if err := h.Valid(); err != nil {
+		return restic.FileInfo{}, err
+	}
+
+	var fs fileStat
+	err := be.call(ctx, "files/stat", url.Values{"arg": {be.Filename(h)}}, nil, "", &fs)
+	if isNotFound(err) {
+		return restic.FileInfo{}, ErrIsNotExist{h}
+	}
+
+	if err != nil {
+		return restic.FileInfo{}, err
+	}
+
+	if fs.Type != "file" {
+		return restic.FileInfo{}, errors.Errorf("%v is not a file", be.Filename(h))
+	}
+
+	return restic.FileInfo{Size: fs.Size, Name: h.Name}, nil
+}
+
+// Test returns true if a blob of the given type and name exists in the backend.
+func (be *Backend) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	_, err := be.Stat(ctx, h)
+	if be.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Remove removes the blob with the given name and type.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	debug.Log("Remove %v", h)
+	if err := h.Valid(); err != nil {
+		return err
+	}
+
+	err := be.call(ctx, "files/rm", url.Values{"arg": {be.Filename(h)}}, nil, "", nil)
+	if isNotFound(err) {
+		return ErrIsNotExist{h}
+	}
+
+	return err
+}
+
+// dirEntry is an entry in the response to the files/ls command.
+type dirEntry struct {
+	Name string
+	Type int
+	Size int64
+}
+
+// entryTypeDir is the type of directories in the response to files/ls.
+const entryTypeDir = 1
+
+// readDir returns the contents of dir. A directory that does not exist is
+// treated as empty.
+func (be *Backend) readDir(ctx context.Context, dir string) ([]dirEntry, error) {
+	var result struct {
+		Entries []dirEntry
+	}
+
+	err := be.call(ctx, "files/ls", url.Values{"arg": {dir}, "long": {"true"}}, nil, "", &result)
+	if isNotFound(err) {
+		debug.Log("ignoring non-existing directory %v", dir)
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Entries, nil
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	debug.Log("List %v", t)
+
+	basedir, subdirs := be.Basedir(t)
+	if !subdirs {
+		return be.listDir(ctx, basedir, fn)
+	}
+
+	entries, err := be.readDir(ctx, basedir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Type != entryTypeDir {
+			continue
+		}
+
+		err = be.listDir(ctx, path.Join(basedir, e.Name), fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// listDir runs fn for each file in dir.
+func (be *Backend) listDir(ctx context.Context, dir string, fn func(restic.FileInfo) error) error {
+	entries, err := be.readDir(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Type == entryTypeDir {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err = fn(restic.FileInfo{Name: e.Name, Size: e.Size})
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Close does nothing, all connections are managed by the HTTP client.
+func (be *Backend) Close() error {
+	return nil
+}
+
+// Delete removes the repository directory from the MFS. The data is removed
+// from the node by the next garbage collection.
+func (be *Backend) Delete(ctx context.Context) error {
+	err := be.call(ctx, "files/rm", url.Values{"arg": {be.root}, "recursive": {"true"}}, nil, "", nil)
+	if isNotFound(err) {
+		return nil
+	}
+
+	return err
+}
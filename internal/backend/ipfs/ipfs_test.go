@@ -0,0 +1,330 @@
+package ipfs_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/ipfs"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/restic"
+)
+
+// fakeNode implements the subset of the MFS commands of the IPFS HTTP API
+// used by the backend.
+type fakeNode struct {
+	m     sync.Mutex
+	files map[string][]byte
+	dirs  map[string]struct{}
+
+	// failStat makes files/stat fail with a server error
+	failStat bool
+}
+
+func newFakeNode() *fakeNode {
+	return &fakeNode{
+		files: make(map[string][]byte),
+		dirs:  map[string]struct{}{"/": {}},
+	}
+}
+
+func (n *fakeNode) mkdirAll(dir string) {
+	for ; dir != "/"; dir = path.Dir(dir) {
+		n.dirs[dir] = struct{}{}
+	}
+}
+
+func writeError(w http.ResponseWriter, msg string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"Message": msg, "Code": 0, "Type": "error"})
+}
+
+func (n *fakeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n.m.Lock()
+	defer n.m.Unlock()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	p := path.Clean(q.Get("arg"))
+	_, isDir := n.dirs[p]
+	data, isFile := n.files[p]
+
+	switch strings.TrimPrefix(r.URL.Path, "/api/v0/") {
+	case "files/mkdir":
+		n.mkdirAll(p)
+
+	case "files/write":
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		buf, err := ioutil.ReadAll(f)
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		n.mkdirAll(path.Dir(p))
+		n.files[p] = buf
+
+	case "files/read":
+		if !isFile {
+			writeError(w, "file does not exist")
+			return
+		}
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		if offset > len(data) {
+			offset = len(data)
+		}
+		data = data[offset:]
+		if count, err := strconv.Atoi(q.Get("count")); err == nil && count < len(data) {
+			data = data[:count]
+		}
+		_, _ = w.Write(data)
+
+	case "files/stat":
+		switch {
+		case n.failStat:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case isFile:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Hash": "Qm", "Size": len(data), "Type": "file"})
+		case isDir:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Hash": "Qm", "Size": 0, "Type": "directory"})
+		default:
+			writeError(w, "file does not exist")
+		}
+
+	case "files/ls":
+		if !isDir {
+			writeError(w, "file does not exist")
+			return
+		}
+		type entry struct {
+			Name string
+			Type int
+			Size int
+		}
+		var entries []entry
+		for name := range n.dirs {
+			if name != "/" && path.Dir(name) == p {
+				entries = append(entries, entry{Name: path.Base(name), Type: 1})
+			}
+		}
+		for name, buf := range n.files {
+			if path.Dir(name) == p {
+				entries = append(entries, entry{Name: path.Base(name), Size: len(buf)})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Entries": entries})
+
+	case "files/rm":
+		switch {
+		case isFile:
+			delete(n.files, p)
+		case isDir && q.Get("recursive") == "true":
+			for name := range n.files {
+				if strings.HasPrefix(name, p+"/") {
+					delete(n.files, name)
+				}
+			}
+			for name := range n.dirs {
+				if name == p || strings.HasPrefix(name, p+"/") {
+					delete(n.dirs, name)
+				}
+			}
+		case isDir:
+			writeError(w, p+" is a directory, use -r to remove directories")
+		default:
+			writeError(w, "file does not exist")
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestSuite(t testing.TB, api string, minimalData bool) *test.Suite {
+	tr, err := backend.Transport(backend.TransportOptions{})
+	if err != nil {
+		t.Fatalf("cannot create transport for tests: %v", err)
+	}
+
+	return &test.Suite{
+		MinimalData: minimalData,
+
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			cfg := ipfs.NewConfig()
+			cfg.API = api
+			id := restic.NewRandomID()
+			cfg.Path = "/restic-test-" + id.Str()
+			return cfg, nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(config interface{}) (restic.Backend, error) {
+			cfg := config.(ipfs.Config)
+			return ipfs.Create(cfg, tr)
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(config interface{}) (restic.Backend, error) {
+			cfg := config.(ipfs.Config)
+			return ipfs.Open(cfg, tr)
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(config interface{}) error {
+			cfg := config.(ipfs.Config)
+			be, err := ipfs.Open(cfg, tr)
+			if err != nil {
+				return err
+			}
+			return be.Delete(context.TODO())
+		},
+	}
+}
+
+func TestBackendIPFS(t *testing.T) {
+	srv := httptest.NewServer(newFakeNode())
+	defer srv.Close()
+
+	newTestSuite(t, srv.URL, false).RunTests(t)
+}
+
+func TestSaveExistingFile(t *testing.T) {
+	srv := httptest.NewServer(newFakeNode())
+	defer srv.Close()
+
+	tr, err := backend.Transport(backend.TransportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ipfs.NewConfig()
+	cfg.API = srv.URL
+	cfg.Path = "/restic-test"
+	be, err := ipfs.Create(cfg, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := restic.Handle{Type: restic.DataFile, Name: restic.NewRandomID().String()}
+	err = be.Save(context.TODO(), h, restic.NewByteReader([]byte("foo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = be.Save(context.TODO(), h, restic.NewByteReader([]byte("bar")))
+	if err == nil {
+		t.Fatal("overwriting an existing file did not return an error")
+	}
+
+	if !backend.IsPermanent(err) {
+		t.Fatalf("error is not permanent: %v", err)
+	}
+
+	var buf []byte
+	err = be.Load(context.TODO(), h, 0, 0, func(rd io.Reader) (ierr error) {
+		buf, ierr = ioutil.ReadAll(rd)
+		return ierr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "foo" {
+		t.Fatalf("file was overwritten, content is %q", buf)
+	}
+}
+
+func TestSaveStatError(t *testing.T) {
+	node := newFakeNode()
+	srv := httptest.NewServer(node)
+	defer srv.Close()
+
+	tr, err := backend.Transport(backend.TransportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ipfs.NewConfig()
+	cfg.API = srv.URL
+	cfg.Path = "/restic-test"
+	be, err := ipfs.Create(cfg, tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := restic.Handle{Type: restic.DataFile, Name: restic.NewRandomID().String()}
+	err = be.Save(context.TODO(), h, restic.NewByteReader([]byte("foo")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the retry backend removes the file after a failed upload unless the
+	// error is permanent
+	retryBe := backend.NewRetryBackend(be, 2, nil)
+
+	node.m.Lock()
+	node.failStat = true
+	node.m.Unlock()
+
+	err = retryBe.Save(context.TODO(), h, restic.NewByteReader([]byte("bar")))
+	if err == nil {
+		t.Fatal("Save did not return an error")
+	}
+
+	if !backend.IsPermanent(err) {
+		t.Fatalf("error is not permanent: %v", err)
+	}
+
+	node.m.Lock()
+	node.failStat = false
+	node.m.Unlock()
+
+	var buf []byte
+	err = be.Load(context.TODO(), h, 0, 0, func(rd io.Reader) (ierr error) {
+		buf, ierr = ioutil.ReadAll(rd)
+		return ierr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(buf) != "foo" {
+		t.Fatalf("file was modified, content is %q", buf)
+	}
+}
+
+func TestBackendIPFSExternalNode(t *testing.T) {
+	api := os.Getenv("RESTIC_TEST_IPFS_API")
+	if api == "" {
+		t.Skipf("environment variable %v not set", "RESTIC_TEST_IPFS_API")
+	}
+
+	newTestSuite(t, api, true).RunTests(t)
+}
+
+func BenchmarkBackendIPFS(t *testing.B) {
+	srv := httptest.NewServer(newFakeNode())
+	defer srv.Close()
+
+	newTestSuite(t, srv.URL, false).RunBenchmarks(t)
+}
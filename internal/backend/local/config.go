@@ -9,8 +9,11 @@ import (
 
 // Config holds all information needed to open a local repository.
 type Config struct {
-	Path   string
-	Layout string `option:"layout" help:"use this backend directory layout (default: auto-detect)"`
+	Path                string
+	Layout              string `option:"layout" help:"use this backend directory layout (default: auto-detect)"`
+	SyncDir             bool   `option:"sync-dir" help:"also sync the directory after saving a file, e.g. for removable drives"`
+	StoreFilesystemUUID bool   `option:"store-filesystem-uuid" help:"store the UUID of the filesystem in the repository config on init, so that the repository is only used on this filesystem (Linux only)"`
+	SkipFilesystemCheck bool   `option:"skip-filesystem-check" help:"do not check the filesystem UUID stored in the repository config, e.g. after copying the repository to a different drive"`
 }
 
 func init() {
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/restic"
@@ -25,9 +26,39 @@ var _ restic.Backend = &Local{}
 
 const defaultLayout = "default"
 
+// FilesystemUUID returns the UUID of the filesystem the repository at
+// cfg.Path is stored on. This is only supported on Linux.
+func FilesystemUUID(cfg Config) (string, error) {
+	return filesystemUUID(cfg.Path)
+}
+
+// CheckFilesystem returns an error if the repository at cfg.Path is not
+// stored on the filesystem with the given UUID, e.g. because a different
+// removable drive is mounted at the path. The check is skipped if
+// cfg.SkipFilesystemCheck is set.
+func CheckFilesystem(cfg Config, uuid string) error {
+	if uuid == "" || cfg.SkipFilesystemCheck {
+		return nil
+	}
+
+	current, err := filesystemUUID(cfg.Path)
+	if err != nil {
+		return errors.Fatalf("unable to check the filesystem UUID %v stored in the repository config: %v\n"+
+			"Use -o local.skip-filesystem-check=true to open the repository anyway.", uuid, err)
+	}
+
+	if !strings.EqualFold(current, uuid) {
+		return errors.Fatalf("%v is stored on the filesystem with UUID %v, but the repository was created on %v, is the correct drive mounted?",
+			cfg.Path, current, uuid)
+	}
+
+	return nil
+}
+
 // Open opens the local backend as specified by config.
 func Open(cfg Config) (*Local, error) {
 	debug.Log("open local backend at %v (layout %q)", cfg.Path, cfg.Layout)
+
 	l, err := backend.ParseLayout(&backend.LocalFilesystem{}, cfg.Layout, defaultLayout, cfg.Path)
 	if err != nil {
 		return nil, err
@@ -73,6 +104,12 @@ func (b *Local) Location() string {
 	return b.Path
 }
 
+// Capabilities returns the properties of the backend.
+func (b *Local) Capabilities() restic.Capabilities {
+	// files are written in place, so a partial file may be visible
+	return restic.Capabilities{}
+}
+
 // IsNotExist returns true if the error is caused by a non existing file.
 func (b *Local) IsNotExist(err error) bool {
 	return os.IsNotExist(errors.Cause(err))
@@ -107,23 +144,40 @@ func (b *Local) Save(ctx context.Context, h restic.Handle, rd restic.RewindReade
 		return errors.Wrap(err, "OpenFile")
 	}
 
+	// the file was created by this call, so remove it again when writing
+	// fails instead of leaving a partial file behind
+	removeFile := func(f *os.File) {
+		_ = f.Close()
+		if rerr := fs.Remove(filename); rerr != nil {
+			debug.Log("error removing partial file %v: %v", filename, rerr)
+		}
+	}
+
 	// save data, then sync
 	_, err = io.Copy(f, rd)
 	if err != nil {
-		_ = f.Close()
+		removeFile(f)
 		return errors.Wrap(err, "Write")
 	}
 
 	if err = f.Sync(); err != nil {
-		_ = f.Close()
+		removeFile(f)
 		return errors.Wrap(err, "Sync")
 	}
 
 	err = f.Close()
 	if err != nil {
+		removeFile(f)
 		return errors.Wrap(err, "Close")
 	}
 
+	// make sure the new directory entry is also written to disk
+	if b.SyncDir {
+		if err = syncDir(filepath.Dir(filename)); err != nil {
+			return errors.Wrap(err, "SyncDir")
+		}
+	}
+
 	return setNewFileMode(filename, backend.Modes.File)
 }
 
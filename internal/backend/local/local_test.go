@@ -12,7 +12,7 @@ import (
 	rtest "github.com/restic/restic/internal/test"
 )
 
-func newTestSuite(t testing.TB) *test.Suite {
+func newTestSuite(t testing.TB, syncDir bool) *test.Suite {
 	return &test.Suite{
 		// NewConfig returns a config for a new temporary backend that will be used in tests.
 		NewConfig: func() (interface{}, error) {
@@ -24,7 +24,8 @@ func newTestSuite(t testing.TB) *test.Suite {
 			t.Logf("create new backend at %v", dir)
 
 			cfg := local.Config{
-				Path: dir,
+				Path:    dir,
+				SyncDir: syncDir,
 			}
 			return cfg, nil
 		},
@@ -55,11 +56,15 @@ func newTestSuite(t testing.TB) *test.Suite {
 }
 
 func TestBackend(t *testing.T) {
-	newTestSuite(t).RunTests(t)
+	newTestSuite(t, false).RunTests(t)
+}
+
+func TestBackendSyncDir(t *testing.T) {
+	newTestSuite(t, true).RunTests(t)
 }
 
 func BenchmarkBackend(t *testing.B) {
-	newTestSuite(t).RunBenchmarks(t)
+	newTestSuite(t, false).RunBenchmarks(t)
 }
 
 func readdirnames(t testing.TB, dir string) []string {
@@ -134,3 +139,26 @@ func TestOpenNotExistingDirectory(t *testing.T) {
 	removeAll(t, filepath.Join(dir, "data"))
 	empty(t, dir)
 }
+
+func TestWrongFilesystemUUID(t *testing.T) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	cfg := local.Config{Path: dir}
+	err := local.CheckFilesystem(cfg, "00000000-0000-0000-0000-000000000000")
+	if err == nil {
+		t.Fatal("CheckFilesystem did not return an error for the wrong filesystem")
+	}
+
+	cfg.SkipFilesystemCheck = true
+	err = local.CheckFilesystem(cfg, "00000000-0000-0000-0000-000000000000")
+	if err != nil {
+		t.Fatalf("CheckFilesystem returned an error although the check is skipped: %v", err)
+	}
+
+	// the repository config of most repositories does not contain a UUID
+	err = local.CheckFilesystem(local.Config{Path: dir}, "")
+	if err != nil {
+		t.Fatalf("CheckFilesystem returned an error without a UUID: %v", err)
+	}
+}
@@ -12,3 +12,19 @@ import (
 func setNewFileMode(f string, mode os.FileMode) error {
 	return fs.Chmod(f, mode)
 }
+
+// syncDir flushes the directory entries of dir to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	err = d.Sync()
+	if err != nil {
+		_ = d.Close()
+		return err
+	}
+
+	return d.Close()
+}
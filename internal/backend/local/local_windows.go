@@ -10,3 +10,8 @@ import (
 func setNewFileMode(f string, mode os.FileMode) error {
 	return nil
 }
+
+// syncDir does nothing, directories cannot be synced on Windows.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package local
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"syscall"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+const uuidDir = "/dev/disk/by-uuid"
+
+// filesystemUUID returns the UUID of the filesystem dir is stored on. The
+// UUID is found by looking for the block device with the same device number
+// in /dev/disk/by-uuid.
+func filesystemUUID(dir string) (string, error) {
+	var st syscall.Stat_t
+	err := syscall.Stat(dir, &st)
+	if err != nil {
+		return "", errors.Wrap(err, "Stat")
+	}
+
+	entries, err := ioutil.ReadDir(uuidDir)
+	if err != nil {
+		return "", errors.Wrap(err, "ReadDir")
+	}
+
+	for _, entry := range entries {
+		var dev syscall.Stat_t
+		err = syscall.Stat(filepath.Join(uuidDir, entry.Name()), &dev)
+		if err != nil {
+			continue
+		}
+
+		if uint64(dev.Rdev) == uint64(st.Dev) {
+			return entry.Name(), nil
+		}
+	}
+
+	return "", errors.Errorf("no filesystem UUID found for %v", dir)
+}
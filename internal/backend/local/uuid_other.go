@@ -0,0 +1,10 @@
+// +build !linux
+
+package local
+
+import "github.com/restic/restic/internal/errors"
+
+// filesystemUUID is only implemented on Linux.
+func filesystemUUID(dir string) (string, error) {
+	return "", errors.New("detecting the filesystem UUID is only supported on Linux")
+}
@@ -7,12 +7,15 @@ import (
 	"github.com/restic/restic/internal/backend/azure"
 	"github.com/restic/restic/internal/backend/b2"
 	"github.com/restic/restic/internal/backend/gs"
+	"github.com/restic/restic/internal/backend/ipfs"
 	"github.com/restic/restic/internal/backend/local"
 	"github.com/restic/restic/internal/backend/rclone"
 	"github.com/restic/restic/internal/backend/rest"
 	"github.com/restic/restic/internal/backend/s3"
 	"github.com/restic/restic/internal/backend/sftp"
+	"github.com/restic/restic/internal/backend/static"
 	"github.com/restic/restic/internal/backend/swift"
+	"github.com/restic/restic/internal/backend/webdav"
 	"github.com/restic/restic/internal/errors"
 )
 
@@ -40,6 +43,9 @@ var parsers = []parser{
 	{"swift", swift.ParseConfig},
 	{"rest", rest.ParseConfig},
 	{"rclone", rclone.ParseConfig},
+	{"webdav", webdav.ParseConfig},
+	{"static", static.ParseConfig},
+	{"ipfs", ipfs.ParseConfig},
 }
 
 func isPath(s string) bool {
@@ -208,6 +208,14 @@ func (be *MemoryBackend) Location() string {
 	return "RAM"
 }
 
+// Capabilities returns the properties of the backend.
+func (be *MemoryBackend) Capabilities() restic.Capabilities {
+	// the data is only stored after it has been read completely
+	return restic.Capabilities{
+		AtomicSave: true,
+	}
+}
+
 // Delete removes all data in the backend.
 func (be *MemoryBackend) Delete(ctx context.Context) error {
 	be.m.Lock()
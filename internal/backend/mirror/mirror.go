@@ -0,0 +1,186 @@
+// Package mirror implements a backend which replicates all data to several
+// underlying backends.
+package mirror
+
+import (
+	"context"
+	"io"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Backend writes all files to every underlying backend and reads them from
+// the first backend which is able to deliver them.
+type Backend struct {
+	backends []restic.Backend
+}
+
+// statically ensure that Backend implements restic.Backend.
+var _ restic.Backend = &Backend{}
+
+// New returns a backend which mirrors all modifications to each of the
+// backends. The first backend is the primary one, it is queried first for all
+// read operations.
+func New(backends ...restic.Backend) *Backend {
+	return &Backend{backends: backends}
+}
+
+// Location returns the location of the primary backend.
+func (be *Backend) Location() string {
+	return be.backends[0].Location()
+}
+
+// Capabilities returns the properties offered by all backends.
+func (be *Backend) Capabilities() restic.Capabilities {
+	caps := be.backends[0].Capabilities()
+	for _, b := range be.backends[1:] {
+		caps = caps.Intersect(b.Capabilities())
+	}
+	return caps
+}
+
+// Save stores the data from rd in all backends. An error is returned if
+// saving the file fails for any of the backends.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	for _, b := range be.backends {
+		if err := rd.Rewind(); err != nil {
+			return err
+		}
+
+		err := b.Save(ctx, h, rd)
+		if err != nil {
+			return errors.Wrapf(err, "Save(%v) to %v", h, b.Location())
+		}
+	}
+
+	return nil
+}
+
+// Remove removes the file from all backends. Files which do not exist in some
+// of the backends are ignored, unless the file does not exist in any backend.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	var notExistErr error
+	removed := false
+	for _, b := range be.backends {
+		err := b.Remove(ctx, h)
+		if err != nil && b.IsNotExist(err) {
+			debug.Log("Remove(%v): file does not exist in %v", h, b.Location())
+			notExistErr = err
+			continue
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "Remove(%v) from %v", h, b.Location())
+		}
+		removed = true
+	}
+
+	if !removed {
+		return notExistErr
+	}
+
+	return nil
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset. Backends are tried in order until one of them succeeds.
+func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) (err error) {
+	for _, b := range be.backends {
+		err = b.Load(ctx, h, length, offset, fn)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		debug.Log("Load(%v) from %v failed: %v", h, b.Location(), err)
+	}
+
+	return err
+}
+
+// Stat returns information about the file identified by h. Backends are tried
+// in order until one of them returns an answer.
+func (be *Backend) Stat(ctx context.Context, h restic.Handle) (fi restic.FileInfo, err error) {
+	for _, b := range be.backends {
+		fi, err = b.Stat(ctx, h)
+		if err == nil || b.IsNotExist(err) || ctx.Err() != nil {
+			return fi, err
+		}
+
+		debug.Log("Stat(%v) on %v failed: %v", h, b.Location(), err)
+	}
+
+	return fi, err
+}
+
+// Test returns whether a file exists. Backends are tried in order until one
+// of them returns an answer.
+func (be *Backend) Test(ctx context.Context, h restic.Handle) (found bool, err error) {
+	for _, b := range be.backends {
+		found, err = b.Test(ctx, h)
+		if err == nil || ctx.Err() != nil {
+			return found, err
+		}
+
+		debug.Log("Test(%v) on %v failed: %v", h, b.Location(), err)
+	}
+
+	return found, err
+}
+
+// List runs fn for each file in the first backend which can be listed. If
+// listing fails after fn has been called, the error is returned to the caller
+// instead of trying the next backend.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) (err error) {
+	for _, b := range be.backends {
+		called := false
+		err = b.List(ctx, t, func(fi restic.FileInfo) error {
+			called = true
+			return fn(fi)
+		})
+		if err == nil || called || ctx.Err() != nil {
+			return err
+		}
+
+		debug.Log("List(%v) on %v failed: %v", t, b.Location(), err)
+	}
+
+	return err
+}
+
+// IsNotExist returns true if the error was caused by a non-existing file in
+// any of the backends.
+func (be *Backend) IsNotExist(err error) bool {
+	for _, b := range be.backends {
+		if b.IsNotExist(err) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Delete removes all data in all backends.
+func (be *Backend) Delete(ctx context.Context) error {
+	for _, b := range be.backends {
+		err := b.Delete(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "Delete %v", b.Location())
+		}
+	}
+
+	return nil
+}
+
+// Close closes all backends and returns the first error.
+func (be *Backend) Close() (err error) {
+	for _, b := range be.backends {
+		cerr := b.Close()
+		if cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
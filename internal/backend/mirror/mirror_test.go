@@ -0,0 +1,133 @@
+package mirror_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/mirror"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/mock"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type mirrorConfig struct {
+	be restic.Backend
+}
+
+func newTestSuite() *test.Suite {
+	return &test.Suite{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			return &mirrorConfig{}, nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*mirrorConfig)
+			if c.be != nil {
+				ok, err := c.be.Test(context.TODO(), restic.Handle{Type: restic.ConfigFile})
+				if err != nil {
+					return nil, err
+				}
+
+				if ok {
+					return nil, errors.New("config already exists")
+				}
+			}
+
+			c.be = mirror.New(mem.New(), mem.New(), mem.New())
+			return c.be, nil
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*mirrorConfig)
+			if c.be == nil {
+				c.be = mirror.New(mem.New(), mem.New(), mem.New())
+			}
+			return c.be, nil
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(cfg interface{}) error {
+			// no cleanup needed
+			return nil
+		},
+	}
+}
+
+func TestSuiteBackendMirror(t *testing.T) {
+	newTestSuite().RunTests(t)
+}
+
+func TestMirrorSave(t *testing.T) {
+	ctx := context.TODO()
+	be1, be2 := mem.New(), mem.New()
+	be := mirror.New(be1, be2)
+
+	data := rtest.Random(23, 1000)
+	h := restic.Handle{Type: restic.DataFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data)))
+
+	for _, b := range []restic.Backend{be1, be2} {
+		buf, err := backend.LoadAll(ctx, nil, b, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, data, buf)
+	}
+
+	// the file is removed from all backends, even if one of them lacks it
+	rtest.OK(t, be1.Remove(ctx, h))
+	rtest.OK(t, be.Remove(ctx, h))
+
+	ok, err := be2.Test(ctx, h)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "file was not removed from second backend")
+
+	err = be.Remove(ctx, h)
+	rtest.Assert(t, be.IsNotExist(err), "wrong error for removing missing file: %v", err)
+}
+
+func TestMirrorReadFallback(t *testing.T) {
+	ctx := context.TODO()
+
+	broken := mock.NewBackend()
+	broken.StatFn = func(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+		return restic.FileInfo{}, errors.New("connection refused")
+	}
+	broken.ListFn = func(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+		return errors.New("connection refused")
+	}
+	broken.TestFn = func(ctx context.Context, h restic.Handle) (bool, error) {
+		return false, errors.New("connection refused")
+	}
+
+	healthy := mem.New()
+	data := rtest.Random(42, 100)
+	h := restic.Handle{Type: restic.SnapshotFile, Name: restic.Hash(data).String()}
+	rtest.OK(t, healthy.Save(ctx, h, restic.NewByteReader(data)))
+
+	be := mirror.New(broken, healthy)
+
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, data, buf)
+
+	fi, err := be.Stat(ctx, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, int64(len(data)), fi.Size)
+
+	ok, err := be.Test(ctx, h)
+	rtest.OK(t, err)
+	rtest.Assert(t, ok, "file not found")
+
+	var names []string
+	rtest.OK(t, be.List(ctx, restic.SnapshotFile, func(fi restic.FileInfo) error {
+		names = append(names, fi.Name)
+		return nil
+	}))
+	rtest.Equals(t, []string{h.Name}, names)
+}
@@ -0,0 +1,145 @@
+// Package quota implements a backend wrapper which limits the total size of
+// the files in a repository.
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Error is returned when saving a file would exceed the quota.
+type Error struct {
+	Limit, Used, Size uint64
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("repository quota exceeded: saving %d bytes would exceed the limit of %d bytes (%d bytes used)",
+		e.Size, e.Limit, e.Used)
+}
+
+// Backend refuses to save files once the size of all files in the underlying
+// backend would exceed a limit. Lock files are always saved and not counted,
+// so that the repository can still be locked to remove data.
+//
+// The quota is only checked by the client, a server can enforce it with
+// "restic serve --max-repo-size".
+type Backend struct {
+	restic.Backend
+	limit uint64
+
+	// used is only valid if known is set, it is determined by listing the
+	// files in the backend when the next file is saved
+	m     sync.Mutex
+	used  uint64
+	known bool
+}
+
+// statically ensure that Backend implements restic.Backend.
+var _ restic.Backend = &Backend{}
+
+// New returns a backend which limits the size of all files stored in be to
+// limit bytes.
+func New(be restic.Backend, limit uint64) *Backend {
+	return &Backend{Backend: be, limit: limit}
+}
+
+// size returns the size of all files in the backend except locks.
+func size(ctx context.Context, be restic.Backend) (uint64, error) {
+	var used uint64
+	for _, t := range []restic.FileType{restic.DataFile, restic.KeyFile, restic.SnapshotFile, restic.IndexFile} {
+		err := be.List(ctx, t, func(fi restic.FileInfo) error {
+			used += uint64(fi.Size)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	fi, err := be.Stat(ctx, restic.Handle{Type: restic.ConfigFile})
+	if err != nil && !be.IsNotExist(err) {
+		return 0, err
+	}
+
+	return used + uint64(fi.Size), nil
+}
+
+// update determines the used space unless it is known, be.m must be held.
+func (be *Backend) update(ctx context.Context) error {
+	if be.known {
+		return nil
+	}
+
+	used, err := size(ctx, be.Backend)
+	if err != nil {
+		return err
+	}
+
+	debug.Log("%v of %v bytes used", used, be.limit)
+	be.used, be.known = used, true
+	return nil
+}
+
+// Used returns the number of bytes used by all files in the backend except
+// locks. The files are listed unless the size is known already.
+func (be *Backend) Used(ctx context.Context) (uint64, error) {
+	be.m.Lock()
+	defer be.m.Unlock()
+
+	err := be.update(ctx)
+	return be.used, err
+}
+
+// Save stores the data in the backend under the given handle. An error is
+// returned if the quota would be exceeded.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	if h.Type == restic.LockFile {
+		return be.Backend.Save(ctx, h, rd)
+	}
+
+	size := uint64(rd.Length())
+
+	be.m.Lock()
+	if err := be.update(ctx); err != nil {
+		be.m.Unlock()
+		return err
+	}
+
+	if be.used+size > be.limit {
+		err := Error{Limit: be.limit, Used: be.used, Size: size}
+		be.m.Unlock()
+		return backend.Permanent(err)
+	}
+	be.used += size
+	be.m.Unlock()
+
+	err := be.Backend.Save(ctx, h, rd)
+	if err != nil {
+		// the remains of the file may still be there, determine the used
+		// space again
+		be.m.Lock()
+		be.known = false
+		be.m.Unlock()
+	}
+
+	return err
+}
+
+// Remove removes the file with the given handle. The size of the file is not
+// known, so the files are listed again when the next file is saved.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	err := be.Backend.Remove(ctx, h)
+
+	if h.Type != restic.LockFile {
+		be.m.Lock()
+		be.known = false
+		be.m.Unlock()
+	}
+
+	return err
+}
@@ -0,0 +1,81 @@
+package quota_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/quota"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func save(be restic.Backend, tpe restic.FileType, data []byte) (restic.Handle, error) {
+	h := restic.Handle{Type: tpe, Name: restic.Hash(data).String()}
+	return h, be.Save(context.TODO(), h, restic.NewByteReader(data))
+}
+
+// countingBackend counts the calls to List and Stat.
+type countingBackend struct {
+	restic.Backend
+	lists, stats int
+}
+
+func (be *countingBackend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	be.lists++
+	return be.Backend.List(ctx, t, fn)
+}
+
+func (be *countingBackend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	be.stats++
+	return be.Backend.Stat(ctx, h)
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.TODO()
+	mbe := &countingBackend{Backend: mem.New()}
+
+	// existing files are counted
+	_, err := save(mbe, restic.SnapshotFile, rtest.Random(1, 300))
+	rtest.OK(t, err)
+
+	// the files are only listed when a file is saved
+	be := quota.New(mbe, 1000)
+	rtest.Equals(t, 0, mbe.lists)
+
+	h, err := save(be, restic.DataFile, rtest.Random(2, 600))
+	rtest.OK(t, err)
+	used, err := be.Used(ctx)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(900), used)
+
+	_, err = save(be, restic.DataFile, rtest.Random(3, 200))
+	_, ok := errors.Cause(err).(quota.Error)
+	rtest.Assert(t, ok, "wrong error returned: %v", err)
+	rtest.Assert(t, backend.IsPermanent(err), "error is not permanent: %v", err)
+	used, err = be.Used(ctx)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(900), used)
+
+	// lock files are saved even if the quota is exceeded
+	lists := mbe.lists
+	lock, err := save(be, restic.LockFile, rtest.Random(4, 200))
+	rtest.OK(t, err)
+	rtest.OK(t, be.Remove(ctx, lock))
+
+	// removing files frees space, the size is determined by listing the
+	// files again
+	stats := mbe.stats
+	rtest.OK(t, be.Remove(ctx, h))
+	rtest.Equals(t, stats, mbe.stats)
+	rtest.Equals(t, lists, mbe.lists)
+
+	used, err = be.Used(ctx)
+	rtest.OK(t, err)
+	rtest.Equals(t, uint64(300), used)
+
+	_, err = save(be, restic.DataFile, rtest.Random(3, 200))
+	rtest.OK(t, err)
+}
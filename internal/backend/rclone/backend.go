@@ -20,6 +20,7 @@ import (
 	"github.com/restic/restic/internal/debug"
 	"github.com/restic/restic/internal/errors"
 	"github.com/restic/restic/internal/limiter"
+	"github.com/restic/restic/internal/restic"
 	"golang.org/x/net/context/ctxhttp"
 	"golang.org/x/net/http2"
 )
@@ -309,3 +310,9 @@ func (be *Backend) Close() error {
 	debug.Log("wait for rclone returned: %v", be.waitResult)
 	return be.waitResult
 }
+
+// Capabilities returns the properties of the backend, which depend on the
+// storage rclone is configured for.
+func (be *Backend) Capabilities() restic.Capabilities {
+	return restic.Capabilities{}
+}
@@ -106,6 +106,12 @@ func (b *Backend) Location() string {
 	return b.url.String()
 }
 
+// Capabilities returns the properties of the backend.
+func (b *Backend) Capabilities() restic.Capabilities {
+	// the server may write files in place
+	return restic.Capabilities{}
+}
+
 // Save stores data in the backend at the handle.
 func (b *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
 	if err := h.Valid(); err != nil {
@@ -146,6 +152,18 @@ func (b *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindRea
 		return errors.Wrap(err, "client.Post")
 	}
 
+	if err = backend.HTTPAuthError(resp); err != nil {
+		return err
+	}
+
+	// the server refused the file, retrying does not help
+	switch resp.StatusCode {
+	case http.StatusInsufficientStorage:
+		return backend.Permanent(errors.Errorf("repository quota on the server exceeded: %v", resp.Status))
+	case http.StatusRequestEntityTooLarge:
+		return backend.Permanent(errors.Errorf("file too large for the server: %v", resp.Status))
+	}
+
 	if resp.StatusCode != 200 {
 		return errors.Errorf("server response unexpected: %v (%v)", resp.Status, resp.StatusCode)
 	}
@@ -220,6 +238,11 @@ func (b *Backend) openReader(ctx context.Context, h restic.Handle, length int, o
 		return nil, ErrIsNotExist{h}
 	}
 
+	if err = backend.HTTPAuthError(resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+
 	if resp.StatusCode != 200 && resp.StatusCode != 206 {
 		_ = resp.Body.Close()
 		return nil, errors.Errorf("unexpected HTTP response (%v): %v", resp.StatusCode, resp.Status)
@@ -257,6 +280,10 @@ func (b *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, e
 		return restic.FileInfo{}, ErrIsNotExist{h}
 	}
 
+	if err = backend.HTTPAuthError(resp); err != nil {
+		return restic.FileInfo{}, err
+	}
+
 	if resp.StatusCode != 200 {
 		return restic.FileInfo{}, errors.Errorf("unexpected HTTP response (%v): %v", resp.StatusCode, resp.Status)
 	}
@@ -308,6 +335,11 @@ func (b *Backend) Remove(ctx context.Context, h restic.Handle) error {
 		return ErrIsNotExist{h}
 	}
 
+	if err = backend.HTTPAuthError(resp); err != nil {
+		_ = resp.Body.Close()
+		return err
+	}
+
 	if resp.StatusCode != 200 {
 		return errors.Errorf("blob not removed, server response: %v (%v)", resp.Status, resp.StatusCode)
 	}
@@ -342,6 +374,11 @@ func (b *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.Fi
 		return errors.Wrap(err, "List")
 	}
 
+	if err = backend.HTTPAuthError(resp); err != nil {
+		_ = resp.Body.Close()
+		return err
+	}
+
 	if resp.StatusCode != 200 {
 		return errors.Errorf("List failed, server response: %v (%v)", resp.Status, resp.StatusCode)
 	}
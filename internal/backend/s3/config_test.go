@@ -1,6 +1,10 @@
 package s3
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/restic/restic/internal/restic"
+)
 
 var configTests = []struct {
 	s   string
@@ -111,3 +115,66 @@ func TestParseConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestServerSideEncryption(t *testing.T) {
+	var tests = []struct {
+		sse, keyID string
+		valid      bool
+	}{
+		{"", "", true},
+		{"AES256", "", true},
+		{"aws:kms", "", true},
+		{"aws:kms", "arn:aws:kms:eu-central-1:123456789012:key/foo", true},
+		{"", "foo", false},
+		{"AES256", "foo", false},
+		{"aes", "", false},
+	}
+
+	for i, test := range tests {
+		_, err := serverSideEncryption(Config{SSE: test.sse, SSEKMSKeyID: test.keyID})
+		if test.valid && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+
+		if !test.valid && err == nil {
+			t.Errorf("test %d: expected error not found", i)
+		}
+	}
+}
+
+func TestStorageClass(t *testing.T) {
+	var tests = []struct {
+		class string
+		valid bool
+	}{
+		{"", true},
+		{"STANDARD_IA", true},
+		{"ONEZONE_IA", true},
+		{"GLACIER", false},
+		{"glacier", false},
+		{"DEEP_ARCHIVE", false},
+	}
+
+	for i, test := range tests {
+		err := checkStorageClass(test.class)
+		if test.valid && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+
+		if !test.valid && err == nil {
+			t.Errorf("test %d: expected error not found", i)
+		}
+	}
+
+	be := &Backend{cfg: Config{StorageClass: "STANDARD_IA"}}
+	for _, tpe := range []restic.FileType{restic.DataFile, restic.KeyFile, restic.LockFile, restic.SnapshotFile, restic.IndexFile, restic.ConfigFile} {
+		want := ""
+		if tpe == restic.DataFile {
+			want = "STANDARD_IA"
+		}
+
+		if class := be.storageClass(tpe); class != want {
+			t.Errorf("wrong storage class for %v: want %q, got %q", tpe, want, class)
+		}
+	}
+}
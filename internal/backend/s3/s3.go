@@ -16,6 +16,7 @@ import (
 
 	"github.com/minio/minio-go/v6"
 	"github.com/minio/minio-go/v6/pkg/credentials"
+	"github.com/minio/minio-go/v6/pkg/encrypt"
 
 	"github.com/restic/restic/internal/debug"
 )
@@ -25,6 +26,7 @@ type Backend struct {
 	client *minio.Client
 	sem    *backend.Semaphore
 	cfg    Config
+	sse    encrypt.ServerSide
 	backend.Layout
 }
 
@@ -66,9 +68,35 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 			},
 		},
 	})
-	client, err := minio.NewWithCredentials(cfg.Endpoint, creds, !cfg.UseHTTP, cfg.Region)
+	options := &minio.Options{
+		Creds:  creds,
+		Secure: !cfg.UseHTTP,
+		Region: cfg.Region,
+	}
+
+	switch strings.ToLower(cfg.BucketLookup) {
+	case "", "auto":
+		options.BucketLookup = minio.BucketLookupAuto
+	case "dns":
+		options.BucketLookup = minio.BucketLookupDNS
+	case "path":
+		options.BucketLookup = minio.BucketLookupPath
+	default:
+		return nil, errors.Fatalf(`bad bucket-lookup style %q must be "auto", "path" or "dns"`, cfg.BucketLookup)
+	}
+
+	client, err := minio.NewWithOptions(cfg.Endpoint, options)
 	if err != nil {
-		return nil, errors.Wrap(err, "minio.NewWithCredentials")
+		return nil, errors.Wrap(err, "minio.NewWithOptions")
+	}
+
+	sse, err := serverSideEncryption(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkStorageClass(cfg.StorageClass); err != nil {
+		return nil, err
 	}
 
 	sem, err := backend.NewSemaphore(cfg.Connections)
@@ -80,6 +108,7 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 		client: client,
 		sem:    sem,
 		cfg:    cfg,
+		sse:    sse,
 	}
 
 	client.SetCustomTransport(rt)
@@ -94,6 +123,63 @@ func open(cfg Config, rt http.RoundTripper) (*Backend, error) {
 	return be, nil
 }
 
+// kmsDefaultKey requests SSE-KMS encryption with the default KMS key of the
+// account.
+type kmsDefaultKey struct{}
+
+func (kmsDefaultKey) Type() encrypt.Type { return encrypt.KMS }
+
+func (kmsDefaultKey) Marshal(h http.Header) {
+	h.Set("X-Amz-Server-Side-Encryption", "aws:kms")
+}
+
+// serverSideEncryption returns the server-side encryption requested for
+// uploaded files, or nil if none is configured.
+func serverSideEncryption(cfg Config) (encrypt.ServerSide, error) {
+	switch cfg.SSE {
+	case "":
+		if cfg.SSEKMSKeyID != "" {
+			return nil, errors.Fatal("s3.sse-kms-key-id requires s3.sse=aws:kms")
+		}
+		return nil, nil
+	case "AES256":
+		if cfg.SSEKMSKeyID != "" {
+			return nil, errors.Fatal("s3.sse-kms-key-id requires s3.sse=aws:kms")
+		}
+		return encrypt.NewSSE(), nil
+	case "aws:kms":
+		if cfg.SSEKMSKeyID == "" {
+			return kmsDefaultKey{}, nil
+		}
+		return encrypt.NewSSEKMS(cfg.SSEKMSKeyID, nil)
+	}
+
+	return nil, errors.Fatalf(`bad server-side encryption %q, must be "AES256" or "aws:kms"`, cfg.SSE)
+}
+
+// checkStorageClass returns an error for storage classes which archive the
+// data. Files in these classes cannot be read without restoring them first,
+// which restic does not support.
+func checkStorageClass(class string) error {
+	switch strings.ToUpper(class) {
+	case "GLACIER", "DEEP_ARCHIVE":
+		return errors.Fatalf("storage class %v is not supported, files in it cannot be read without restoring them first", class)
+	}
+
+	return nil
+}
+
+// storageClass returns the storage class for files of type t. Only data
+// files are stored with the configured storage class, all other files are
+// small and read frequently, so they are kept in the default storage class.
+func (be *Backend) storageClass(t restic.FileType) string {
+	if t == restic.DataFile {
+		return be.cfg.StorageClass
+	}
+
+	return ""
+}
+
 // Open opens the S3 backend at bucket and region. The bucket is created if it
 // does not exist yet.
 func Open(cfg Config, rt http.RoundTripper) (restic.Backend, error) {
@@ -226,6 +312,16 @@ func (be *Backend) Location() string {
 	return be.Join(be.cfg.Bucket, be.cfg.Prefix)
 }
 
+// Capabilities returns the properties of the backend.
+func (be *Backend) Capabilities() restic.Capabilities {
+	// an object only becomes visible after the upload has been completed,
+	// multipart uploads are limited to 5 TiB
+	return restic.Capabilities{
+		AtomicSave:  true,
+		MaxFileSize: 5 << 40,
+	}
+}
+
 // Path returns the path in the bucket that is used for this backend.
 func (be *Backend) Path() string {
 	return be.cfg.Prefix
@@ -244,8 +340,9 @@ func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindRe
 	be.sem.GetToken()
 	defer be.sem.ReleaseToken()
 
-	opts := minio.PutObjectOptions{StorageClass: be.cfg.StorageClass}
+	opts := minio.PutObjectOptions{ServerSideEncryption: be.sse}
 	opts.ContentType = "application/octet-stream"
+	opts.StorageClass = be.storageClass(h.Type)
 
 	debug.Log("PutObject(%v, %v, %v)", be.cfg.Bucket, objName, rd.Length())
 	n, err := be.client.PutObjectWithContext(ctx, be.cfg.Bucket, objName, ioutil.NopCloser(rd), int64(rd.Length()), opts)
@@ -308,6 +405,11 @@ func (be *Backend) openReader(ctx context.Context, h restic.Handle, length int,
 	rd, _, _, err := coreClient.GetObjectWithContext(ctx, be.cfg.Bucket, objName, opts)
 	if err != nil {
 		be.sem.ReleaseToken()
+		if e, ok := errors.Cause(err).(minio.ErrorResponse); ok && e.Code == "InvalidObjectState" {
+			// retrying does not help, the file has to be restored from
+			// the archive first
+			return nil, backend.Permanent(errors.Errorf("%v is archived (e.g. in Glacier) and must be restored before it can be read", objName))
+		}
 		return nil, err
 	}
 
@@ -487,7 +589,7 @@ func (be *Backend) Rename(h restic.Handle, l backend.Layout) error {
 
 	src := minio.NewSourceInfo(be.cfg.Bucket, oldname, nil)
 
-	dst, err := minio.NewDestinationInfo(be.cfg.Bucket, newname, nil, nil)
+	dst, err := minio.NewDestinationInfo(be.cfg.Bucket, newname, be.sse, nil)
 	if err != nil {
 		return errors.Wrap(err, "NewDestinationInfo")
 	}
@@ -250,6 +250,12 @@ func (r *SFTP) Location() string {
 	return r.p
 }
 
+// Capabilities returns the properties of the backend.
+func (r *SFTP) Capabilities() restic.Capabilities {
+	// files are written in place, so a partial file may be visible
+	return restic.Capabilities{}
+}
+
 func (r *SFTP) mkdirAll(dir string, mode os.FileMode) error {
 	// check if directory already exists
 	fi, err := r.c.Lstat(dir)
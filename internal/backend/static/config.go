@@ -0,0 +1,52 @@
+package static
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/options"
+)
+
+// Config contains all configuration necessary to read a repository from a
+// static HTTP file server.
+type Config struct {
+	URL         *url.URL
+	Connections uint `option:"connections" help:"set a limit for the number of concurrent connections (default: 5)"`
+}
+
+func init() {
+	options.Register("static", Config{})
+}
+
+// NewConfig returns a new Config with the default values filled in.
+func NewConfig() Config {
+	return Config{
+		Connections: 5,
+	}
+}
+
+// ParseConfig parses the string s and extracts the server URL. The supported
+// format is static:https://host/path.
+func ParseConfig(s string) (interface{}, error) {
+	if !strings.HasPrefix(s, "static:") {
+		return nil, errors.New("invalid static backend specification")
+	}
+
+	u, err := url.Parse(s[7:])
+	if err != nil {
+		return nil, errors.Wrap(err, "url.Parse")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("invalid URL scheme %q, only http and https are supported", u.Scheme)
+	}
+
+	u.Path = path.Clean("/" + u.Path)
+	u.RawPath = ""
+
+	cfg := NewConfig()
+	cfg.URL = u
+	return cfg, nil
+}
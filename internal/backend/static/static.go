@@ -0,0 +1,199 @@
+package static
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/httpfile"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// make sure the static backend implements restic.Backend
+var _ restic.Backend = &Backend{}
+
+// Backend reads a repository published by a static HTTP file server (e.g.
+// nginx or Apache with directory listings enabled). The backend is read-only,
+// all attempts to modify the repository return an error.
+type Backend struct {
+	*httpfile.Client
+}
+
+// ErrReadOnly is returned by all operations that would modify the repository.
+var ErrReadOnly = errors.New("the static backend is read-only")
+
+// errReadOnly wraps ErrReadOnly so that the operation is not retried.
+func errReadOnly() error {
+	return backend.Permanent(ErrReadOnly)
+}
+
+// Open opens the static backend with the given config.
+func Open(cfg Config, rt http.RoundTripper) (*Backend, error) {
+	debug.Log("open, config %#v", cfg)
+
+	client, err := httpfile.New(cfg.URL, rt, cfg.Connections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{Client: client}, nil
+}
+
+// Location returns this backend's location (the server's URL).
+func (be *Backend) Location() string {
+	u := *be.URL()
+	if u.User != nil {
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
+
+// Capabilities returns the properties of the backend.
+func (be *Backend) Capabilities() restic.Capabilities {
+	return restic.Capabilities{}
+}
+
+// Save returns ErrReadOnly.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	return errReadOnly()
+}
+
+// Remove returns ErrReadOnly.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	return errReadOnly()
+}
+
+// Delete returns ErrReadOnly.
+func (be *Backend) Delete(ctx context.Context) error {
+	return errReadOnly()
+}
+
+// Close does nothing, all connections are managed by the HTTP client.
+func (be *Backend) Close() error {
+	return nil
+}
+
+var (
+	hrefPattern     = regexp.MustCompile(`(?i)href\s*=\s*"([^"]*)"`)
+	filenamePattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	subdirPattern   = regexp.MustCompile(`^[0-9a-f]{2}$`)
+)
+
+// readDir parses the directory listing generated by the server for dir and
+// returns the names of all links matching pattern. A directory that does not
+// exist is treated as empty.
+func (be *Backend) readDir(ctx context.Context, dir string, pattern *regexp.Regexp) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, be.FileURL(dir+"/"), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "NewRequest")
+	}
+
+	resp, err := be.Do(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "GET")
+	}
+
+	defer func() {
+		_ = httpfile.Drain(resp)
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		debug.Log("ignoring non-existing directory %v", dir)
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("List failed, server response: %v (%v)", resp.Status, resp.StatusCode)
+	}
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReadAll")
+	}
+
+	seen := make(map[string]struct{})
+	var names []string
+	for _, match := range hrefPattern.FindAllStringSubmatch(string(buf), -1) {
+		u, err := url.Parse(match[1])
+		if err != nil {
+			continue
+		}
+
+		name := path.Base(strings.TrimSuffix(u.Path, "/"))
+		if !pattern.MatchString(name) {
+			continue
+		}
+
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it. The sizes
+// of the files are determined with an additional HEAD request per file.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	debug.Log("List %v", t)
+
+	basedir, subdirs := be.Basedir(t)
+	if !subdirs {
+		return be.listDir(ctx, t, basedir, fn)
+	}
+
+	dirs, err := be.readDir(ctx, basedir, subdirPattern)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		err = be.listDir(ctx, t, path.Join(basedir, dir), fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// listDir runs fn for each file in dir.
+func (be *Backend) listDir(ctx context.Context, t restic.FileType, dir string, fn func(restic.FileInfo) error) error {
+	names, err := be.readDir(ctx, dir, filenamePattern)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fi, err := be.Stat(ctx, restic.Handle{Type: t, Name: name})
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err = fn(fi)
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return ctx.Err()
+}
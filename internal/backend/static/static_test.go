@@ -0,0 +1,89 @@
+package static_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/backend/static"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func listNames(t testing.TB, be restic.Backend, tpe restic.FileType) []string {
+	var names []string
+	err := be.List(context.TODO(), tpe, func(fi restic.FileInfo) error {
+		names = append(names, fi.Name)
+		return nil
+	})
+	rtest.OK(t, err)
+	sort.Strings(names)
+	return names
+}
+
+func TestStaticBackend(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	lbe, err := local.Create(local.Config{Path: dir})
+	rtest.OK(t, err)
+
+	var handles []restic.Handle
+	for _, tpe := range []restic.FileType{restic.DataFile, restic.DataFile, restic.SnapshotFile, restic.KeyFile, restic.IndexFile} {
+		data := rtest.Random(len(handles), 1000)
+		h := restic.Handle{Type: tpe, Name: restic.Hash(data).String()}
+		rtest.OK(t, lbe.Save(ctx, h, restic.NewByteReader(data)))
+		handles = append(handles, h)
+	}
+	rtest.OK(t, lbe.Save(ctx, restic.Handle{Type: restic.ConfigFile}, restic.NewByteReader([]byte("config"))))
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	cfg, err := static.ParseConfig("static:" + srv.URL)
+	rtest.OK(t, err)
+
+	be, err := static.Open(cfg.(static.Config), http.DefaultTransport)
+	rtest.OK(t, err)
+
+	for _, tpe := range []restic.FileType{restic.DataFile, restic.SnapshotFile, restic.KeyFile, restic.IndexFile, restic.LockFile} {
+		rtest.Equals(t, listNames(t, lbe, tpe), listNames(t, be, tpe))
+	}
+
+	for _, h := range append(handles, restic.Handle{Type: restic.ConfigFile}) {
+		want, err := backend.LoadAll(ctx, nil, lbe, h)
+		rtest.OK(t, err)
+
+		buf, err := backend.LoadAll(ctx, nil, be, h)
+		rtest.OK(t, err)
+		if !bytes.Equal(want, buf) {
+			t.Errorf("wrong data returned for %v", h)
+		}
+
+		fi, err := be.Stat(ctx, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, int64(len(want)), fi.Size)
+	}
+
+	missing := restic.Handle{Type: restic.DataFile, Name: restic.NewRandomID().String()}
+	ok, err := be.Test(ctx, missing)
+	rtest.OK(t, err)
+	rtest.Assert(t, !ok, "missing file %v reported as existing", missing)
+
+	_, err = be.Stat(ctx, missing)
+	rtest.Assert(t, be.IsNotExist(err), "wrong error for missing file: %v", err)
+
+	err = be.Save(ctx, missing, restic.NewByteReader([]byte("foo")))
+	rtest.Assert(t, err != nil, "Save did not return an error")
+
+	err = be.Remove(ctx, handles[0])
+	rtest.Assert(t, err != nil, "Remove did not return an error")
+}
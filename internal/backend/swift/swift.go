@@ -110,6 +110,16 @@ func (be *beSwift) Location() string {
 	return be.container
 }
 
+// Capabilities returns the properties of the backend.
+func (be *beSwift) Capabilities() restic.Capabilities {
+	// objects are uploaded in a single request, which is limited to 5 GiB,
+	// and only become visible after it has been completed
+	return restic.Capabilities{
+		AtomicSave:  true,
+		MaxFileSize: 5 << 30,
+	}
+}
+
 // Load runs fn with a reader that yields the contents of the file at h at the
 // given offset.
 func (be *beSwift) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
@@ -559,6 +559,63 @@ func (s *Suite) TestSave(t *testing.T) {
 	}
 }
 
+// failingReader returns an error after the first half of the data was read.
+type failingReader struct {
+	restic.RewindReader
+	read, limit int64
+}
+
+func (fr *failingReader) Read(p []byte) (int, error) {
+	if fr.read+int64(len(p)) > fr.limit {
+		p = p[:fr.limit-fr.read]
+	}
+	n, err := fr.RewindReader.Read(p)
+	fr.read += int64(n)
+	if err == nil && fr.read >= fr.limit {
+		err = errors.New("injected read error")
+	}
+	return n, err
+}
+
+func (fr *failingReader) Rewind() error {
+	fr.read = 0
+	return fr.RewindReader.Rewind()
+}
+
+// TestSaveError tests that a backend which claims atomic uploads does not
+// leave a file behind when reading the data fails during Save.
+func (s *Suite) TestSaveError(t *testing.T) {
+	seedRand(t)
+
+	b := s.open(t)
+	defer s.close(t, b)
+
+	length := rand.Intn(1<<20) + 200000
+	data := test.Random(23, length)
+	var id restic.ID
+	copy(id[:], data)
+
+	h := restic.Handle{Type: restic.DataFile, Name: id.String()}
+	rd := &failingReader{RewindReader: restic.NewByteReader(data), limit: int64(length / 2)}
+	err := b.Save(context.TODO(), h, rd)
+	if err == nil {
+		t.Fatal("Save() did not return an error for a failing reader")
+	}
+
+	if !b.Capabilities().AtomicSave {
+		// the backend may keep the partial file, the RetryBackend removes it
+		_ = b.Remove(context.TODO(), h)
+		return
+	}
+
+	found, err := b.Test(context.TODO(), h)
+	test.OK(t, err)
+	if found {
+		t.Errorf("backend with AtomicSave left a file behind after a failed Save")
+		_ = b.Remove(context.TODO(), h)
+	}
+}
+
 var filenameTests = []struct {
 	name string
 	data string
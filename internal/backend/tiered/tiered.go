@@ -0,0 +1,170 @@
+// Package tiered implements a backend which stores pack files containing file
+// data in a separate, usually slower and cheaper, backend.
+package tiered
+
+import (
+	"context"
+	"io"
+
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// Backend stores pack files containing data blobs in the cold backend and all
+// other files, including pack files containing tree blobs, in the hot backend.
+type Backend struct {
+	hot, cold restic.Backend
+}
+
+// statically ensure that Backend implements restic.Backend.
+var _ restic.Backend = &Backend{}
+
+// New returns a backend which stores the metadata of the repository in hot
+// and pack files with file data in cold.
+func New(hot, cold restic.Backend) *Backend {
+	return &Backend{hot: hot, cold: cold}
+}
+
+// Location returns the location of the hot backend.
+func (be *Backend) Location() string {
+	return be.hot.Location()
+}
+
+// Capabilities returns the properties offered by both backends.
+func (be *Backend) Capabilities() restic.Capabilities {
+	return be.hot.Capabilities().Intersect(be.cold.Capabilities())
+}
+
+// Save stores the data in the cold backend if h is a pack file which does not
+// contain tree blobs, and in the hot backend otherwise. The blob type is taken
+// from ctx, see restic.WithPackBlobType. The config is saved to both backends,
+// so that the cold backend can be recognized as part of the repository.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	switch {
+	case h.Type == restic.DataFile && restic.PackBlobType(ctx) != restic.TreeBlob:
+		return be.cold.Save(ctx, h, rd)
+
+	case h.Type == restic.ConfigFile:
+		err := be.hot.Save(ctx, h, rd)
+		if err != nil {
+			return err
+		}
+
+		err = rd.Rewind()
+		if err != nil {
+			return err
+		}
+
+		return be.cold.Save(ctx, h, rd)
+	}
+
+	return be.hot.Save(ctx, h, rd)
+}
+
+// Load runs fn with a reader that yields the contents of the file at h at the
+// given offset. Pack files are loaded from the cold backend if they cannot be
+// found in the hot backend.
+func (be *Backend) Load(ctx context.Context, h restic.Handle, length int, offset int64, fn func(rd io.Reader) error) error {
+	err := be.hot.Load(ctx, h, length, offset, fn)
+	if h.Type != restic.DataFile || err == nil || !be.hot.IsNotExist(err) {
+		return err
+	}
+
+	debug.Log("Load(%v): not found in %v, using %v", h, be.hot.Location(), be.cold.Location())
+	return be.cold.Load(ctx, h, length, offset, fn)
+}
+
+// Stat returns information about the file identified by h.
+func (be *Backend) Stat(ctx context.Context, h restic.Handle) (restic.FileInfo, error) {
+	fi, err := be.hot.Stat(ctx, h)
+	if h.Type != restic.DataFile || err == nil || !be.hot.IsNotExist(err) {
+		return fi, err
+	}
+
+	return be.cold.Stat(ctx, h)
+}
+
+// Test returns whether a file exists.
+func (be *Backend) Test(ctx context.Context, h restic.Handle) (bool, error) {
+	found, err := be.hot.Test(ctx, h)
+	if h.Type != restic.DataFile || err != nil || found {
+		return found, err
+	}
+
+	return be.cold.Test(ctx, h)
+}
+
+// Remove removes the file from the backend it is stored in.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	err := be.hot.Remove(ctx, h)
+
+	switch {
+	case h.Type == restic.ConfigFile && err == nil:
+		err = be.cold.Remove(ctx, h)
+		if err != nil && be.cold.IsNotExist(err) {
+			return nil
+		}
+		return err
+
+	case h.Type != restic.DataFile || err == nil || !be.hot.IsNotExist(err):
+		return err
+	}
+
+	return be.cold.Remove(ctx, h)
+}
+
+// List runs fn for each file of type t. Pack files are listed from both
+// backends, all other files only from the hot backend.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	if t != restic.DataFile {
+		return be.hot.List(ctx, t, fn)
+	}
+
+	seen := make(map[string]struct{})
+	err := be.hot.List(ctx, t, func(fi restic.FileInfo) error {
+		seen[fi.Name] = struct{}{}
+		return fn(fi)
+	})
+	if err != nil {
+		return err
+	}
+
+	return be.cold.List(ctx, t, func(fi restic.FileInfo) error {
+		if _, ok := seen[fi.Name]; ok {
+			return nil
+		}
+		return fn(fi)
+	})
+}
+
+// IsNotExist returns true if the error was caused by a non-existing file in
+// one of the backends.
+func (be *Backend) IsNotExist(err error) bool {
+	return be.hot.IsNotExist(err) || be.cold.IsNotExist(err)
+}
+
+// Delete removes all data in both backends.
+func (be *Backend) Delete(ctx context.Context) error {
+	err := be.hot.Delete(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Delete %v", be.hot.Location())
+	}
+
+	err = be.cold.Delete(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "Delete %v", be.cold.Location())
+	}
+
+	return nil
+}
+
+// Close closes both backends and returns the first error.
+func (be *Backend) Close() error {
+	err := be.hot.Close()
+	cerr := be.cold.Close()
+	if err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package tiered_test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/mem"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/backend/tiered"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+type tieredConfig struct {
+	be restic.Backend
+}
+
+func newTestSuite() *test.Suite {
+	return &test.Suite{
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			return &tieredConfig{}, nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*tieredConfig)
+			if c.be != nil {
+				ok, err := c.be.Test(context.TODO(), restic.Handle{Type: restic.ConfigFile})
+				if err != nil {
+					return nil, err
+				}
+
+				if ok {
+					return nil, errors.New("config already exists")
+				}
+			}
+
+			c.be = tiered.New(mem.New(), mem.New())
+			return c.be, nil
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(cfg interface{}) (restic.Backend, error) {
+			c := cfg.(*tieredConfig)
+			if c.be == nil {
+				c.be = tiered.New(mem.New(), mem.New())
+			}
+			return c.be, nil
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(cfg interface{}) error {
+			// no cleanup needed
+			return nil
+		},
+	}
+}
+
+func TestSuiteBackendTiered(t *testing.T) {
+	newTestSuite().RunTests(t)
+}
+
+func listNames(t testing.TB, be restic.Backend, tpe restic.FileType) []string {
+	var names []string
+	rtest.OK(t, be.List(context.TODO(), tpe, func(fi restic.FileInfo) error {
+		names = append(names, fi.Name)
+		return nil
+	}))
+	sort.Strings(names)
+	return names
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.TODO()
+	hot, cold := mem.New(), mem.New()
+	be := tiered.New(hot, cold)
+
+	save := func(ctx context.Context, tpe restic.FileType, data []byte) restic.Handle {
+		h := restic.Handle{Type: tpe, Name: restic.Hash(data).String()}
+		rtest.OK(t, be.Save(ctx, h, restic.NewByteReader(data)))
+		return h
+	}
+
+	dataPack := save(restic.WithPackBlobType(ctx, restic.DataBlob), restic.DataFile, rtest.Random(1, 100))
+	treePack := save(restic.WithPackBlobType(ctx, restic.TreeBlob), restic.DataFile, rtest.Random(2, 100))
+	index := save(ctx, restic.IndexFile, rtest.Random(3, 100))
+
+	cfg := restic.Handle{Type: restic.ConfigFile}
+	rtest.OK(t, be.Save(ctx, cfg, restic.NewByteReader(rtest.Random(4, 100))))
+	for _, b := range []restic.Backend{hot, cold} {
+		found, err := b.Test(ctx, cfg)
+		rtest.OK(t, err)
+		rtest.Assert(t, found, "config not saved to %v", b.Location())
+	}
+
+	rtest.Equals(t, []string{treePack.Name}, listNames(t, hot, restic.DataFile))
+	rtest.Equals(t, []string{index.Name}, listNames(t, hot, restic.IndexFile))
+	rtest.Equals(t, []string{dataPack.Name}, listNames(t, cold, restic.DataFile))
+	rtest.Equals(t, []string(nil), listNames(t, cold, restic.IndexFile))
+
+	want := []string{dataPack.Name, treePack.Name}
+	sort.Strings(want)
+	rtest.Equals(t, want, listNames(t, be, restic.DataFile))
+
+	for _, h := range []restic.Handle{dataPack, treePack, index} {
+		_, err := backend.LoadAll(ctx, nil, be, h)
+		rtest.OK(t, err)
+
+		fi, err := be.Stat(ctx, h)
+		rtest.OK(t, err)
+		rtest.Equals(t, int64(100), fi.Size)
+
+		rtest.OK(t, be.Remove(ctx, h))
+
+		found, err := be.Test(ctx, h)
+		rtest.OK(t, err)
+		rtest.Assert(t, !found, "file %v not removed", h)
+	}
+}
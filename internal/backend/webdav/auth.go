@@ -0,0 +1,254 @@
+package webdav
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/debug"
+)
+
+// authTransport adds credentials to all requests. The credentials are not sent
+// until the server answers a request with a challenge, and only with the
+// scheme the server asked for. Digest authentication is preferred when the
+// server offers it, so the password is never sent in clear text to such a
+// server. The scheme is remembered for all following requests.
+type authTransport struct {
+	rt                 http.RoundTripper
+	username, password string
+
+	m         sync.Mutex
+	basic     bool
+	challenge map[string]string
+	nc        uint
+}
+
+func newAuthTransport(rt http.RoundTripper, username, password string) *authTransport {
+	return &authTransport{
+		rt:       rt,
+		username: username,
+		password: password,
+	}
+}
+
+// RoundTrip authenticates the request and passes it on to the underlying
+// transport. When the server requests authentication, the request is sent
+// again with credentials if its body can be replayed.
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.rt.RoundTrip(t.authenticate(req))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	if t.username == "" && t.password == "" {
+		return resp, nil
+	}
+
+	if req.Body != nil && req.GetBody == nil {
+		// the body cannot be sent again, let the caller handle the error
+		return resp, nil
+	}
+
+	var challenge map[string]string
+	var basic bool
+	for _, header := range resp.Header["Www-Authenticate"] {
+		if c := parseDigestChallenge(header); c != nil {
+			challenge = c
+			break
+		}
+
+		if isBasicChallenge(header) {
+			basic = true
+		}
+	}
+
+	t.m.Lock()
+	switch {
+	case challenge != nil:
+		debug.Log("server requested digest authentication for realm %q", challenge["realm"])
+		t.challenge = challenge
+		t.nc = 0
+	case basic && !t.basic && t.challenge == nil:
+		debug.Log("server requested basic authentication")
+		t.basic = true
+	default:
+		// the server did not ask for a supported scheme, or rejected the
+		// credentials sent with the scheme it asked for
+		t.m.Unlock()
+		return resp, nil
+	}
+	t.m.Unlock()
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	retry := cloneRequest(req)
+	if req.GetBody != nil {
+		retry.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return t.rt.RoundTrip(t.authenticate(retry))
+}
+
+// authenticate returns a copy of req with the Authorization header set for the
+// scheme requested by the server. Without such a request, req is returned
+// unchanged.
+func (t *authTransport) authenticate(req *http.Request) *http.Request {
+	if t.username == "" && t.password == "" {
+		return req
+	}
+
+	t.m.Lock()
+	challenge, basic := t.challenge, t.basic
+	t.nc++
+	nc := t.nc
+	t.m.Unlock()
+
+	switch {
+	case challenge != nil:
+		req2 := cloneRequest(req)
+		req2.Header.Set("Authorization", t.digestAuthorization(challenge, req.Method, req.URL.RequestURI(), nc))
+		return req2
+	case basic:
+		req2 := cloneRequest(req)
+		req2.SetBasicAuth(t.username, t.password)
+		return req2
+	}
+
+	return req
+}
+
+// isBasicChallenge returns true if the WWW-Authenticate header requests basic
+// authentication.
+func isBasicChallenge(header string) bool {
+	const prefix = "basic"
+	return len(header) >= len(prefix) && strings.ToLower(header[:len(prefix)]) == prefix
+}
+
+// cloneRequest returns a shallow copy of req with a separate header map, so
+// that headers can be changed without modifying the original request.
+func cloneRequest(req *http.Request) *http.Request {
+	req2 := new(http.Request)
+	*req2 = *req
+
+	req2.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		req2.Header[k] = append([]string(nil), v...)
+	}
+
+	return req2
+}
+
+// newCnonce returns a random client nonce for digest authentication.
+var newCnonce = func() string {
+	buf := make([]byte, 8)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// digestAuthorization computes the value of the Authorization header for
+// digest authentication as described in RFC 7616.
+func (t *authTransport) digestAuthorization(challenge map[string]string, method, uri string, nc uint) string {
+	var newHash func() hash.Hash
+	algorithm := challenge["algorithm"]
+	switch strings.ToUpper(algorithm) {
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		newHash = md5.New
+	}
+
+	h := func(s string) string {
+		hash := newHash()
+		_, _ = hash.Write([]byte(s))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+
+	realm, nonce := challenge["realm"], challenge["nonce"]
+	ha1 := h(t.username + ":" + realm + ":" + t.password)
+	ha2 := h(method + ":" + uri)
+
+	var qop, cnonce, response string
+	for _, q := range strings.Split(challenge["qop"], ",") {
+		if strings.TrimSpace(q) == "auth" {
+			qop = "auth"
+		}
+	}
+
+	if qop != "" {
+		cnonce = newCnonce()
+		response = h(fmt.Sprintf("%s:%s:%08x:%s:%s:%s", ha1, nonce, nc, cnonce, qop, ha2))
+	} else {
+		response = h(ha1 + ":" + nonce + ":" + ha2)
+	}
+
+	s := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s"`,
+		t.username, realm, nonce, uri, response)
+	if algorithm != "" {
+		s += fmt.Sprintf(`, algorithm=%s`, algorithm)
+	}
+	if qop != "" {
+		s += fmt.Sprintf(`, qop=%s, nc=%08x, cnonce="%s"`, qop, nc, cnonce)
+	}
+	if opaque, ok := challenge["opaque"]; ok {
+		s += fmt.Sprintf(`, opaque="%s"`, opaque)
+	}
+
+	return s
+}
+
+// parseDigestChallenge extracts the parameters from a WWW-Authenticate header
+// requesting digest authentication. It returns nil if the header does not
+// contain a digest challenge.
+func parseDigestChallenge(header string) map[string]string {
+	const prefix = "digest "
+	if len(header) < len(prefix) || strings.ToLower(header[:len(prefix)]) != prefix {
+		return nil
+	}
+
+	params := make(map[string]string)
+	s := header[len(prefix):]
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			break
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				return nil
+			}
+			value, s = s[1:end+1], s[end+2:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+
+		params[key] = value
+	}
+
+	return params
+}
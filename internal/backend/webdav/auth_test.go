@@ -0,0 +1,175 @@
+package webdav
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDigestChallenge(t *testing.T) {
+	var tests = []struct {
+		header string
+		params map[string]string
+	}{
+		{
+			header: `Basic realm="foo"`,
+			params: nil,
+		},
+		{
+			header: `Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`,
+			params: map[string]string{
+				"realm":  "testrealm@host.com",
+				"qop":    "auth,auth-int",
+				"nonce":  "dcd98b7102dd2f0e8b11d0f600bfb0c093",
+				"opaque": "5ccc069c403ebaf9f0171e9517f40e41",
+			},
+		},
+		{
+			header: `digest realm="a, b", algorithm=SHA-256, nonce="xyz"`,
+			params: map[string]string{
+				"realm":     "a, b",
+				"algorithm": "SHA-256",
+				"nonce":     "xyz",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		params := parseDigestChallenge(test.header)
+		if !reflect.DeepEqual(params, test.params) {
+			t.Errorf("wrong params for %q, want:\n  %v\ngot:\n  %v", test.header, test.params, params)
+		}
+	}
+}
+
+func TestDigestAuthorization(t *testing.T) {
+	// example from RFC 2617, section 3.5
+	oldCnonce := newCnonce
+	newCnonce = func() string { return "0a4f113b" }
+	defer func() { newCnonce = oldCnonce }()
+
+	tr := newAuthTransport(nil, "Mufasa", "Circle Of Life")
+	challenge := parseDigestChallenge(`Digest realm="testrealm@host.com", qop="auth,auth-int", nonce="dcd98b7102dd2f0e8b11d0f600bfb0c093", opaque="5ccc069c403ebaf9f0171e9517f40e41"`)
+
+	header := tr.digestAuthorization(challenge, "GET", "/dir/index.html", 1)
+	want := `response="6629fae49393a05397450978507c4ef1"`
+	if !strings.Contains(header, want) {
+		t.Fatalf("header %q does not contain %q", header, want)
+	}
+
+	for _, s := range []string{`nc=00000001`, `cnonce="0a4f113b"`, `qop=auth`, `opaque="5ccc069c403ebaf9f0171e9517f40e41"`} {
+		if !strings.Contains(header, s) {
+			t.Errorf("header %q does not contain %q", header, s)
+		}
+	}
+}
+
+func TestAuthTransportDigestReplay(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), `Digest username="user"`) {
+			w.Header().Set("WWW-Authenticate", `Digest realm="restic", qop="auth", nonce="123"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		bodies = append(bodies, string(buf))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newAuthTransport(http.DefaultTransport, "user", "secret")}
+
+	for _, body := range []string{"foo", "bar"} {
+		req, err := http.NewRequest(http.MethodPut, srv.URL+"/file", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %v", resp.Status)
+		}
+	}
+
+	if !reflect.DeepEqual(bodies, []string{"foo", "bar"}) {
+		t.Fatalf("server received wrong bodies: %v", bodies)
+	}
+}
+
+func TestAuthTransportNoBasicForDigest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, _, ok := r.BasicAuth(); ok {
+			t.Errorf("password sent in clear text for %v %v", r.Method, r.URL.Path)
+		}
+
+		if !strings.HasPrefix(r.Header.Get("Authorization"), `Digest username="user"`) {
+			w.Header().Set("WWW-Authenticate", `Digest realm="restic", qop="auth", nonce="123"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newAuthTransport(http.DefaultTransport, "user", "secret")}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL + "/file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %v", resp.Status)
+		}
+	}
+}
+
+func TestAuthTransportBasic(t *testing.T) {
+	var requests, authorized int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		user, password, ok := r.BasicAuth()
+		if !ok || user != "user" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="restic"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		authorized++
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: newAuthTransport(http.DefaultTransport, "user", "secret")}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(srv.URL + "/file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %v", resp.Status)
+		}
+	}
+
+	// only the first request is sent without credentials
+	if requests != 4 || authorized != 3 {
+		t.Fatalf("unexpected number of requests: %d, authorized %d", requests, authorized)
+	}
+}
@@ -0,0 +1,395 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/restic/restic/internal/backend/httpfile"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// make sure the WebDAV backend implements restic.Backend
+var _ restic.Backend = &Backend{}
+
+// Backend stores data on a WebDAV server.
+type Backend struct {
+	*httpfile.Client
+}
+
+// Open opens the WebDAV backend with the given config.
+func Open(cfg Config, rt http.RoundTripper) (*Backend, error) {
+	debug.Log("open, config %#v", cfg)
+
+	// credentials are handled by the transport, which only sends them after
+	// the server has requested authentication and uses the scheme the server
+	// asked for, so that the password is not sent in clear text to a server
+	// using digest authentication
+	u := *cfg.URL
+	if u.User != nil {
+		password, _ := u.User.Password()
+		rt = newAuthTransport(rt, u.User.Username(), password)
+		u.User = nil
+	}
+
+	client, err := httpfile.New(&u, rt, cfg.Connections)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Backend{Client: client}, nil
+}
+
+// Create creates all the necessary collections for a new repository on the
+// WebDAV server.
+func Create(cfg Config, rt http.RoundTripper) (*Backend, error) {
+	be, err := Open(cfg, rt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = be.Stat(context.TODO(), restic.Handle{Type: restic.ConfigFile})
+	if err == nil {
+		return nil, errors.Fatal("config file already exists")
+	}
+
+	if !be.IsNotExist(err) {
+		return nil, err
+	}
+
+	if err = be.mkcol(context.TODO(), be.URL().Path); err != nil {
+		return nil, err
+	}
+
+	for _, d := range be.Paths() {
+		if err = be.mkcol(context.TODO(), d); err != nil {
+			return nil, err
+		}
+	}
+
+	return be, nil
+}
+
+// Location returns this backend's location (the server's URL without
+// credentials).
+func (be *Backend) Location() string {
+	return be.URL().String()
+}
+
+// Capabilities returns the properties of the backend.
+func (be *Backend) Capabilities() restic.Capabilities {
+	// the server may write files in place
+	return restic.Capabilities{}
+}
+
+// mkcol creates the collection dir on the server. Missing parent collections
+// are created as well, existing collections are ignored.
+func (be *Backend) mkcol(ctx context.Context, dir string) error {
+	if dir == "/" {
+		return nil
+	}
+
+	req, err := http.NewRequest("MKCOL", be.FileURL(dir+"/"), nil)
+	if err != nil {
+		return errors.Wrap(err, "NewRequest")
+	}
+
+	resp, err := be.Do(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "MKCOL")
+	}
+
+	if err = httpfile.Drain(resp); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusOK:
+		return nil
+	case http.StatusMethodNotAllowed:
+		// the collection already exists
+		return nil
+	case http.StatusConflict:
+		// the parent collection is missing
+		if err = be.mkcol(ctx, path.Dir(dir)); err != nil {
+			return err
+		}
+
+		return be.mkcol(ctx, dir)
+	}
+
+	return errors.Errorf("unable to create collection %v: %v", dir, resp.Status)
+}
+
+// Save stores data in the backend at the handle.
+func (be *Backend) Save(ctx context.Context, h restic.Handle, rd restic.RewindReader) error {
+	debug.Log("Save %v", h)
+	if err := h.Valid(); err != nil {
+		return err
+	}
+
+	status, err := be.put(ctx, h, rd)
+	if err != nil {
+		return err
+	}
+
+	if status == http.StatusConflict {
+		// the parent collection is missing, create it and try again
+		if err = be.mkcol(ctx, path.Dir(be.Filename(h))); err != nil {
+			return err
+		}
+
+		status, err = be.put(ctx, h, rd)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch status {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	}
+
+	return errors.Errorf("server response unexpected: %v", http.StatusText(status))
+}
+
+func (be *Backend) put(ctx context.Context, h restic.Handle, rd restic.RewindReader) (int, error) {
+	if err := rd.Rewind(); err != nil {
+		return 0, err
+	}
+
+	// make sure that the client cannot close the reader by wrapping it
+	req, err := http.NewRequest(http.MethodPut, be.FileURL(be.Filename(h)), ioutil.NopCloser(rd))
+	if err != nil {
+		return 0, errors.Wrap(err, "NewRequest")
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	// allow the transport to send the data again for authentication
+	req.GetBody = func() (io.ReadCloser, error) {
+		if err := rd.Rewind(); err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(rd), nil
+	}
+
+	// explicitly set the content length, this prevents chunked encoding and
+	// let's the server know what's coming.
+	req.ContentLength = rd.Length()
+
+	resp, err := be.Do(ctx, req)
+	if err != nil {
+		return 0, errors.Wrap(err, "PUT")
+	}
+
+	if err = httpfile.Drain(resp); err != nil {
+		return 0, errors.Wrap(err, "Close")
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Remove removes the blob with the given name and type.
+func (be *Backend) Remove(ctx context.Context, h restic.Handle) error {
+	debug.Log("Remove %v", h)
+	if err := h.Valid(); err != nil {
+		return err
+	}
+
+	return be.delete(ctx, be.Filename(h), h)
+}
+
+func (be *Backend) delete(ctx context.Context, p string, h restic.Handle) error {
+	req, err := http.NewRequest(http.MethodDelete, be.FileURL(p), nil)
+	if err != nil {
+		return errors.Wrap(err, "NewRequest")
+	}
+
+	resp, err := be.Do(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "DELETE")
+	}
+
+	if err = httpfile.Drain(resp); err != nil {
+		return errors.Wrap(err, "Close")
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return httpfile.ErrIsNotExist{Handle: h}
+	}
+
+	return errors.Errorf("%v not removed, server response: %v (%v)", p, resp.Status, resp.StatusCode)
+}
+
+// propfindBody requests the properties needed to list a collection.
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:resourcetype/><D:getcontentlength/></D:prop></D:propfind>`
+
+// multistatus is the response to a PROPFIND request.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64 `xml:"getcontentlength"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// entry is an item in a collection on the server.
+type entry struct {
+	Name string
+	Size int64
+	Dir  bool
+}
+
+// parseMultistatus extracts the entries from the response to a PROPFIND
+// request.
+func parseMultistatus(rd io.Reader) ([]entry, error) {
+	var ms multistatus
+	if err := xml.NewDecoder(rd).Decode(&ms); err != nil {
+		return nil, errors.Wrap(err, "Decode")
+	}
+
+	entries := make([]entry, 0, len(ms.Responses))
+	for _, r := range ms.Responses {
+		u, err := url.Parse(r.Href)
+		if err != nil {
+			return nil, errors.Wrap(err, "url.Parse")
+		}
+
+		e := entry{Name: path.Base(strings.TrimSuffix(u.Path, "/"))}
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+
+			if ps.Prop.ResourceType.Collection != nil {
+				e.Dir = true
+			}
+			e.Size = ps.Prop.ContentLength
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// readDir returns the contents of the collection dir, including the entry for
+// dir itself. A collection that does not exist is treated as empty.
+func (be *Backend) readDir(ctx context.Context, dir string) ([]entry, error) {
+	req, err := http.NewRequest("PROPFIND", be.FileURL(dir+"/"), strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "NewRequest")
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := be.Do(ctx, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "PROPFIND")
+	}
+
+	defer func() {
+		_ = httpfile.Drain(resp)
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		debug.Log("ignoring non-existing collection %v", dir)
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, errors.Errorf("List failed, server response: %v (%v)", resp.Status, resp.StatusCode)
+	}
+
+	return parseMultistatus(resp.Body)
+}
+
+// List runs fn for each file in the backend which has the type t. When an
+// error occurs (or fn returns an error), List stops and returns it.
+func (be *Backend) List(ctx context.Context, t restic.FileType, fn func(restic.FileInfo) error) error {
+	debug.Log("List %v", t)
+
+	basedir, subdirs := be.Basedir(t)
+	if !subdirs {
+		return be.listDir(ctx, basedir, fn)
+	}
+
+	entries, err := be.readDir(ctx, basedir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		// skip files and the entry for the base directory itself
+		if !e.Dir || e.Name == path.Base(basedir) {
+			continue
+		}
+
+		err = be.listDir(ctx, path.Join(basedir, e.Name), fn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+// listDir runs fn for each file in the collection dir.
+func (be *Backend) listDir(ctx context.Context, dir string, fn func(restic.FileInfo) error) error {
+	entries, err := be.readDir(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if e.Dir {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		err = fn(restic.FileInfo{Name: e.Name, Size: e.Size})
+		if err != nil {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Close does nothing, all connections are managed by the HTTP client.
+func (be *Backend) Close() error {
+	return nil
+}
+
+// Delete removes all data in the backend.
+func (be *Backend) Delete(ctx context.Context) error {
+	err := be.delete(ctx, strings.TrimSuffix(be.URL().Path, "/")+"/", restic.Handle{})
+	if err != nil && be.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,254 @@
+package webdav_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/backend/webdav"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// davServer implements the subset of WebDAV used by the backend on top of a
+// local directory.
+type davServer struct {
+	dir string
+}
+
+func (s davServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Join(s.dir, filepath.FromSlash(path.Clean(r.URL.Path)))
+	fi, err := os.Stat(name)
+	exists := err == nil
+	_, err = os.Stat(filepath.Dir(name))
+	parentExists := err == nil
+
+	switch r.Method {
+	case "MKCOL":
+		switch {
+		case exists:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case !parentExists:
+			w.WriteHeader(http.StatusConflict)
+		case os.Mkdir(name, 0700) != nil:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusCreated)
+		}
+
+	case http.MethodPut:
+		if !parentExists {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		f, err := os.Create(name)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, err = io.Copy(f, r.Body)
+		if err != nil || f.Close() != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+
+	case http.MethodGet, http.MethodHead:
+		if !exists || fi.IsDir() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		f, err := os.Open(name)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		http.ServeContent(w, r, name, fi.ModTime(), f)
+
+	case http.MethodDelete:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if os.RemoveAll(name) != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case "PROPFIND":
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		entries := []os.FileInfo{fi}
+		if fi.IsDir() {
+			f, err := os.Open(name)
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			list, err := f.Readdir(-1)
+			_ = f.Close()
+			if err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			entries = append(entries, list...)
+		}
+
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:">`)
+		for i, e := range entries {
+			href := r.URL.Path
+			if i > 0 {
+				href = path.Join(href, e.Name())
+			}
+
+			resourceType := ""
+			if e.IsDir() {
+				resourceType = "<D:collection/>"
+				href += "/"
+			}
+
+			u := url.URL{Path: href}
+			fmt.Fprintf(w, `<D:response><D:href>%s</D:href><D:propstat><D:prop>`+
+				`<D:resourcetype>%s</D:resourcetype><D:getcontentlength>%d</D:getcontentlength>`+
+				`</D:prop><D:status>HTTP/1.1 200 OK</D:status></D:propstat></D:response>`,
+				u.EscapedPath(), resourceType, e.Size())
+		}
+		fmt.Fprintf(w, `</D:multistatus>`)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestSuite(t testing.TB, u *url.URL, minimalData bool) *test.Suite {
+	tr, err := backend.Transport(backend.TransportOptions{})
+	if err != nil {
+		t.Fatalf("cannot create transport for tests: %v", err)
+	}
+
+	return &test.Suite{
+		MinimalData: minimalData,
+
+		// NewConfig returns a config for a new temporary backend that will be used in tests.
+		NewConfig: func() (interface{}, error) {
+			cfg := webdav.NewConfig()
+			cfg.URL = u
+			return cfg, nil
+		},
+
+		// CreateFn is a function that creates a temporary repository for the tests.
+		Create: func(config interface{}) (restic.Backend, error) {
+			cfg := config.(webdav.Config)
+			return webdav.Create(cfg, tr)
+		},
+
+		// OpenFn is a function that opens a previously created temporary repository.
+		Open: func(config interface{}) (restic.Backend, error) {
+			cfg := config.(webdav.Config)
+			return webdav.Open(cfg, tr)
+		},
+
+		// CleanupFn removes data created during the tests.
+		Cleanup: func(config interface{}) error {
+			return nil
+		},
+	}
+}
+
+func TestBackendWebDAV(t *testing.T) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	srv := httptest.NewServer(davServer{dir: dir})
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/dav/restic-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestSuite(t, u, false).RunTests(t)
+}
+
+func TestBackendWebDAVExternalServer(t *testing.T) {
+	repostr := os.Getenv("RESTIC_TEST_WEBDAV_REPOSITORY")
+	if repostr == "" {
+		t.Skipf("environment variable %v not set", "RESTIC_TEST_WEBDAV_REPOSITORY")
+	}
+
+	cfg, err := webdav.ParseConfig(repostr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestSuite(t, cfg.(webdav.Config).URL, true).RunTests(t)
+}
+
+func BenchmarkBackendWebDAV(t *testing.B) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	srv := httptest.NewServer(davServer{dir: dir})
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL + "/restic-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newTestSuite(t, u, false).RunBenchmarks(t)
+}
+
+func TestLoadIgnoredRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the server always returns the complete file
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("0123456789"))
+	}))
+	defer srv.Close()
+
+	cfg, err := webdav.ParseConfig("webdav:" + srv.URL + "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	be, err := webdav.Open(cfg.(webdav.Config), http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := restic.Handle{Type: restic.DataFile, Name: restic.NewRandomID().String()}
+	buf, err := backend.LoadAll(ctx, nil, be, h)
+	rtest.OK(t, err)
+	rtest.Equals(t, "0123456789", string(buf))
+
+	var data []byte
+	err = be.Load(ctx, h, 4, 3, func(rd io.Reader) error {
+		var ierr error
+		data, ierr = ioutil.ReadAll(rd)
+		return ierr
+	})
+	rtest.OK(t, err)
+	rtest.Equals(t, "3456", string(data))
+}
@@ -0,0 +1,145 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// vssTimeout is the time in seconds after which creating a VSS snapshot is
+// aborted.
+const vssTimeout = 120
+
+// ErrorHandler is called to report an error for an item.
+type ErrorHandler func(item string, err error) error
+
+// MessageHandler is called to report a message.
+type MessageHandler func(msg string, args ...interface{})
+
+// LocalVss is the local file system, but files are read from a VSS snapshot
+// of their volume on Windows. The snapshot of a volume is created when a path
+// on it is accessed for the first time. When creating the snapshot fails, the
+// files on the volume are read from the original volume.
+type LocalVss struct {
+	FS
+
+	msgError   ErrorHandler
+	msgMessage MessageHandler
+
+	mutex           sync.Mutex
+	snapshots       map[string]VssSnapshot
+	failedSnapshots map[string]struct{}
+}
+
+// statically ensure that LocalVss implements FS.
+var _ FS = &LocalVss{}
+
+// NewLocalVss returns a new LocalVss, which reports errors and messages via
+// the handlers. DeleteSnapshots must be called when the file system is not
+// needed any more.
+func NewLocalVss(msgError ErrorHandler, msgMessage MessageHandler) *LocalVss {
+	return &LocalVss{
+		FS:              Local{},
+		msgError:        msgError,
+		msgMessage:      msgMessage,
+		snapshots:       make(map[string]VssSnapshot),
+		failedSnapshots: make(map[string]struct{}),
+	}
+}
+
+// DeleteSnapshots deletes all snapshots that were created.
+func (fs *LocalVss) DeleteSnapshots() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var firstErr error
+	for volume, snapshot := range fs.snapshots {
+		err := snapshot.Delete()
+		if err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "delete snapshot for %v", volume)
+		}
+		delete(fs.snapshots, volume)
+	}
+
+	return firstErr
+}
+
+// Open wraps the Open method of the underlying file system.
+func (fs *LocalVss) Open(name string) (File, error) {
+	return fs.FS.Open(fs.snapshotPath(name))
+}
+
+// OpenFile wraps the OpenFile method of the underlying file system.
+func (fs *LocalVss) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return fs.FS.OpenFile(fs.snapshotPath(name), flag, perm)
+}
+
+// Stat wraps the Stat method of the underlying file system.
+func (fs *LocalVss) Stat(name string) (os.FileInfo, error) {
+	return fs.FS.Stat(fs.snapshotPath(name))
+}
+
+// Lstat wraps the Lstat method of the underlying file system.
+func (fs *LocalVss) Lstat(name string) (os.FileInfo, error) {
+	return fs.FS.Lstat(fs.snapshotPath(name))
+}
+
+// snapshot returns the snapshot of the volume, which is created if no
+// snapshot was attempted for the volume yet.
+func (fs *LocalVss) snapshot(volume string) (VssSnapshot, bool) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if snapshot, ok := fs.snapshots[volume]; ok {
+		return snapshot, true
+	}
+
+	if _, ok := fs.failedSnapshots[volume]; ok {
+		return VssSnapshot{}, false
+	}
+
+	vssVolume := volume + `\`
+	fs.msgMessage("creating VSS snapshot for %v\n", vssVolume)
+
+	snapshot, err := NewVssSnapshot(vssVolume, vssTimeout)
+	if err != nil {
+		_ = fs.msgError(vssVolume, errors.Errorf("unable to create snapshot for %v, reading from the volume: %v", vssVolume, err))
+		fs.failedSnapshots[volume] = struct{}{}
+		return VssSnapshot{}, false
+	}
+
+	fs.msgMessage("successfully created snapshot for %v\n", vssVolume)
+	fs.snapshots[volume] = snapshot
+	return snapshot, true
+}
+
+// snapshotPath returns the path of name in the snapshot of its volume, or name
+// itself if no snapshot is available.
+func (fs *LocalVss) snapshotPath(name string) string {
+	if runtime.GOOS != "windows" {
+		return name
+	}
+
+	// network shares are not supported by VSS
+	p := fixpath(name)
+	if strings.HasPrefix(p, `\\?\UNC\`) {
+		return name
+	}
+
+	p = strings.TrimPrefix(p, `\\?\`)
+	volume := filepath.VolumeName(p)
+	if len(volume) != 2 || volume[1] != ':' {
+		return name
+	}
+
+	snapshot, ok := fs.snapshot(strings.ToUpper(volume))
+	if !ok {
+		return name
+	}
+
+	return snapshot.GetSnapshotDeviceObject() + p[len(volume):]
+}
@@ -0,0 +1,32 @@
+// +build !windows
+
+package fs
+
+import (
+	"github.com/restic/restic/internal/errors"
+)
+
+// VssSnapshot is a VSS snapshot of a volume, which is only supported on
+// Windows.
+type VssSnapshot struct{}
+
+// HasSufficientPrivilegesForVSS returns nil if the user is allowed to use VSS.
+func HasSufficientPrivilegesForVSS() error {
+	return errors.New("VSS snapshots are only supported on windows")
+}
+
+// NewVssSnapshot creates a new VSS snapshot of the volume.
+func NewVssSnapshot(volume string, timeoutInSeconds uint) (VssSnapshot, error) {
+	return VssSnapshot{}, errors.New("VSS snapshots are only supported on windows")
+}
+
+// Delete deletes the snapshot.
+func (p *VssSnapshot) Delete() error {
+	return nil
+}
+
+// GetSnapshotDeviceObject returns the path of the snapshot's device object,
+// e.g. \\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy1.
+func (p *VssSnapshot) GetSnapshotDeviceObject() string {
+	return ""
+}
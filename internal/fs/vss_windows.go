@@ -0,0 +1,525 @@
+// +build windows
+
+package fs
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// hresult is the return value of the COM functions and methods.
+type hresult uint32
+
+const (
+	sOK                                 hresult = 0x00000000
+	sFalse                              hresult = 0x00000001
+	eAccessDenied                       hresult = 0x80070005
+	eOutOfMemory                        hresult = 0x8007000E
+	eInvalidArg                         hresult = 0x80070057
+	rpcEChangedMode                     hresult = 0x80010106
+	vssEBadState                        hresult = 0x80042301
+	vssEObjectNotFound                  hresult = 0x80042308
+	vssEVolumeNotSupported              hresult = 0x8004230C
+	vssEUnexpected                      hresult = 0x8004230F
+	vssESnapshotSetInProgress           hresult = 0x80042316
+	vssEMaximumNumberOfSnapshotsReached hresult = 0x80042317
+	vssSAsyncPending                    hresult = 0x00042309
+	vssSAsyncFinished                   hresult = 0x0004230A
+	vssSAsyncCancelled                  hresult = 0x0004230B
+)
+
+var hresultText = map[hresult]string{
+	eAccessDenied:                       "access denied, VSS requires administrative privileges",
+	eOutOfMemory:                        "out of memory",
+	eInvalidArg:                         "invalid argument",
+	vssEBadState:                        "the backup components object is not initialized",
+	vssEObjectNotFound:                  "the volume or snapshot was not found",
+	vssEVolumeNotSupported:              "creating snapshots is not supported for the volume",
+	vssEUnexpected:                      "unexpected error, see the windows event log for details",
+	vssESnapshotSetInProgress:           "the creation of another snapshot is in progress",
+	vssEMaximumNumberOfSnapshotsReached: "the maximum number of snapshots has been reached",
+	vssSAsyncCancelled:                  "the operation was cancelled",
+}
+
+func (h hresult) String() string {
+	if text, ok := hresultText[h]; ok {
+		return text
+	}
+	return fmt.Sprintf("HRESULT %#08x", uint32(h))
+}
+
+// vssError is returned when a VSS function fails.
+type vssError struct {
+	text string
+	hr   hresult
+}
+
+func newVssError(text string, hr hresult) error {
+	return &vssError{text: text, hr: hr}
+}
+
+func (e *vssError) Error() string {
+	return fmt.Sprintf("VSS error: %s: %v", e.text, e.hr)
+}
+
+const (
+	// vssCtxBackup creates non-persistent snapshots, which are deleted when
+	// the backup components object is released.
+	vssCtxBackup = 0
+
+	// vssBtCopy is a backup which does not change the backup history of the
+	// files, so that it does not interfere with other backup programs.
+	vssBtCopy = 5
+
+	vssObjectSnapshot = 3
+
+	coinitMultithreaded = 0
+)
+
+// is64Bit is true if VSS_ID arguments passed by value are passed as a
+// pointer to a copy, which is the case for the 64 bit calling conventions.
+const is64Bit = unsafe.Sizeof(uintptr(0)) == 8
+
+var (
+	vssapi                        = windows.NewLazySystemDLL("vssapi.dll")
+	procCreateVssBackupComponents = vssapi.NewProc("CreateVssBackupComponentsInternal")
+	procVssFreeSnapshotProperties = vssapi.NewProc("VssFreeSnapshotPropertiesInternal")
+
+	ole32              = windows.NewLazySystemDLL("ole32.dll")
+	procCoInitializeEx = ole32.NewProc("CoInitializeEx")
+	procCoUninitialize = ole32.NewProc("CoUninitialize")
+)
+
+// initializeCOM initializes COM for the current thread, which must be locked
+// by the caller. The returned function must be called to uninitialize it.
+func initializeCOM() (func(), error) {
+	if err := procCoInitializeEx.Find(); err != nil {
+		return nil, err
+	}
+
+	r, _, _ := procCoInitializeEx.Call(0, coinitMultithreaded)
+	switch hresult(r) {
+	case sOK, sFalse:
+		return func() {
+			_, _, _ = procCoUninitialize.Call()
+		}, nil
+	case rpcEChangedMode:
+		// COM was already initialized for another apartment, which is
+		// also fine for the VSS API
+		return func() {}, nil
+	default:
+		return nil, newVssError("CoInitializeEx", hresult(r))
+	}
+}
+
+// vssSnapshotProperties is the VSS_SNAPSHOT_PROP structure.
+type vssSnapshotProperties struct {
+	snapshotID           windows.GUID
+	snapshotSetID        windows.GUID
+	snapshotsCount       int32
+	snapshotDeviceObject *uint16
+	originalVolumeName   *uint16
+	originatingMachine   *uint16
+	serviceMachine       *uint16
+	exposedName          *uint16
+	exposedPath          *uint16
+	providerID           windows.GUID
+	snapshotAttributes   int32
+	creationTimestamp    int64
+	status               int32
+}
+
+// iVssAsync is the COM interface IVssAsync, which is returned by the
+// asynchronous methods of IVssBackupComponents.
+type iVssAsync struct {
+	vtbl *iVssAsyncVtbl
+}
+
+type iVssAsyncVtbl struct {
+	queryInterface uintptr
+	addRef         uintptr
+	release        uintptr
+	cancel         uintptr
+	wait           uintptr
+	queryStatus    uintptr
+}
+
+func (a *iVssAsync) release() {
+	_, _, _ = syscall.Syscall(a.vtbl.release, 1, uintptr(unsafe.Pointer(a)), 0, 0)
+}
+
+// wait waits until the asynchronous operation has finished and releases a.
+// The operation is cancelled when it does not finish within the timeout.
+func (a *iVssAsync) wait(name string, timeout time.Duration) error {
+	defer a.release()
+
+	ms := timeout / time.Millisecond
+	if ms < 0 {
+		ms = 0
+	}
+
+	r, _, _ := syscall.Syscall(a.vtbl.wait, 2, uintptr(unsafe.Pointer(a)), uintptr(ms), 0)
+	if hresult(r) != sOK {
+		return newVssError(name, hresult(r))
+	}
+
+	var status hresult
+	r, _, _ = syscall.Syscall(a.vtbl.queryStatus, 3, uintptr(unsafe.Pointer(a)), uintptr(unsafe.Pointer(&status)), 0)
+	if hresult(r) != sOK {
+		return newVssError(name, hresult(r))
+	}
+
+	switch status {
+	case vssSAsyncFinished:
+		return nil
+	case vssSAsyncPending:
+		_, _, _ = syscall.Syscall(a.vtbl.cancel, 1, uintptr(unsafe.Pointer(a)), 0, 0)
+		return errors.Errorf("VSS error: %s: timeout after %v", name, timeout)
+	default:
+		return newVssError(name, status)
+	}
+}
+
+// iVssBackupComponents is the COM interface IVssBackupComponents.
+type iVssBackupComponents struct {
+	vtbl *iVssBackupComponentsVtbl
+}
+
+// iVssBackupComponentsVtbl lists the methods of IVssBackupComponents in the
+// order of their declaration in vsbackup.h.
+type iVssBackupComponentsVtbl struct {
+	queryInterface                uintptr
+	addRef                        uintptr
+	release                       uintptr
+	getWriterComponentsCount      uintptr
+	getWriterComponents           uintptr
+	initializeForBackup           uintptr
+	setBackupState                uintptr
+	initializeForRestore          uintptr
+	setRestoreState               uintptr
+	gatherWriterMetadata          uintptr
+	getWriterMetadataCount        uintptr
+	getWriterMetadata             uintptr
+	freeWriterMetadata            uintptr
+	addComponent                  uintptr
+	prepareForBackup              uintptr
+	abortBackup                   uintptr
+	gatherWriterStatus            uintptr
+	getWriterStatusCount          uintptr
+	freeWriterStatus              uintptr
+	getWriterStatus               uintptr
+	setBackupSucceeded            uintptr
+	setBackupOptions              uintptr
+	setSelectedForRestore         uintptr
+	setRestoreOptions             uintptr
+	setAdditionalRestores         uintptr
+	setPreviousBackupStamp        uintptr
+	saveAsXML                     uintptr
+	backupComplete                uintptr
+	addAlternativeLocationMapping uintptr
+	addRestoreSubcomponent        uintptr
+	setFileRestoreStatus          uintptr
+	addNewTarget                  uintptr
+	setRangesFilePath             uintptr
+	preRestore                    uintptr
+	postRestore                   uintptr
+	setContext                    uintptr
+	startSnapshotSet              uintptr
+	addToSnapshotSet              uintptr
+	doSnapshotSet                 uintptr
+	deleteSnapshots               uintptr
+	importSnapshots               uintptr
+	breakSnapshotSet              uintptr
+	getSnapshotProperties         uintptr
+	query                         uintptr
+	isVolumeSupported             uintptr
+	disableWriterClasses          uintptr
+	enableWriterClasses           uintptr
+	disableWriterInstances        uintptr
+	exposeSnapshot                uintptr
+	revertToSnapshot              uintptr
+	queryRevertStatus             uintptr
+}
+
+// createVssBackupComponents returns a new backup components object.
+func createVssBackupComponents() (*iVssBackupComponents, error) {
+	if err := procCreateVssBackupComponents.Find(); err != nil {
+		return nil, errors.Wrap(err, "VSS is not available")
+	}
+
+	var vss *iVssBackupComponents
+	r, _, _ := procCreateVssBackupComponents.Call(uintptr(unsafe.Pointer(&vss)))
+	if hresult(r) != sOK {
+		return nil, newVssError("CreateVssBackupComponents", hresult(r))
+	}
+
+	return vss, nil
+}
+
+func (vss *iVssBackupComponents) release() {
+	_, _, _ = syscall.Syscall(vss.vtbl.release, 1, uintptr(unsafe.Pointer(vss)), 0, 0)
+}
+
+func (vss *iVssBackupComponents) initializeForBackup() hresult {
+	r, _, _ := syscall.Syscall(vss.vtbl.initializeForBackup, 2, uintptr(unsafe.Pointer(vss)), 0, 0)
+	return hresult(r)
+}
+
+func (vss *iVssBackupComponents) setContext(context int32) hresult {
+	r, _, _ := syscall.Syscall(vss.vtbl.setContext, 2, uintptr(unsafe.Pointer(vss)), uintptr(context), 0)
+	return hresult(r)
+}
+
+func (vss *iVssBackupComponents) setBackupState(backupType int32) hresult {
+	r, _, _ := syscall.Syscall6(vss.vtbl.setBackupState, 5, uintptr(unsafe.Pointer(vss)),
+		0, 0, uintptr(backupType), 0, 0)
+	return hresult(r)
+}
+
+// callAsync calls one of the methods which start an asynchronous operation.
+func (vss *iVssBackupComponents) callAsync(method uintptr) (*iVssAsync, hresult) {
+	var async *iVssAsync
+	r, _, _ := syscall.Syscall(method, 2, uintptr(unsafe.Pointer(vss)), uintptr(unsafe.Pointer(&async)), 0)
+	return async, hresult(r)
+}
+
+func (vss *iVssBackupComponents) abortBackup() hresult {
+	r, _, _ := syscall.Syscall(vss.vtbl.abortBackup, 1, uintptr(unsafe.Pointer(vss)), 0, 0)
+	return hresult(r)
+}
+
+func (vss *iVssBackupComponents) startSnapshotSet(snapshotSetID *windows.GUID) hresult {
+	r, _, _ := syscall.Syscall(vss.vtbl.startSnapshotSet, 2, uintptr(unsafe.Pointer(vss)), uintptr(unsafe.Pointer(snapshotSetID)), 0)
+	return hresult(r)
+}
+
+// addToSnapshotSet adds the volume to the snapshot set, using the default
+// provider.
+func (vss *iVssBackupComponents) addToSnapshotSet(volume *uint16, snapshotID *windows.GUID) hresult {
+	var provider windows.GUID
+	var r uintptr
+	if is64Bit {
+		r, _, _ = syscall.Syscall6(vss.vtbl.addToSnapshotSet, 4, uintptr(unsafe.Pointer(vss)),
+			uintptr(unsafe.Pointer(volume)), uintptr(unsafe.Pointer(&provider)), uintptr(unsafe.Pointer(snapshotID)), 0, 0)
+	} else {
+		p := (*[4]uint32)(unsafe.Pointer(&provider))
+		r, _, _ = syscall.Syscall9(vss.vtbl.addToSnapshotSet, 7, uintptr(unsafe.Pointer(vss)),
+			uintptr(unsafe.Pointer(volume)), uintptr(p[0]), uintptr(p[1]), uintptr(p[2]), uintptr(p[3]),
+			uintptr(unsafe.Pointer(snapshotID)), 0, 0)
+	}
+	return hresult(r)
+}
+
+func (vss *iVssBackupComponents) getSnapshotProperties(snapshotID windows.GUID, props *vssSnapshotProperties) hresult {
+	var r uintptr
+	if is64Bit {
+		r, _, _ = syscall.Syscall(vss.vtbl.getSnapshotProperties, 3, uintptr(unsafe.Pointer(vss)),
+			uintptr(unsafe.Pointer(&snapshotID)), uintptr(unsafe.Pointer(props)))
+	} else {
+		id := (*[4]uint32)(unsafe.Pointer(&snapshotID))
+		r, _, _ = syscall.Syscall6(vss.vtbl.getSnapshotProperties, 6, uintptr(unsafe.Pointer(vss)),
+			uintptr(id[0]), uintptr(id[1]), uintptr(id[2]), uintptr(id[3]), uintptr(unsafe.Pointer(props)))
+	}
+	return hresult(r)
+}
+
+func (vss *iVssBackupComponents) deleteSnapshot(snapshotID windows.GUID) hresult {
+	var deleted int32
+	var nonDeleted windows.GUID
+	var r uintptr
+	if is64Bit {
+		r, _, _ = syscall.Syscall6(vss.vtbl.deleteSnapshots, 6, uintptr(unsafe.Pointer(vss)),
+			uintptr(unsafe.Pointer(&snapshotID)), vssObjectSnapshot, 1,
+			uintptr(unsafe.Pointer(&deleted)), uintptr(unsafe.Pointer(&nonDeleted)))
+	} else {
+		id := (*[4]uint32)(unsafe.Pointer(&snapshotID))
+		r, _, _ = syscall.Syscall9(vss.vtbl.deleteSnapshots, 9, uintptr(unsafe.Pointer(vss)),
+			uintptr(id[0]), uintptr(id[1]), uintptr(id[2]), uintptr(id[3]), vssObjectSnapshot, 1,
+			uintptr(unsafe.Pointer(&deleted)), uintptr(unsafe.Pointer(&nonDeleted)))
+	}
+	return hresult(r)
+}
+
+// utf16PtrToString returns the string for the NUL terminated UTF-16 string p.
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+
+	n := 0
+	for ptr := unsafe.Pointer(p); *(*uint16)(ptr) != 0; n++ {
+		ptr = unsafe.Pointer(uintptr(ptr) + unsafe.Sizeof(*p))
+	}
+
+	return windows.UTF16ToString((*[1 << 29]uint16)(unsafe.Pointer(p))[:n:n])
+}
+
+// VssSnapshot is a VSS snapshot of a volume.
+type VssSnapshot struct {
+	vss          *iVssBackupComponents
+	snapshotID   windows.GUID
+	deviceObject string
+	timeout      time.Duration
+}
+
+// HasSufficientPrivilegesForVSS returns nil if the user is allowed to use VSS.
+func HasSufficientPrivilegesForVSS() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	uninitialize, err := initializeCOM()
+	if err != nil {
+		return err
+	}
+	defer uninitialize()
+
+	vss, err := createVssBackupComponents()
+	if err != nil {
+		if e, ok := err.(*vssError); ok && e.hr == eAccessDenied {
+			return errors.New("VSS snapshots require administrative privileges")
+		}
+		return err
+	}
+	vss.release()
+
+	return nil
+}
+
+// NewVssSnapshot creates a new VSS snapshot of the volume, e.g. `C:\`. It fails
+// when the snapshot is not completed within the timeout.
+func NewVssSnapshot(volume string, timeoutInSeconds uint) (VssSnapshot, error) {
+	timeout := time.Duration(timeoutInSeconds) * time.Second
+	deadline := time.Now().Add(timeout)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// COM stays initialized for this thread so that the multithreaded
+	// apartment the backup components object lives in is not torn down
+	// before the snapshot is deleted
+	if _, err := initializeCOM(); err != nil {
+		return VssSnapshot{}, err
+	}
+
+	volumeName, err := windows.UTF16PtrFromString(volume)
+	if err != nil {
+		return VssSnapshot{}, err
+	}
+
+	vss, err := createVssBackupComponents()
+	if err != nil {
+		return VssSnapshot{}, err
+	}
+
+	success := false
+	defer func() {
+		if !success {
+			// snapshots of the context vssCtxBackup are deleted when
+			// the object is released
+			_ = vss.abortBackup()
+			vss.release()
+		}
+	}()
+
+	if hr := vss.initializeForBackup(); hr != sOK {
+		return VssSnapshot{}, newVssError("InitializeForBackup", hr)
+	}
+
+	if hr := vss.setContext(vssCtxBackup); hr != sOK {
+		return VssSnapshot{}, newVssError("SetContext", hr)
+	}
+
+	if hr := vss.setBackupState(vssBtCopy); hr != sOK {
+		return VssSnapshot{}, newVssError("SetBackupState", hr)
+	}
+
+	// runAsync runs the asynchronous method and waits until it has finished
+	runAsync := func(name string, method uintptr) error {
+		async, hr := vss.callAsync(method)
+		if hr != sOK {
+			return newVssError(name, hr)
+		}
+		return async.wait(name, time.Until(deadline))
+	}
+
+	if err = runAsync("GatherWriterMetadata", vss.vtbl.gatherWriterMetadata); err != nil {
+		return VssSnapshot{}, err
+	}
+
+	var snapshotSetID, snapshotID windows.GUID
+	if hr := vss.startSnapshotSet(&snapshotSetID); hr != sOK {
+		return VssSnapshot{}, newVssError("StartSnapshotSet", hr)
+	}
+
+	if hr := vss.addToSnapshotSet(volumeName, &snapshotID); hr != sOK {
+		return VssSnapshot{}, newVssError(fmt.Sprintf("AddToSnapshotSet(%v)", volume), hr)
+	}
+
+	if err = runAsync("PrepareForBackup", vss.vtbl.prepareForBackup); err != nil {
+		return VssSnapshot{}, err
+	}
+
+	if err = runAsync("DoSnapshotSet", vss.vtbl.doSnapshotSet); err != nil {
+		return VssSnapshot{}, err
+	}
+
+	var props vssSnapshotProperties
+	if hr := vss.getSnapshotProperties(snapshotID, &props); hr != sOK {
+		return VssSnapshot{}, newVssError("GetSnapshotProperties", hr)
+	}
+	deviceObject := utf16PtrToString(props.snapshotDeviceObject)
+	_, _, _ = procVssFreeSnapshotProperties.Call(uintptr(unsafe.Pointer(&props)))
+
+	success = true
+	return VssSnapshot{
+		vss:          vss,
+		snapshotID:   snapshotID,
+		deviceObject: deviceObject,
+		timeout:      timeout,
+	}, nil
+}
+
+// Delete completes the backup and deletes the snapshot.
+func (p *VssSnapshot) Delete() error {
+	if p.vss == nil {
+		return nil
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	uninitialize, err := initializeCOM()
+	if err != nil {
+		return err
+	}
+	defer uninitialize()
+
+	async, hr := p.vss.callAsync(p.vss.vtbl.backupComplete)
+	if hr != sOK {
+		err = newVssError("BackupComplete", hr)
+	} else {
+		err = async.wait("BackupComplete", p.timeout)
+	}
+
+	if hr := p.vss.deleteSnapshot(p.snapshotID); hr != sOK && hr != vssEObjectNotFound && err == nil {
+		err = newVssError("DeleteSnapshots", hr)
+	}
+
+	p.vss.release()
+	p.vss = nil
+
+	return err
+}
+
+// GetSnapshotDeviceObject returns the path of the snapshot's device object,
+// e.g. \\?\GLOBALROOT\Device\HarddiskVolumeShadowCopy1.
+func (p *VssSnapshot) GetSnapshotDeviceObject() string {
+	return p.deviceObject
+}
@@ -20,6 +20,7 @@ type Backend struct {
 	TestFn       func(ctx context.Context, h restic.Handle) (bool, error)
 	DeleteFn     func(ctx context.Context) error
 	LocationFn   func() string
+	CapsFn       func() restic.Capabilities
 }
 
 // NewBackend returns new mock Backend instance
@@ -132,5 +133,14 @@ func (m *Backend) Delete(ctx context.Context) error {
 	return m.DeleteFn(ctx)
 }
 
+// Capabilities returns the properties of the backend.
+func (m *Backend) Capabilities() restic.Capabilities {
+	if m.CapsFn == nil {
+		return restic.Capabilities{}
+	}
+
+	return m.CapsFn()
+}
+
 // Make sure that Backend implements the backend interface.
 var _ restic.Backend = &Backend{}
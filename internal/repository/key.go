@@ -55,10 +55,37 @@ var (
 	KDFMemory = 60
 )
 
+// KDFLimits bounds the resources the KDF of a new key may use. The KDF
+// parameters are calibrated to stay within these limits.
+type KDFLimits struct {
+	// Timeout is the maximum runtime for the KDF.
+	Timeout time.Duration
+
+	// Memory is the maximum memory in MiB the KDF is allowed to use.
+	Memory int
+}
+
+// DefaultKDFLimits returns the limits set by KDFTimeout and KDFMemory.
+func DefaultKDFLimits() KDFLimits {
+	return KDFLimits{Timeout: KDFTimeout, Memory: KDFMemory}
+}
+
 // createMasterKey creates a new master key in the given backend and encrypts
-// it with the password.
-func createMasterKey(s *Repository, password string) (*Key, error) {
-	return AddKey(context.TODO(), s, password, nil)
+// it with the password. The KDF parameters are calibrated within limits,
+// unless Params is set.
+func createMasterKey(ctx context.Context, s *Repository, password string, limits KDFLimits) (*Key, error) {
+	params := Params
+	if params == nil {
+		p, err := crypto.Calibrate(limits.Timeout, limits.Memory)
+		if err != nil {
+			return nil, errors.Wrap(err, "Calibrate")
+		}
+
+		params = &p
+		debug.Log("calibrated KDF parameters are %v", p)
+	}
+
+	return addKey(ctx, s, password, nil, *params)
 }
 
 // OpenKey tries do decrypt the key specified by name with the given password.
@@ -211,13 +238,18 @@ func AddKey(ctx context.Context, s *Repository, password string, template *crypt
 		debug.Log("calibrated KDF parameters are %v", p)
 	}
 
+	return addKey(ctx, s, password, template, *Params)
+}
+
+// addKey adds a new key with the KDF parameters params to the repository.
+func addKey(ctx context.Context, s *Repository, password string, template *crypto.Key, params crypto.Params) (*Key, error) {
 	// fill meta data about key
 	newkey := &Key{
 		Created: time.Now(),
 		KDF:     "scrypt",
-		N:       Params.N,
-		R:       Params.R,
-		P:       Params.P,
+		N:       params.N,
+		R:       params.R,
+		P:       params.P,
 	}
 
 	hn, err := os.Hostname()
@@ -237,7 +269,7 @@ func AddKey(ctx context.Context, s *Repository, password string, template *crypt
 	}
 
 	// call KDF to derive user key
-	newkey.user, err = crypto.KDF(*Params, newkey.Salt, password)
+	newkey.user, err = crypto.KDF(params, newkey.Salt, password)
 	if err != nil {
 		return nil, err
 	}
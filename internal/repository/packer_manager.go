@@ -103,7 +103,7 @@ func (r *Repository) savePacker(ctx context.Context, t restic.BlobType, p *Packe
 		return err
 	}
 
-	err = r.be.Save(ctx, h, rd)
+	err = r.be.Save(restic.WithPackBlobType(ctx, t), h, rd)
 	if err != nil {
 		debug.Log("Save(%v) error: %v", h, err)
 		return err
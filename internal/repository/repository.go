@@ -9,6 +9,8 @@ import (
 	"io"
 	"os"
 
+	"github.com/restic/chunker"
+	"github.com/restic/restic/internal/backend/dryrun"
 	"github.com/restic/restic/internal/cache"
 	"github.com/restic/restic/internal/crypto"
 	"github.com/restic/restic/internal/debug"
@@ -60,6 +62,15 @@ func (r *Repository) UseCache(c restic.Cache) {
 	r.be = c.Wrap(r.be)
 }
 
+// SetDryRun discards all changes to the repository from now on, except for
+// locks. Data is still processed as usual, so that the caller can report what
+// would have been saved. The cache is not updated either.
+func (r *Repository) SetDryRun() {
+	debug.Log("enabling dry run")
+	r.Cache = nil
+	r.be = dryrun.New(r.be)
+}
+
 // PrefixLength returns the number of bytes required so that all prefixes of
 // all IDs of type t are unique.
 func (r *Repository) PrefixLength(t restic.FileType) (int, error) {
@@ -588,8 +599,12 @@ func (r *Repository) SearchKey(ctx context.Context, password string, maxKeys int
 }
 
 // Init creates a new master key with the supplied password, initializes and
-// saves the repository config.
-func (r *Repository) Init(ctx context.Context, password string) error {
+// saves the repository config. If chunkerPolynomial is not nil, it is used
+// instead of a new random polynomial, e.g. to get the same chunks as in
+// another repository. If filesystemUUID is not empty, it is stored in the
+// config, so that the repository is only used on this filesystem. The
+// parameters of the KDF for the key are calibrated within kdfLimits.
+func (r *Repository) Init(ctx context.Context, password string, chunkerPolynomial *chunker.Pol, filesystemUUID string, kdfLimits KDFLimits) error {
 	has, err := r.be.Test(ctx, restic.Handle{Type: restic.ConfigFile})
 	if err != nil {
 		return err
@@ -602,14 +617,18 @@ func (r *Repository) Init(ctx context.Context, password string) error {
 	if err != nil {
 		return err
 	}
+	if chunkerPolynomial != nil {
+		cfg.ChunkerPolynomial = *chunkerPolynomial
+	}
+	cfg.FilesystemUUID = filesystemUUID
 
-	return r.init(ctx, password, cfg)
+	return r.init(ctx, password, cfg, kdfLimits)
 }
 
 // init creates a new master key with the supplied password and uses it to save
 // the config into the repo.
-func (r *Repository) init(ctx context.Context, password string, cfg restic.Config) error {
-	key, err := createMasterKey(r, password)
+func (r *Repository) init(ctx context.Context, password string, cfg restic.Config, kdfLimits KDFLimits) error {
+	key, err := createMasterKey(ctx, r, password, kdfLimits)
 	if err != nil {
 		return err
 	}
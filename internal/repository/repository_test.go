@@ -498,3 +498,35 @@ func TestDownloadAndHashErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestInitKDFLimits(t *testing.T) {
+	oldParams := repository.Params
+	repository.Params = nil
+	defer func() {
+		repository.Params = oldParams
+	}()
+
+	be, cleanup := repository.TestBackend(t)
+	defer cleanup()
+
+	repo := repository.New(be)
+	limits := repository.KDFLimits{Timeout: 10 * time.Millisecond, Memory: 1}
+	rtest.OK(t, repo.Init(context.TODO(), rtest.TestPassword, nil, "", limits))
+
+	// the limits must not change the parameters for other keys
+	rtest.Assert(t, repository.Params == nil, "Init changed the global KDF parameters")
+
+	var keys []string
+	rtest.OK(t, be.List(context.TODO(), restic.KeyFile, func(fi restic.FileInfo) error {
+		keys = append(keys, fi.Name)
+		return nil
+	}))
+	rtest.Equals(t, 1, len(keys))
+
+	key, err := repository.LoadKey(context.TODO(), repo, keys[0])
+	rtest.OK(t, err)
+
+	// scrypt uses 128*N*r bytes of memory
+	rtest.Assert(t, 128*key.N*key.R <= 1<<20,
+		"KDF parameters N=%d r=%d exceed the memory limit", key.N, key.R)
+}
@@ -52,6 +52,10 @@ type Backend interface {
 
 	// Delete removes all data in the backend.
 	Delete(ctx context.Context) error
+
+	// Capabilities returns the properties of the backend, which decide how
+	// failed uploads are handled.
+	Capabilities() Capabilities
 }
 
 // FileInfo is contains information about a file in the backend.
@@ -59,3 +63,30 @@ type FileInfo struct {
 	Size int64
 	Name string
 }
+
+// Capabilities describes properties of a backend which differ between the
+// storage services.
+type Capabilities struct {
+	// AtomicSave is true if a file is not visible to other clients before
+	// it has been saved completely, so a failed Save never leaves a partial
+	// file behind which would have to be removed.
+	AtomicSave bool
+
+	// MaxFileSize is the largest size of a single file in bytes, zero means
+	// there is no limit.
+	MaxFileSize int64
+}
+
+// Intersect returns the capabilities offered by both c and other.
+func (c Capabilities) Intersect(other Capabilities) Capabilities {
+	res := Capabilities{
+		AtomicSave:  c.AtomicSave && other.AtomicSave,
+		MaxFileSize: c.MaxFileSize,
+	}
+
+	if other.MaxFileSize > 0 && (res.MaxFileSize == 0 || other.MaxFileSize < res.MaxFileSize) {
+		res.MaxFileSize = other.MaxFileSize
+	}
+
+	return res
+}
@@ -0,0 +1,48 @@
+package restic
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCapabilitiesIntersect(t *testing.T) {
+	var tests = []struct {
+		a, b, want Capabilities
+	}{
+		{
+			a:    Capabilities{AtomicSave: true},
+			b:    Capabilities{},
+			want: Capabilities{},
+		},
+		{
+			a:    Capabilities{AtomicSave: true},
+			b:    Capabilities{AtomicSave: true},
+			want: Capabilities{AtomicSave: true},
+		},
+		{
+			a:    Capabilities{MaxFileSize: 100},
+			b:    Capabilities{},
+			want: Capabilities{MaxFileSize: 100},
+		},
+		{
+			a:    Capabilities{},
+			b:    Capabilities{MaxFileSize: 100},
+			want: Capabilities{MaxFileSize: 100},
+		},
+		{
+			a:    Capabilities{MaxFileSize: 200},
+			b:    Capabilities{MaxFileSize: 100},
+			want: Capabilities{MaxFileSize: 100},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			got := test.a.Intersect(test.b)
+			if !cmp.Equal(test.want, got) {
+				t.Error(cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package restic
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/restic/restic/internal/errors"
@@ -118,3 +119,19 @@ func (h BlobHandles) String() string {
 	}
 	return fmt.Sprintf("%v", elements)
 }
+
+type packBlobTypeKey struct{}
+
+// WithPackBlobType returns a context which records that the pack file saved
+// with it contains blobs of type t. Backends can use this to decide where to
+// store the file.
+func WithPackBlobType(ctx context.Context, t BlobType) context.Context {
+	return context.WithValue(ctx, packBlobTypeKey{}, t)
+}
+
+// PackBlobType returns the blob type recorded in ctx by WithPackBlobType, or
+// InvalidBlob if there is none.
+func PackBlobType(ctx context.Context) BlobType {
+	t, _ := ctx.Value(packBlobTypeKey{}).(BlobType)
+	return t
+}
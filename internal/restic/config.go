@@ -16,6 +16,10 @@ type Config struct {
 	Version           uint        `json:"version"`
 	ID                string      `json:"id"`
 	ChunkerPolynomial chunker.Pol `json:"chunker_polynomial"`
+
+	// FilesystemUUID is set for repositories in the local backend which may
+	// only be used on the filesystem they were created on.
+	FilesystemUUID string `json:"filesystem_uuid,omitempty"`
 }
 
 // RepoVersion is the version that is written to the config when a repository
@@ -172,6 +172,12 @@ func (l *Lock) createLock(ctx context.Context) (ID, error) {
 	return id, nil
 }
 
+// ID returns the ID of the lock file in the repository, or nil if the lock
+// was not created in the repository.
+func (l *Lock) ID() *ID {
+	return l.lockID
+}
+
 // Unlock removes the lock from the repository.
 func (l *Lock) Unlock() error {
 	if l == nil || l.lockID == nil {
@@ -73,6 +73,12 @@ func NewProgress() *Progress {
 	return &Progress{d: d}
 }
 
+// NewProgressInterval returns a new progress reporter which calls OnUpdate at
+// least every d interval, also when stdout is not a terminal.
+func NewProgressInterval(d time.Duration) *Progress {
+	return &Progress{d: d}
+}
+
 // Start resets and runs the progress reporter.
 func (p *Progress) Start() {
 	if p == nil || p.running {
@@ -63,6 +63,7 @@ type fileRestorer struct {
 
 	packCache   *packCache   // pack cache
 	filesWriter *filesWriter // file write
+	progress    *restic.Progress
 
 	dst   string
 	files []*fileInfo
@@ -169,7 +170,7 @@ func (r *fileRestorer) restoreFiles(ctx context.Context, onError func(path strin
 			target := r.targetPath(file.location)
 			if ferr != nil {
 				onError(file.location, ferr)
-				r.filesWriter.close(target)
+				_ = r.filesWriter.close(target)
 				delete(inprogress, file)
 				failure = append(failure, file)
 			} else {
@@ -178,7 +179,11 @@ func (r *fileRestorer) restoreFiles(ctx context.Context, onError func(path strin
 					return false // only interesed in the first pack
 				})
 				if len(file.blobs) == 0 {
-					r.filesWriter.close(target)
+					if err := r.filesWriter.close(target); err != nil {
+						onError(file.location, err)
+					} else {
+						r.progress.Report(restic.Stat{Files: 1})
+					}
 					delete(inprogress, file)
 				}
 				success = append(success, file)
@@ -284,6 +289,9 @@ func (r *fileRestorer) processPack(ctx context.Context, request processingInfo,
 				if err == nil {
 					err = r.filesWriter.writeToFile(target, buf)
 				}
+				if err == nil {
+					r.progress.Report(restic.Stat{Bytes: uint64(len(buf))})
+				}
 				if err != nil {
 					request.files[file] = err
 					break // could not restore the file
@@ -13,16 +13,20 @@ import (
 // Implementation allows virtually unlimited number of logically open
 // files, but number of phisically open files will never exceed number
 // of concurrent writeToFile invocations plus cacheCap.
+//
+// When sparse is set, blobs which only contain zeros are not written, the
+// file system allocates no space for the resulting holes.
 type filesWriter struct {
 	lock       sync.Mutex          // guards concurrent access to open files cache
-	inprogress map[string]struct{} // (logically) opened file writers
+	inprogress map[string]int64    // (logically) opened file writers and the offset of the next blob
 	cache      map[string]*os.File // cache of open files
 	cacheCap   int                 // max number of cached open files
+	sparse     bool                // do not write blobs which only contain zeros
 }
 
 func newFilesWriter(cacheCap int) *filesWriter {
 	return &filesWriter{
-		inprogress: make(map[string]struct{}),
+		inprogress: make(map[string]int64),
 		cache:      make(map[string]*os.File),
 		cacheCap:   cacheCap,
 	}
@@ -34,8 +38,8 @@ func (w *filesWriter) writeToFile(path string, blob []byte) error {
 	// - write the blob to the file
 	// - cache the open file if there is space, close the file otherwise
 	// Subsequent invocations will:
-	// - remove the open file from the cache _or_ open the file for append
-	// - write the blob to the file
+	// - remove the open file from the cache _or_ open the file for writing
+	// - write the blob to the file at the end of the previous blob
 	// - cache the open file if there is space, close the file otherwise
 	// The idea is to cap maximum number of open files with minimal
 	// coordination among concurrent writeToFile invocations (note that
@@ -43,27 +47,28 @@ func (w *filesWriter) writeToFile(path string, blob []byte) error {
 
 	// TODO measure if caching is useful (likely depends on operating system
 	// and hardware configuration)
-	acquireWriter := func() (*os.File, error) {
+	acquireWriter := func() (*os.File, int64, error) {
 		w.lock.Lock()
 		defer w.lock.Unlock()
+		offset := w.inprogress[path]
 		if wr, ok := w.cache[path]; ok {
 			debug.Log("Used cached writer for %s", path)
 			delete(w.cache, path)
-			return wr, nil
+			return wr, offset, nil
 		}
 		var flags int
-		if _, append := w.inprogress[path]; append {
-			flags = os.O_APPEND | os.O_WRONLY
+		if _, ok := w.inprogress[path]; ok {
+			flags = os.O_WRONLY
 		} else {
-			w.inprogress[path] = struct{}{}
+			w.inprogress[path] = 0
 			flags = os.O_CREATE | os.O_TRUNC | os.O_WRONLY
 		}
 		wr, err := os.OpenFile(path, flags, 0600)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		debug.Log("Opened writer for %s", path)
-		return wr, nil
+		return wr, offset, nil
 	}
 	cacheOrCloseWriter := func(wr *os.File) {
 		w.lock.Lock()
@@ -75,11 +80,15 @@ func (w *filesWriter) writeToFile(path string, blob []byte) error {
 		}
 	}
 
-	wr, err := acquireWriter()
+	wr, offset, err := acquireWriter()
 	if err != nil {
 		return err
 	}
-	n, err := wr.Write(blob)
+
+	n := len(blob)
+	if !w.sparse || !allZeros(blob) {
+		n, err = wr.WriteAt(blob, offset)
+	}
 	cacheOrCloseWriter(wr)
 	if err != nil {
 		return err
@@ -87,15 +96,37 @@ func (w *filesWriter) writeToFile(path string, blob []byte) error {
 	if n != len(blob) {
 		return errors.Errorf("error writing file %v: wrong length written, want %d, got %d", path, len(blob), n)
 	}
+
+	w.lock.Lock()
+	w.inprogress[path] = offset + int64(n)
+	w.lock.Unlock()
 	return nil
 }
 
-func (w *filesWriter) close(path string) {
+// close closes the file at path. For sparse files, the file is extended to
+// its full size, in case the last blobs were not written.
+func (w *filesWriter) close(path string) error {
 	w.lock.Lock()
 	defer w.lock.Unlock()
 	if wr, ok := w.cache[path]; ok {
 		wr.Close()
 		delete(w.cache, path)
 	}
+
+	size, ok := w.inprogress[path]
 	delete(w.inprogress, path)
+	if w.sparse && ok {
+		return os.Truncate(path, size)
+	}
+	return nil
+}
+
+// allZeros returns true if buf only contains zero bytes.
+func allZeros(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
 }
@@ -42,3 +42,30 @@ func TestFilesWriterBasic(t *testing.T) {
 	rtest.OK(t, err)
 	rtest.Equals(t, []byte{2, 2}, buf)
 }
+
+func TestFilesWriterSparse(t *testing.T) {
+	dir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	w := newFilesWriter(1)
+	w.sparse = true
+
+	f1 := dir + "/f1"
+	f2 := dir + "/f2"
+
+	rtest.OK(t, w.writeToFile(f1, []byte{0, 0}))
+	rtest.OK(t, w.writeToFile(f2, []byte{2}))
+	rtest.OK(t, w.writeToFile(f1, []byte{1}))
+	rtest.OK(t, w.writeToFile(f1, []byte{0, 0, 0}))
+	rtest.OK(t, w.close(f1))
+	rtest.OK(t, w.writeToFile(f2, []byte{0}))
+	rtest.OK(t, w.close(f2))
+
+	buf, err := ioutil.ReadFile(f1)
+	rtest.OK(t, err)
+	rtest.Equals(t, []byte{0, 0, 1, 0, 0, 0}, buf)
+
+	buf, err = ioutil.ReadFile(f2)
+	rtest.OK(t, err)
+	rtest.Equals(t, []byte{2, 0}, buf)
+}
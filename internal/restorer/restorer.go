@@ -15,11 +15,33 @@ import (
 
 // Restorer is used to restore a snapshot to a directory.
 type Restorer struct {
-	repo restic.Repository
-	sn   *restic.Snapshot
+	repo  restic.Repository
+	sn    *restic.Snapshot
+	stats Stats
 
 	Error        func(location string, err error) error
 	SelectFilter func(item string, dstpath string, node *restic.Node) (selectedForRestore bool, childMayBeSelected bool)
+
+	// Sparse restores files as sparse files, blocks which only contain zeros
+	// are not written.
+	Sparse bool
+
+	// XattrSelectFilter returns true for the extended attributes which are
+	// restored. When it is nil, all extended attributes are restored.
+	XattrSelectFilter func(name string) bool
+
+	// Progress is started by RestoreTo when all files to restore have been
+	// found, Stats returns the totals from then on. The number of bytes is
+	// reported when they are written to a file, a file is reported when its
+	// content is complete.
+	Progress *restic.Progress
+}
+
+// Stats counts the items restored from the snapshot.
+type Stats struct {
+	Files uint64 // files, symlinks and other non-directory items
+	Dirs  uint64
+	Bytes uint64 // content of the regular files, hardlinks are counted once
 }
 
 var restorerAbortOnAllErrors = func(location string, err error) error { return err }
@@ -156,6 +178,19 @@ func (res *Restorer) restoreNodeTo(ctx context.Context, node *restic.Node, targe
 
 func (res *Restorer) restoreNodeMetadataTo(node *restic.Node, target, location string) error {
 	debug.Log("restoreNodeMetadata %v %v %v", node.Name, target, location)
+
+	if res.XattrSelectFilter != nil && len(node.ExtendedAttributes) > 0 {
+		// the node may be used again, e.g. for hard links, so don't modify it
+		selected := *node
+		selected.ExtendedAttributes = nil
+		for _, attr := range node.ExtendedAttributes {
+			if res.XattrSelectFilter(attr.Name) {
+				selected.ExtendedAttributes = append(selected.ExtendedAttributes, attr)
+			}
+		}
+		node = &selected
+	}
+
 	err := node.RestoreMetadata(target)
 	if err != nil {
 		debug.Log("node.RestoreMetadata(%s) error %v", target, err)
@@ -207,12 +242,15 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 	idx := restic.NewHardlinkIndex()
 
 	filerestorer := newFileRestorer(dst, res.repo.Backend().Load, res.repo.Key(), filePackTraverser{lookup: res.repo.Index().Lookup})
+	filerestorer.filesWriter.sparse = res.Sparse
+	filerestorer.progress = res.Progress
 
 	// first tree pass: create directories and collect all files to restore
 	err = res.traverseTree(ctx, dst, string(filepath.Separator), *res.sn.Tree, treeVisitor{
 		enterDir: func(node *restic.Node, target, location string) error {
 			// create dir with default permissions
 			// #leaveDir restores dir metadata after visiting all children
+			res.stats.Dirs++
 			return fs.MkdirAll(target, 0700)
 		},
 
@@ -224,6 +262,8 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 				return err
 			}
 
+			res.stats.Files++
+
 			if node.Type != "file" {
 				return nil
 			}
@@ -239,6 +279,7 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 				idx.Add(node.Inode, node.DeviceID, location)
 			}
 
+			res.stats.Bytes += node.Size
 			filerestorer.addFile(location, node.Content)
 
 			return nil
@@ -249,7 +290,9 @@ func (res *Restorer) RestoreTo(ctx context.Context, dst string) error {
 		return err
 	}
 
+	res.Progress.Start()
 	err = filerestorer.restoreFiles(ctx, func(location string, err error) { res.Error(location, err) })
+	res.Progress.Done()
 	if err != nil {
 		return err
 	}
@@ -285,6 +328,11 @@ func (res *Restorer) Snapshot() *restic.Snapshot {
 	return res.sn
 }
 
+// Stats returns the number of items restored by RestoreTo.
+func (res *Restorer) Stats() Stats {
+	return res.stats
+}
+
 // VerifyFiles reads all snapshot files and verifies their contents
 func (res *Restorer) VerifyFiles(ctx context.Context, dst string) (int, error) {
 	// TODO multithreaded?
@@ -485,6 +485,81 @@ func TestRestorerRelative(t *testing.T) {
 	}
 }
 
+func TestRestorerStats(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	_, id := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"foo":   File{Data: "content: foo\n"},
+			"empty": File{Data: ""},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+					"subdir": Dir{
+						Nodes: map[string]Node{},
+					},
+				},
+			},
+		},
+	})
+
+	res, err := NewRestorer(repo, id)
+	rtest.OK(t, err)
+
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+	rtest.Equals(t, Stats{Files: 3, Dirs: 2, Bytes: 27}, res.Stats())
+}
+
+func TestRestorerProgress(t *testing.T) {
+	repo, cleanup := repository.TestRepository(t)
+	defer cleanup()
+
+	_, id := saveSnapshot(t, repo, Snapshot{
+		Nodes: map[string]Node{
+			"foo":   File{Data: "content: foo\n"},
+			"empty": File{Data: ""},
+			"dir": Dir{
+				Nodes: map[string]Node{
+					"file": File{Data: "content: file\n"},
+				},
+			},
+		},
+	})
+
+	res, err := NewRestorer(repo, id)
+	rtest.OK(t, err)
+
+	var total Stats
+	var done restic.Stat
+	res.Progress = restic.NewProgress()
+	res.Progress.OnStart = func() {
+		total = res.Stats()
+	}
+	res.Progress.OnUpdate = func(s restic.Stat, d time.Duration, ticker bool) {}
+	res.Progress.OnDone = func(s restic.Stat, d time.Duration, ticker bool) {
+		done = s
+	}
+
+	tempdir, cleanup := rtest.TempDir(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rtest.OK(t, res.RestoreTo(ctx, tempdir))
+	rtest.Equals(t, uint64(27), total.Bytes)
+
+	// the empty file has no content which needs to be restored
+	rtest.Equals(t, restic.Stat{Files: 2, Bytes: 27}, done)
+}
+
 type TraverseTreeCheck func(testing.TB) treeVisitor
 
 type TreeVisit struct {
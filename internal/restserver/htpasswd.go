@@ -0,0 +1,203 @@
+package restserver
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/restic/restic/internal/errors"
+)
+
+// Htpasswd contains the credentials read from an htpasswd file.
+type Htpasswd struct {
+	users map[string]string
+
+	// bcrypt is slow on purpose, so the SHA256 hash of the last password
+	// which was verified successfully is cached for each user
+	m        sync.Mutex
+	verified map[string][sha256.Size]byte
+}
+
+// isBcrypt returns true if hash was created with bcrypt.
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// ReadHtpasswd reads an htpasswd file from rd. Passwords must be hashed with
+// bcrypt (htpasswd -B), MD5 (htpasswd -m) or SHA1 (htpasswd -s), other hash
+// functions are rejected.
+func ReadHtpasswd(rd io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{
+		users:    make(map[string]string),
+		verified: make(map[string][sha256.Size]byte),
+	}
+
+	sc := bufio.NewScanner(rd)
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		data := strings.SplitN(line, ":", 2)
+		if len(data) != 2 || data[0] == "" {
+			return nil, errors.Errorf("line %d: expected user:hash", lineno)
+		}
+
+		user, hash := data[0], data[1]
+		if !isBcrypt(hash) && !strings.HasPrefix(hash, "$apr1$") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, errors.Errorf("line %d: unsupported password hash for user %q, use bcrypt, MD5 or SHA1", lineno, user)
+		}
+
+		h.users[user] = hash
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrap(err, "Scan")
+	}
+
+	return h, nil
+}
+
+// LoadHtpasswd reads the htpasswd file filename.
+func LoadHtpasswd(filename string) (*Htpasswd, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "Open")
+	}
+
+	h, err := ReadHtpasswd(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.Wrap(err, filename)
+	}
+
+	return h, errors.Wrap(f.Close(), "Close")
+}
+
+// Valid returns true if the password is correct for the user.
+func (h *Htpasswd) Valid(user, password string) bool {
+	hash, ok := h.users[user]
+	if !ok {
+		return false
+	}
+
+	if isBcrypt(hash) {
+		return h.validBcrypt(user, hash, password)
+	}
+
+	var computed string
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		computed = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt := strings.TrimPrefix(hash, "$apr1$")
+		if i := strings.IndexByte(salt, '$'); i >= 0 {
+			salt = salt[:i]
+		}
+		computed = apr1(password, salt)
+	}
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// validBcrypt returns true if password matches the bcrypt hash of the user.
+func (h *Htpasswd) validBcrypt(user, hash, password string) bool {
+	sum := sha256.Sum256([]byte(password))
+
+	h.m.Lock()
+	cached, ok := h.verified[user]
+	h.m.Unlock()
+
+	if ok && subtle.ConstantTimeCompare(cached[:], sum[:]) == 1 {
+		return true
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false
+	}
+
+	h.m.Lock()
+	h.verified[user] = sum
+	h.m.Unlock()
+
+	return true
+}
+
+// apr1 computes the Apache variant of the MD5 based crypt function.
+func apr1(password, salt string) string {
+	const magic = "$apr1$"
+	pw := []byte(password)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write([]byte(salt))
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	h := md5.New()
+	h.Write(pw)
+	h.Write([]byte(magic + salt))
+	for i := len(pw); i > 0; i -= 16 {
+		n := i
+		if n > 16 {
+			n = 16
+		}
+		h.Write(altSum[:n])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(pw[:1])
+		}
+	}
+	sum := h.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		h := md5.New()
+		if i&1 != 0 {
+			h.Write(pw)
+		} else {
+			h.Write(sum)
+		}
+		if i%3 != 0 {
+			h.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			h.Write(pw)
+		}
+		if i&1 != 0 {
+			h.Write(sum)
+		} else {
+			h.Write(pw)
+		}
+		sum = h.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var buf []byte
+	encode := func(v uint32, n int) {
+		for ; n > 0; n-- {
+			buf = append(buf, itoa64[v&0x3f])
+			v >>= 6
+		}
+	}
+
+	for _, idx := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint32(sum[idx[0]])<<16|uint32(sum[idx[1]])<<8|uint32(sum[idx[2]]), 4)
+	}
+	encode(uint32(sum[11]), 2)
+
+	return magic + salt + "$" + string(buf)
+}
@@ -0,0 +1,66 @@
+package restserver
+
+import (
+	"strings"
+	"testing"
+
+	rtest "github.com/restic/restic/internal/test"
+)
+
+// the hashes were created with "openssl passwd -apr1", "htpasswd -s" and
+// bcrypt with cost 5
+const testHtpasswd = `
+# comment
+md5user:$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/
+sha1user:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=
+bcryptuser:$2y$05$we4Y6OcYIJwKW8wlogCFueKrRUUr0KCxfAjjZODzoOGMVtoeDiSny
+longpw:$apr1$12345678$RkSsHiO1RUdjaoav57ZaW/
+`
+
+func TestHtpasswd(t *testing.T) {
+	h, err := ReadHtpasswd(strings.NewReader(testHtpasswd))
+	rtest.OK(t, err)
+
+	var tests = []struct {
+		user, password string
+		valid          bool
+	}{
+		{"md5user", "secret", true},
+		{"md5user", "Secret", false},
+		{"sha1user", "secret", true},
+		{"sha1user", "", false},
+		{"bcryptuser", "secret", true},
+		{"bcryptuser", "secret", true},
+		{"bcryptuser", "Secret", false},
+		{"bcryptuser", "", false},
+		{"longpw", "a much longer password than sixteen bytes", true},
+		{"longpw", "a much longer password than sixteen byte", false},
+		{"unknown", "secret", false},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			valid := h.Valid(test.user, test.password)
+			if valid != test.valid {
+				t.Errorf("Valid(%q, %q) returned %v, want %v", test.user, test.password, valid, test.valid)
+			}
+		})
+	}
+}
+
+func TestHtpasswdInvalid(t *testing.T) {
+	var tests = []string{
+		"user",
+		":$apr1$abcdefgh$h9FWgUz3n9YxylKLlR5SQ/",
+		"user:$6$abcdefgh$hash",
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			_, err := ReadHtpasswd(strings.NewReader(test))
+			if err == nil {
+				t.Errorf("no error returned for %q", test)
+			}
+		})
+	}
+}
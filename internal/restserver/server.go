@@ -0,0 +1,593 @@
+package restserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/restic/restic/internal/backend/local"
+	"github.com/restic/restic/internal/debug"
+	"github.com/restic/restic/internal/errors"
+	"github.com/restic/restic/internal/restic"
+)
+
+// the REST API protocol version is decided by HTTP request headers, these
+// are the same constants as used by the REST backend.
+const (
+	contentTypeV1 = "application/vnd.x.restic.rest.v1"
+	contentTypeV2 = "application/vnd.x.restic.rest.v2"
+)
+
+// fileTypes maps the directory names used in URLs to the file types.
+var fileTypes = map[string]restic.FileType{
+	"data":      restic.DataFile,
+	"keys":      restic.KeyFile,
+	"locks":     restic.LockFile,
+	"snapshots": restic.SnapshotFile,
+	"index":     restic.IndexFile,
+}
+
+// Config configures the server.
+type Config struct {
+	// Path is the directory in which the repositories are stored.
+	Path string
+
+	// Users contains the credentials of the clients, the server does not
+	// require authentication when it is nil.
+	Users *Htpasswd
+
+	// AppendOnly forbids removing files other than locks.
+	AppendOnly bool
+
+	// PrivateRepos only grants access to the repositories below a directory
+	// with the name of the authenticated user.
+	PrivateRepos bool
+
+	// MaxRepoSize limits the size of all files in a repository except locks,
+	// zero means no limit.
+	MaxRepoSize uint64
+}
+
+// Server serves repositories stored in local directories over the REST
+// protocol.
+type Server struct {
+	cfg Config
+
+	m     sync.Mutex
+	repos map[string]*local.Local
+
+	// used contains the size of the files in the repositories, it is only
+	// determined when MaxRepoSize is set and a file is saved
+	usedMutex sync.Mutex
+	used      map[string]uint64
+}
+
+// New returns a new server for the repositories below cfg.Path.
+func New(cfg Config) (*Server, error) {
+	if cfg.PrivateRepos && cfg.Users == nil {
+		return nil, errors.New("private repositories require authentication")
+	}
+
+	fi, err := os.Stat(cfg.Path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Stat")
+	}
+	if !fi.IsDir() {
+		return nil, errors.Errorf("%v is not a directory", cfg.Path)
+	}
+
+	return &Server{
+		cfg:   cfg,
+		repos: make(map[string]*local.Local),
+		used:  make(map[string]uint64),
+	}, nil
+}
+
+// request is the parsed path of a request.
+type request struct {
+	// repo is the name of the repository, it is empty for the repository
+	// stored in the root directory
+	repo string
+
+	// isFile is set when the request refers to a file or the config, isDir
+	// when it refers to all files of a type. Otherwise, the request refers to
+	// the repository itself.
+	isFile bool
+	isDir  bool
+	handle restic.Handle
+}
+
+// parsePath splits the URL path into the repository name and the file
+// within the repository. Names containing "." or ".." are rejected.
+func parsePath(p string) (req request, ok bool) {
+	segments := strings.Split(strings.Trim(p, "/"), "/")
+	if len(segments) == 1 && segments[0] == "" {
+		segments = nil
+	}
+
+	for _, s := range segments {
+		if s == "" || s == "." || s == ".." || strings.ContainsRune(s, '\\') {
+			return request{}, false
+		}
+	}
+
+	n := len(segments)
+	switch {
+	case n >= 1 && segments[n-1] == "config":
+		req.isFile = true
+		req.handle = restic.Handle{Type: restic.ConfigFile}
+		segments = segments[:n-1]
+
+	case n >= 2 && fileTypes[segments[n-2]] != "":
+		req.isFile = true
+		req.handle = restic.Handle{Type: fileTypes[segments[n-2]], Name: segments[n-1]}
+		segments = segments[:n-2]
+
+	case n >= 1 && fileTypes[segments[n-1]] != "":
+		req.isDir = true
+		req.handle = restic.Handle{Type: fileTypes[segments[n-1]]}
+		segments = segments[:n-1]
+	}
+
+	req.repo = strings.Join(segments, "/")
+	return req, true
+}
+
+// backend returns the backend for the repository. Unless create is set, the
+// repository directory must exist.
+func (s *Server) backend(repo string, create bool) (*local.Local, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if be, ok := s.repos[repo]; ok {
+		return be, nil
+	}
+
+	cfg := local.Config{Path: filepath.Join(s.cfg.Path, filepath.FromSlash(repo))}
+
+	var be *local.Local
+	var err error
+	if create {
+		be, err = local.Create(cfg)
+	} else {
+		if _, err = os.Stat(cfg.Path); err != nil {
+			return nil, err
+		}
+		be, err = local.Open(cfg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.repos[repo] = be
+	return be, nil
+}
+
+// authenticate checks the credentials of the request, it returns the name of
+// the user.
+func (s *Server) authenticate(r *http.Request) (user string, ok bool) {
+	if s.cfg.Users == nil {
+		return "", true
+	}
+
+	user, password, ok := r.BasicAuth()
+	if !ok || !s.cfg.Users.Valid(user, password) {
+		return "", false
+	}
+
+	return user, true
+}
+
+// allowed returns true if the user may access the repository.
+func (s *Server) allowed(user, repo string) bool {
+	if !s.cfg.PrivateRepos {
+		return true
+	}
+	return repo == user || strings.HasPrefix(repo, user+"/")
+}
+
+// ServeHTTP handles a request of the REST protocol.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	debug.Log("%v %v", r.Method, r.URL.Path)
+
+	user, ok := s.authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="restic"`)
+		httpError(w, http.StatusUnauthorized)
+		return
+	}
+
+	req, ok := parsePath(r.URL.Path)
+	if !ok {
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	if !s.allowed(user, req.repo) {
+		httpError(w, http.StatusForbidden)
+		return
+	}
+
+	switch {
+	case req.isFile:
+		s.serveFile(w, r, req)
+	case req.isDir:
+		if r.Method != http.MethodGet {
+			httpError(w, http.StatusMethodNotAllowed)
+			return
+		}
+		s.list(w, r, req)
+	default:
+		if r.Method != http.MethodPost || r.URL.Query().Get("create") != "true" {
+			httpError(w, http.StatusNotFound)
+			return
+		}
+		s.create(w, req)
+	}
+}
+
+func httpError(w http.ResponseWriter, code int) {
+	http.Error(w, http.StatusText(code), code)
+}
+
+// backendError reports an error returned by a backend to the client.
+func backendError(w http.ResponseWriter, be *local.Local, err error) {
+	if be == nil && os.IsNotExist(errors.Cause(err)) || be != nil && be.IsNotExist(err) {
+		httpError(w, http.StatusNotFound)
+		return
+	}
+
+	debug.Log("error: %v", err)
+	httpError(w, http.StatusInternalServerError)
+}
+
+func (s *Server) create(w http.ResponseWriter, req request) {
+	_, err := s.backend(req.repo, true)
+	if err != nil {
+		backendError(w, nil, err)
+		return
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, req request) {
+	be, err := s.backend(req.repo, false)
+	if err != nil {
+		backendError(w, nil, err)
+		return
+	}
+
+	type fileInfo struct {
+		Name string `json:"name"`
+		Size int64  `json:"size"`
+	}
+
+	var list []fileInfo
+	err = be.List(r.Context(), req.handle.Type, func(fi restic.FileInfo) error {
+		list = append(list, fileInfo{Name: fi.Name, Size: fi.Size})
+		return nil
+	})
+	if err != nil {
+		backendError(w, be, err)
+		return
+	}
+
+	var result interface{}
+	if r.Header.Get("Accept") == contentTypeV2 {
+		w.Header().Set("Content-Type", contentTypeV2)
+		if list == nil {
+			list = []fileInfo{}
+		}
+		result = list
+	} else {
+		w.Header().Set("Content-Type", contentTypeV1)
+		names := []string{}
+		for _, fi := range list {
+			names = append(names, fi.Name)
+		}
+		result = names
+	}
+
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		debug.Log("Encode: %v", err)
+	}
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request, req request) {
+	if r.Method == http.MethodDelete && s.cfg.AppendOnly && req.handle.Type != restic.LockFile {
+		httpError(w, http.StatusForbidden)
+		return
+	}
+
+	if err := req.handle.Valid(); err != nil {
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	be, err := s.backend(req.repo, false)
+	if err != nil {
+		backendError(w, nil, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		s.load(w, r, be, req.handle)
+
+	case http.MethodPost:
+		s.save(w, r, be, req)
+
+	case http.MethodDelete:
+		s.remove(w, r, be, req)
+
+	default:
+		httpError(w, http.StatusMethodNotAllowed)
+	}
+}
+
+// parseRange parses the header "Range: bytes=start-end" for a file of the
+// given size, the end is optional.
+func parseRange(header string, size int64) (offset, length int64, ok bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, false
+	}
+
+	data := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(data) != 2 {
+		return 0, 0, false
+	}
+
+	offset, err := strconv.ParseInt(data[0], 10, 64)
+	if err != nil || offset < 0 || offset >= size {
+		return 0, 0, false
+	}
+
+	end := size - 1
+	if data[1] != "" {
+		end, err = strconv.ParseInt(data[1], 10, 64)
+		if err != nil || end < offset {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	return offset, end - offset + 1, true
+}
+
+func (s *Server) load(w http.ResponseWriter, r *http.Request, be *local.Local, h restic.Handle) {
+	fi, err := be.Stat(r.Context(), h)
+	if err != nil {
+		backendError(w, be, err)
+		return
+	}
+
+	status := http.StatusOK
+	offset, length := int64(0), fi.Size
+
+	if header := r.Header.Get("Range"); header != "" && r.Method == http.MethodGet {
+		var ok bool
+		offset, length, ok = parseRange(header, fi.Size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", fi.Size))
+			httpError(w, http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, fi.Size))
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+
+	if r.Method == http.MethodHead || length == 0 {
+		w.WriteHeader(status)
+		return
+	}
+
+	started := false
+	err = be.Load(r.Context(), h, int(length), offset, func(rd io.Reader) error {
+		w.WriteHeader(status)
+		started = true
+		_, err := io.Copy(w, rd)
+		return err
+	})
+	if err != nil {
+		debug.Log("Load(%v): %v", h, err)
+		if !started {
+			backendError(w, be, err)
+		}
+	}
+}
+
+// repoSize returns the size of all files in the repository except locks.
+func repoSize(ctx context.Context, be *local.Local) (uint64, error) {
+	var size uint64
+	for _, t := range []restic.FileType{restic.DataFile, restic.KeyFile, restic.SnapshotFile, restic.IndexFile} {
+		err := be.List(ctx, t, func(fi restic.FileInfo) error {
+			size += uint64(fi.Size)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	fi, err := be.Stat(ctx, restic.Handle{Type: restic.ConfigFile})
+	if err != nil && !be.IsNotExist(err) {
+		return 0, err
+	}
+
+	return size + uint64(fi.Size), nil
+}
+
+// reserve adds size bytes to the space used by the repository, which is
+// determined on first use. It returns false if this would exceed
+// MaxRepoSize.
+func (s *Server) reserve(ctx context.Context, repo string, be *local.Local, size uint64) (bool, error) {
+	s.usedMutex.Lock()
+	defer s.usedMutex.Unlock()
+
+	used, ok := s.used[repo]
+	if !ok {
+		var err error
+		used, err = repoSize(ctx, be)
+		if err != nil {
+			return false, err
+		}
+		debug.Log("repository %q uses %d bytes", repo, used)
+		s.used[repo] = used
+	}
+
+	if used+size > s.cfg.MaxRepoSize {
+		return false, nil
+	}
+
+	s.used[repo] = used + size
+	return true, nil
+}
+
+// release subtracts size bytes from the space used by the repository.
+func (s *Server) release(repo string, size uint64) {
+	s.usedMutex.Lock()
+	defer s.usedMutex.Unlock()
+
+	used, ok := s.used[repo]
+	if !ok {
+		return
+	}
+
+	if size > used {
+		size = used
+	}
+	s.used[repo] = used - size
+}
+
+// quotaApplies returns true if the size of the file h counts towards the
+// MaxRepoSize of the repository.
+func (s *Server) quotaApplies(h restic.Handle) bool {
+	return s.cfg.MaxRepoSize > 0 && h.Type != restic.LockFile
+}
+
+// maxFileSize is the maximum size of a file uploaded by a client, larger
+// uploads are rejected.
+var maxFileSize int64 = 1 << 30
+
+// bodyReader is a RewindReader for the body of a request, which cannot be
+// rewound once it has been read from. It records the error returned when
+// reading the body fails.
+type bodyReader struct {
+	io.Reader
+	length int64
+	read   bool
+	err    error
+}
+
+func (rd *bodyReader) Read(p []byte) (int, error) {
+	rd.read = true
+	n, err := rd.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		rd.err = err
+	}
+	return n, err
+}
+
+// Rewind returns an error if data has been read already.
+func (rd *bodyReader) Rewind() error {
+	if rd.read {
+		return errors.New("the request body cannot be rewound")
+	}
+	return nil
+}
+
+// Length returns the length of the body, which is negative if unknown.
+func (rd *bodyReader) Length() int64 {
+	return rd.length
+}
+
+func (s *Server) save(w http.ResponseWriter, r *http.Request, be *local.Local, req request) {
+	if r.ContentLength > maxFileSize {
+		httpError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	h := req.handle
+	if s.quotaApplies(h) {
+		// the space is reserved before the upload starts
+		if r.ContentLength < 0 {
+			httpError(w, http.StatusLengthRequired)
+			return
+		}
+
+		ok, err := s.reserve(r.Context(), req.repo, be, uint64(r.ContentLength))
+		if err != nil {
+			backendError(w, be, err)
+			return
+		}
+
+		if !ok {
+			httpError(w, http.StatusInsufficientStorage)
+			return
+		}
+	}
+
+	rd := &bodyReader{
+		Reader: http.MaxBytesReader(w, r.Body, maxFileSize),
+		length: r.ContentLength,
+	}
+
+	// files are never overwritten, the local backend refuses to save a file
+	// which exists already and removes the partial file on errors
+	err := be.Save(r.Context(), h, rd)
+	if err != nil && s.quotaApplies(h) {
+		s.release(req.repo, uint64(r.ContentLength))
+	}
+
+	if os.IsExist(errors.Cause(err)) {
+		httpError(w, http.StatusForbidden)
+		return
+	}
+
+	if err != nil && rd.err != nil {
+		// the upload was aborted or is larger than maxFileSize
+		debug.Log("reading body failed: %v", rd.err)
+		httpError(w, http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		debug.Log("Save(%v): %v", h, err)
+		httpError(w, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (s *Server) remove(w http.ResponseWriter, r *http.Request, be *local.Local, req request) {
+	var size uint64
+	if s.quotaApplies(req.handle) {
+		fi, err := be.Stat(r.Context(), req.handle)
+		if err != nil {
+			backendError(w, be, err)
+			return
+		}
+		size = uint64(fi.Size)
+	}
+
+	err := be.Remove(r.Context(), req.handle)
+	if err != nil {
+		backendError(w, be, err)
+		return
+	}
+
+	if s.quotaApplies(req.handle) {
+		s.release(req.repo, size)
+	}
+}
@@ -0,0 +1,240 @@
+package restserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/restic/restic/internal/backend"
+	"github.com/restic/restic/internal/backend/rest"
+	"github.com/restic/restic/internal/backend/test"
+	"github.com/restic/restic/internal/restic"
+	rtest "github.com/restic/restic/internal/test"
+)
+
+func newTestServer(t testing.TB, cfg Config) (*httptest.Server, func()) {
+	dir, cleanup := rtest.TempDir(t)
+	cfg.Path = dir
+
+	srv, err := New(cfg)
+	rtest.OK(t, err)
+
+	ts := httptest.NewServer(srv)
+	return ts, func() {
+		ts.Close()
+		cleanup()
+	}
+}
+
+func newTestSuite(t testing.TB, serverURL string) *test.Suite {
+	tr, err := backend.Transport(backend.TransportOptions{})
+	rtest.OK(t, err)
+
+	return &test.Suite{
+		NewConfig: func() (interface{}, error) {
+			cfg := rest.NewConfig()
+			u, err := url.Parse(serverURL + "/repo")
+			if err != nil {
+				return nil, err
+			}
+			cfg.URL = u
+			return cfg, nil
+		},
+
+		Create: func(config interface{}) (restic.Backend, error) {
+			return rest.Create(config.(rest.Config), tr)
+		},
+
+		Open: func(config interface{}) (restic.Backend, error) {
+			return rest.Open(config.(rest.Config), tr)
+		},
+
+		Cleanup: func(config interface{}) error {
+			return nil
+		},
+	}
+}
+
+func TestBackendRESTServer(t *testing.T) {
+	ts, cleanup := newTestServer(t, Config{})
+	defer cleanup()
+
+	newTestSuite(t, ts.URL).RunTests(t)
+}
+
+func TestParsePath(t *testing.T) {
+	var tests = []struct {
+		path   string
+		ok     bool
+		repo   string
+		isFile bool
+		isDir  bool
+		handle restic.Handle
+	}{
+		{path: "/", ok: true},
+		{path: "/foo/bar/", ok: true, repo: "foo/bar"},
+		{path: "/config", ok: true, isFile: true, handle: restic.Handle{Type: restic.ConfigFile}},
+		{path: "/foo/config", ok: true, repo: "foo", isFile: true, handle: restic.Handle{Type: restic.ConfigFile}},
+		{path: "/foo/data/", ok: true, repo: "foo", isDir: true, handle: restic.Handle{Type: restic.DataFile}},
+		{path: "/foo/keys/1234", ok: true, repo: "foo", isFile: true, handle: restic.Handle{Type: restic.KeyFile, Name: "1234"}},
+		{path: "/snapshots/1234", ok: true, isFile: true, handle: restic.Handle{Type: restic.SnapshotFile, Name: "1234"}},
+		{path: "/foo/../config", ok: false},
+		{path: "/foo//data/", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run("", func(t *testing.T) {
+			req, ok := parsePath(test.path)
+			if ok != test.ok {
+				t.Fatalf("parsePath(%q) returned ok %v, want %v", test.path, ok, test.ok)
+			}
+
+			if req.repo != test.repo || req.isFile != test.isFile || req.isDir != test.isDir || req.handle != test.handle {
+				t.Errorf("parsePath(%q) returned %+v", test.path, req)
+			}
+		})
+	}
+}
+
+func doRequest(t testing.TB, method, url, user, password string) int {
+	req, err := http.NewRequest(method, url, strings.NewReader("foo"))
+	rtest.OK(t, err)
+
+	if user != "" {
+		req.SetBasicAuth(user, password)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	rtest.OK(t, err)
+	rtest.OK(t, res.Body.Close())
+	return res.StatusCode
+}
+
+func TestServerAppendOnly(t *testing.T) {
+	ts, cleanup := newTestServer(t, Config{AppendOnly: true})
+	defer cleanup()
+
+	rtest.Equals(t, http.StatusOK, doRequest(t, "POST", ts.URL+"/repo/?create=true", "", ""))
+
+	for _, dir := range []string{"data", "keys", "locks", "snapshots", "index"} {
+		url := ts.URL + "/repo/" + dir + "/" + restic.NewRandomID().String()
+		rtest.Equals(t, http.StatusOK, doRequest(t, "POST", url, "", ""))
+		rtest.Equals(t, http.StatusForbidden, doRequest(t, "POST", url, "", ""))
+
+		want := http.StatusForbidden
+		if dir == "locks" {
+			want = http.StatusOK
+		}
+		rtest.Equals(t, want, doRequest(t, "DELETE", url, "", ""))
+	}
+
+	url := ts.URL + "/repo/config"
+	rtest.Equals(t, http.StatusOK, doRequest(t, "POST", url, "", ""))
+	rtest.Equals(t, http.StatusForbidden, doRequest(t, "POST", url, "", ""))
+	rtest.Equals(t, http.StatusForbidden, doRequest(t, "DELETE", url, "", ""))
+}
+
+func TestServerMaxFileSize(t *testing.T) {
+	defer func(size int64) {
+		maxFileSize = size
+	}(maxFileSize)
+	maxFileSize = 2
+
+	ts, cleanup := newTestServer(t, Config{})
+	defer cleanup()
+
+	rtest.Equals(t, http.StatusOK, doRequest(t, "POST", ts.URL+"/repo/?create=true", "", ""))
+	url := ts.URL + "/repo/keys/" + restic.NewRandomID().String()
+
+	// the size is known in advance
+	rtest.Equals(t, http.StatusRequestEntityTooLarge, doRequest(t, "POST", url, "", ""))
+
+	// the size is only detected while reading the body
+	req, err := http.NewRequest("POST", url, ioutil.NopCloser(strings.NewReader("foo")))
+	rtest.OK(t, err)
+	res, err := http.DefaultClient.Do(req)
+	rtest.OK(t, err)
+	rtest.OK(t, res.Body.Close())
+	rtest.Equals(t, http.StatusBadRequest, res.StatusCode)
+
+	// the partial file was removed
+	rtest.Equals(t, http.StatusNotFound, doRequest(t, "HEAD", url, "", ""))
+}
+
+func TestServerMaxRepoSize(t *testing.T) {
+	ts, cleanup := newTestServer(t, Config{MaxRepoSize: 1000})
+	defer cleanup()
+
+	ctx := context.TODO()
+	be, err := rest.Create(rest.Config{URL: mustParseURL(t, ts.URL+"/repo"), Connections: 1}, nil)
+	rtest.OK(t, err)
+
+	save := func(tpe restic.FileType, size int) (restic.Handle, error) {
+		data := rtest.Random(size, size)
+		h := restic.Handle{Type: tpe, Name: restic.Hash(data).String()}
+		return h, be.Save(ctx, h, restic.NewByteReader(data))
+	}
+
+	h, err := save(restic.DataFile, 600)
+	rtest.OK(t, err)
+
+	_, err = save(restic.DataFile, 500)
+	rtest.Assert(t, err != nil, "exceeding the quota did not return an error")
+	rtest.Assert(t, backend.IsPermanent(err), "error is not permanent: %v", err)
+
+	// lock files are always accepted
+	lock, err := save(restic.LockFile, 500)
+	rtest.OK(t, err)
+	rtest.OK(t, be.Remove(ctx, lock))
+
+	// removing files frees space
+	rtest.OK(t, be.Remove(ctx, h))
+	_, err = save(restic.DataFile, 900)
+	rtest.OK(t, err)
+}
+
+func TestServerPrivateRepos(t *testing.T) {
+	users, err := ReadHtpasswd(strings.NewReader(testHtpasswd))
+	rtest.OK(t, err)
+
+	ts, cleanup := newTestServer(t, Config{Users: users, PrivateRepos: true})
+	defer cleanup()
+
+	var tests = []struct {
+		path, user, password string
+		status               int
+	}{
+		{"/md5user/?create=true", "", "", http.StatusUnauthorized},
+		{"/md5user/?create=true", "md5user", "wrong", http.StatusUnauthorized},
+		{"/md5user/?create=true", "md5user", "secret", http.StatusOK},
+		{"/md5user/repo2/?create=true", "md5user", "secret", http.StatusOK},
+		{"/md5user/?create=true", "sha1user", "secret", http.StatusForbidden},
+		{"/md5user2/?create=true", "md5user", "secret", http.StatusForbidden},
+		{"/?create=true", "md5user", "secret", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		status := doRequest(t, "POST", ts.URL+test.path, test.user, test.password)
+		if status != test.status {
+			t.Errorf("POST %v as %q returned status %v, want %v", test.path, test.user, status, test.status)
+		}
+	}
+
+	ctx := context.TODO()
+	be, err := rest.Open(rest.Config{URL: mustParseURL(t, ts.URL+"/md5user"), Connections: 1}, nil)
+	rtest.OK(t, err)
+	err = be.List(ctx, restic.SnapshotFile, func(restic.FileInfo) error { return nil })
+	if err == nil {
+		t.Errorf("List without credentials did not fail")
+	}
+}
+
+func mustParseURL(t testing.TB, s string) *url.URL {
+	u, err := url.Parse(s)
+	rtest.OK(t, err)
+	return u
+}
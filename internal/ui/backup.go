@@ -29,6 +29,7 @@ type Backup struct {
 	*StdioWrapper
 
 	MinUpdatePause time.Duration
+	DryRun         bool
 
 	term  *termstatus.Terminal
 	v     uint
@@ -362,9 +363,18 @@ func (b *Backup) Finish(snapshotID restic.ID) {
 	b.P("\n")
 	b.P("Files:       %5d new, %5d changed, %5d unmodified\n", b.summary.Files.New, b.summary.Files.Changed, b.summary.Files.Unchanged)
 	b.P("Dirs:        %5d new, %5d changed, %5d unmodified\n", b.summary.Dirs.New, b.summary.Dirs.Changed, b.summary.Dirs.Unchanged)
-	b.V("Data Blobs:  %5d new\n", b.summary.ItemStats.DataBlobs)
-	b.V("Tree Blobs:  %5d new\n", b.summary.ItemStats.TreeBlobs)
-	b.P("Added to the repo: %-5s\n", formatBytes(b.summary.ItemStats.DataSize+b.summary.ItemStats.TreeSize))
+
+	added := "Added to the repo:"
+	printBlobs := b.V
+	if b.DryRun {
+		// the number of new blobs is interesting for a dry run
+		added = "Would add to the repo:"
+		printBlobs = b.P
+	}
+
+	printBlobs("Data Blobs:  %5d new\n", b.summary.ItemStats.DataBlobs)
+	printBlobs("Tree Blobs:  %5d new\n", b.summary.ItemStats.TreeBlobs)
+	b.P("%s %-5s\n", added, formatBytes(b.summary.ItemStats.DataSize+b.summary.ItemStats.TreeSize))
 	b.P("\n")
 	b.P("processed %v files, %v in %s",
 		b.summary.Files.New+b.summary.Files.Changed+b.summary.Files.Unchanged,
@@ -378,3 +388,8 @@ func (b *Backup) Finish(snapshotID restic.ID) {
 func (b *Backup) SetMinUpdatePause(d time.Duration) {
 	b.MinUpdatePause = d
 }
+
+// SetDryRun sets b.DryRun. It satisfies the ArchiveProgressReporter interface.
+func (b *Backup) SetDryRun() {
+	b.DryRun = true
+}
@@ -29,6 +29,7 @@ type Backup struct {
 	*ui.StdioWrapper
 
 	MinUpdatePause time.Duration
+	DryRun         bool
 
 	term  *termstatus.Terminal
 	v     uint
@@ -351,6 +352,12 @@ func (b *Backup) ReportTotal(item string, s archiver.ScanStats) {
 // Finish prints the finishing messages.
 func (b *Backup) Finish(snapshotID restic.ID) {
 	close(b.finished)
+
+	id := snapshotID.Str()
+	if b.DryRun {
+		id = ""
+	}
+
 	json.NewEncoder(b.StdioWrapper.Stdout()).Encode(summaryOutput{
 		MessageType:         "summary",
 		FilesNew:            b.summary.Files.New,
@@ -365,7 +372,8 @@ func (b *Backup) Finish(snapshotID restic.ID) {
 		TotalFilesProcessed: b.summary.Files.New + b.summary.Files.Changed + b.summary.Files.Unchanged,
 		TotalBytesProcessed: b.summary.ProcessedBytes,
 		TotalDuration:       time.Since(b.start).Seconds(),
-		SnapshotID:          snapshotID.Str(),
+		SnapshotID:          id,
+		DryRun:              b.DryRun,
 	})
 }
 
@@ -375,6 +383,11 @@ func (b *Backup) SetMinUpdatePause(d time.Duration) {
 	b.MinUpdatePause = d
 }
 
+// SetDryRun sets b.DryRun. It satisfies the ArchiveProgressReporter interface.
+func (b *Backup) SetDryRun() {
+	b.DryRun = true
+}
+
 type statusUpdate struct {
 	MessageType      string   `json:"message_type"` // "status"
 	SecondsElapsed   uint64   `json:"seconds_elapsed,omitempty"`
@@ -419,5 +432,6 @@ type summaryOutput struct {
 	TotalFilesProcessed uint    `json:"total_files_processed"`
 	TotalBytesProcessed uint64  `json:"total_bytes_processed"`
 	TotalDuration       float64 `json:"total_duration"` // in seconds
-	SnapshotID          string  `json:"snapshot_id"`
+	SnapshotID          string  `json:"snapshot_id,omitempty"`
+	DryRun              bool    `json:"dry_run,omitempty"`
 }